@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"JustServe/pkg/mdns"
+	"JustServe/pkg/settings"
+)
+
+// AdvertiseStatus describes the mDNS advertisement of local shares
+type AdvertiseStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Hostname bool   `json:"hostname"` // justserve.local requested
+	Running  bool   `json:"running"`
+	Host     string `json:"host"`  // .local name the shares point to
+	Alias    string `json:"alias"` // "justserve.local" once claimed
+	Error    string `json:"error"` // Why advertising is enabled but not running, or the alias is taken
+}
+
+// GetAdvertiseStatus reports whether shares are advertised on the local network
+func (a *App) GetAdvertiseStatus() AdvertiseStatus {
+	st, _ := a.settings.Load()
+	status := AdvertiseStatus{Enabled: st.MDNS, Hostname: st.MDNSHostname}
+
+	a.mdnsMu.Lock()
+	defer a.mdnsMu.Unlock()
+	switch {
+	case a.mdns != nil:
+		status.Running = true
+		status.Host = a.mdns.Hostname()
+		status.Alias = a.mdns.Alias()
+		if st.MDNSHostname && a.mdns.AliasConflict() {
+			status.Error = mdns.Alias + " is already used by another device"
+		}
+	case a.mdnsErr != nil:
+		status.Error = a.mdnsErr.Error()
+	}
+	return status
+}
+
+// SetAdvertise turns mDNS advertisement and the justserve.local hostname on or
+// off and remembers the choice
+func (a *App) SetAdvertise(enabled bool, hostname bool) (AdvertiseStatus, error) {
+	if _, err := a.settings.Update(func(st *settings.Settings) {
+		st.MDNS, st.MDNSHostname = enabled, hostname
+	}); err != nil {
+		return a.GetAdvertiseStatus(), err
+	}
+	a.stopAdvertiser()
+	err := a.startAdvertiser()
+	return a.GetAdvertiseStatus(), err
+}
+
+// DiscoverShares lists the HTTP services advertised on the local network,
+// JustServe shares first
+func (a *App) DiscoverShares(timeoutSeconds int) ([]mdns.Service, error) {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 3
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+	list, err := mdns.Browse(ctx)
+	if list == nil {
+		list = []mdns.Service{}
+	}
+	return list, err
+}
+
+// startAdvertiser starts the mDNS responder if it is enabled in the settings
+func (a *App) startAdvertiser() error {
+	st, err := a.settings.Load()
+	if err != nil || !st.MDNS {
+		return nil
+	}
+
+	a.mdnsMu.Lock()
+	defer a.mdnsMu.Unlock()
+	if a.mdns != nil {
+		return nil
+	}
+	a.mdns, a.mdnsErr = mdns.Start(mdns.Options{Alias: st.MDNSHostname})
+	if a.mdnsErr != nil {
+		runtime.LogErrorf(a.ctx, "mDNS: %v", a.mdnsErr)
+		return a.mdnsErr
+	}
+	a.sessions.SetAdvertiser(a.mdns)
+	return nil
+}
+
+func (a *App) stopAdvertiser() {
+	a.mdnsMu.Lock()
+	defer a.mdnsMu.Unlock()
+	if a.mdns != nil {
+		a.sessions.SetAdvertiser(nil)
+		a.mdns.Close()
+		a.mdns = nil
+	}
+	a.mdnsErr = nil
+}
@@ -3,50 +3,151 @@ package main
 import (
 	"context"
 	"fmt"
-	"net"
-	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	stdruntime "runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
-	"golang.ngrok.com/ngrok"
 
+	"JustServe/pkg/control"
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/events/wailsevents"
+	"JustServe/pkg/mdns"
+	"JustServe/pkg/metrics"
 	"JustServe/pkg/p2p"
+	"JustServe/pkg/qr"
 	"JustServe/pkg/server"
-	"JustServe/pkg/tunnel"
+	"JustServe/pkg/secrets"
+	"JustServe/pkg/session"
+	"JustServe/pkg/settings"
 	"JustServe/pkg/update"
 	"JustServe/pkg/utils"
 )
 
 // App struct
 type App struct {
-	ctx         context.Context
-	server      *http.Server
-	ngrokTunnel ngrok.Tunnel
-	mu          sync.Mutex // Mutex for state management
-	isQuitting  bool       // Flag to determine if we are really quitting or just minimizing
-	cancel      context.CancelFunc
+	ctx        context.Context
+	isQuitting bool        // Flag to determine if we are really quitting or just minimizing
+	ui         events.Sink // Wails frontend, set on startup
+
+	// Running shares and proxies
+	sessions *session.Manager
 
 	// P2P Manager
 	p2pManager *p2p.Manager
+
+	// Persisted preferences, plus the running shares recorded as the last session
+	settings *settings.Store
+	sharesMu sync.Mutex
+	shares   []runningShare
+
+	// Encrypted ngrok tokens and passwords; vaultErr is set if it could not be opened
+	vault    *secrets.Vault
+	vaultErr error
+
+	// Loopback REST API for scripts; nil while disabled or failed (controlErr)
+	controlMu  sync.Mutex
+	control    *control.Server
+	controlErr error
+
+	// mDNS advertisement of local shares; nil while disabled or failed (mdnsErr)
+	mdnsMu  sync.Mutex
+	mdns    *mdns.Responder
+	mdnsErr error
+
+	// Prometheus /metrics on loopback; nil while disabled or failed (metricsErr)
+	metricsMu  sync.Mutex
+	metrics    *metrics.Server
+	metricsErr error
+}
+
+// runningShare remembers how a share session was started
+type runningShare struct {
+	id  string
+	cfg session.ShareConfig
+}
+
+// ShareOptions carries per-share settings beyond the basic
+// port/path/password/upload arguments of the start bindings
+type ShareOptions struct {
+	Upload         server.UploadLimits `json:"upload"`
+	PasswordSecret string              `json:"passwordSecret"` // Vault secret used when the password argument is empty
+	Interface      string              `json:"interface"`      // Network interface local shares listen on, "" for all
+	PortFallback   int                 `json:"portFallback"`   // Following ports local shares try when the port is busy
+	Expiry         string              `json:"expiry"`         // Stop after a duration or at a time, e.g. "2h" or "2025-06-01T18:00"; "" for never
+	MaxDownloads   int                 `json:"maxDownloads"`   // Stop after this many completed downloads, 0 for no limit
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
-		p2pManager: p2p.NewManager(),
+	path, err := settings.DefaultPath()
+	if err != nil {
+		path = "justserve-settings.json"
+	}
+	a := &App{settings: settings.NewStore(path)}
+	a.sessions = session.NewManager(events.Func(a.emit))
+	a.p2pManager = p2p.NewManager(events.Func(a.emit))
+	if vaultPath, err := secrets.DefaultPath(); err != nil {
+		a.vaultErr = err
+	} else {
+		a.vault, a.vaultErr = secrets.Open(vaultPath)
 	}
+	return a
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	// Pass context to P2P manager
-	a.p2pManager.SetContext(ctx)
+	a.ui = wailsevents.New(ctx)
+
+	a.importLegacySecrets()
+	a.startAdvertiser()
+	a.restoreLastSession()
+	a.startControlAPI()
+	a.startMetrics()
+}
+
+// shutdown is called when the app quits
+func (a *App) shutdown(ctx context.Context) {
+	a.stopControlAPI()
+	a.stopAdvertiser()
+	a.stopMetrics()
+}
+
+// emit receives session, share and P2P events and forwards them to the UI and
+// to control API event streams
+func (a *App) emit(name string, data interface{}) {
+	if ev, ok := data.(session.Event); ok && name == "session-stopped" {
+		a.forgetShare(ev.Session.ID)
+	}
+	if a.ui != nil {
+		a.ui.Emit(name, data)
+	}
+	a.publishControlEvent(name, data)
+}
+
+// restoreLastSession restarts the shares that were running when the app last
+// quit, if the user enabled it
+func (a *App) restoreLastSession() {
+	st, err := a.settings.Load()
+	if err != nil {
+		runtime.LogErrorf(a.ctx, "Failed to load settings: %v", err)
+		return
+	}
+	if !st.RestoreLastSession {
+		return
+	}
+	for _, cfg := range st.LastSession {
+		if _, err := a.startShare(cfg, st.NgrokTokenSecret); err != nil {
+			runtime.LogErrorf(a.ctx, "Failed to restore share %s: %v", cfg.Path, err)
+		}
+	}
 }
 
 // SelectFolder opens a dialog to select a folder
@@ -95,115 +196,323 @@ func (a *App) showApp() {
 }
 
 // StartLocalServer starts a local file server
-func (a *App) StartLocalServer(port string, path string, password string, allowUpload bool) (string, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+func (a *App) StartLocalServer(port string, path string, password string, allowUpload bool, opts ShareOptions) (string, error) {
+	return a.startShareWith(opts, session.ShareConfig{
+		Path:           path,
+		Port:           port,
+		Password:       password,
+		PasswordSecret: opts.PasswordSecret,
+		AllowUpload:    allowUpload,
+		Upload:         opts.Upload,
+		Interface:      opts.Interface,
+		PortFallback:   opts.PortFallback,
+	}, "")
+}
 
-	// Stop any existing server
-	a.stopServerInternal()
+// StartLocalMountServer starts a local file server sharing several folders,
+// each under its own mount point
+func (a *App) StartLocalMountServer(port string, mounts []server.Mount, password string, opts ShareOptions) (string, error) {
+	return a.startShareWith(opts, session.ShareConfig{
+		Mounts:         mounts,
+		Port:           port,
+		Password:       password,
+		PasswordSecret: opts.PasswordSecret,
+		Upload:         opts.Upload,
+		Interface:      opts.Interface,
+		PortFallback:   opts.PortFallback,
+	}, "")
+}
+
+// StartPublicServer starts a publicly accessible tunnel using ngrok.
+// tokenSecret names the vault secret holding the ngrok token.
+func (a *App) StartPublicServer(tokenSecret string, path string, password string, allowUpload bool, opts ShareOptions) (string, error) {
+	return a.startShareWith(opts, session.ShareConfig{
+		Path:           path,
+		Password:       password,
+		PasswordSecret: opts.PasswordSecret,
+		AllowUpload:    allowUpload,
+		Upload:         opts.Upload,
+		Public:         true,
+	}, tokenSecret)
+}
+
+// StartPublicMountServer starts a public tunnel sharing several folders
+func (a *App) StartPublicMountServer(tokenSecret string, mounts []server.Mount, password string, opts ShareOptions) (string, error) {
+	return a.startShareWith(opts, session.ShareConfig{
+		Mounts:         mounts,
+		Password:       password,
+		PasswordSecret: opts.PasswordSecret,
+		Upload:         opts.Upload,
+		Public:         true,
+	}, tokenSecret)
+}
+
+// startShareWith starts a share with the expiry and download limit of opts
+func (a *App) startShareWith(opts ShareOptions, cfg session.ShareConfig, tokenSecret string) (string, error) {
+	expires, err := parseExpiry(opts.Expiry)
+	if err != nil {
+		return "", err
+	}
+	cfg.ExpiresAt, cfg.MaxDownloads = expires, opts.MaxDownloads
+	return a.startShare(cfg, tokenSecret)
+}
 
-	// Default port if empty
-	if port == "" {
-		port = "8080"
+// parseExpiry reads an expiry given to a binding, see utils.ParseExpiry
+func parseExpiry(s string) (time.Time, error) {
+	t, err := utils.ParseExpiry(s, time.Now())
+	if err != nil {
+		return time.Time{}, errcode.New(errcode.InvalidInput, err.Error())
 	}
+	return t, nil
+}
 
-	// Prepare file server handler with custom logic
-	handler := server.NewFileHandler(path, password, allowUpload)
+// startShare starts a share session and returns its URL
+func (a *App) startShare(cfg session.ShareConfig, tokenSecret string) (string, error) {
+	s, err := a.startShareSession(cfg, tokenSecret)
+	if err != nil {
+		return "", err
+	}
+	return s.Info().URLs[0], nil
+}
 
-	// Create listener first to get the actual port (in case of 0)
-	// Ensure port starts with :
-	if !strings.HasPrefix(port, ":") {
-		port = ":" + port
+// startShareSession resolves the secrets of cfg and starts a share session
+// alongside any running sessions
+func (a *App) startShareSession(cfg session.ShareConfig, tokenSecret string) (*session.Session, error) {
+	run := cfg
+	if run.Password == "" && run.PasswordSecret != "" {
+		password, err := a.secret(run.PasswordSecret)
+		if err != nil {
+			return nil, err
+		}
+		run.Password = password
+	}
+	if run.Public {
+		token, err := a.secret(tokenSecret)
+		if err != nil {
+			return nil, fmt.Errorf("ngrok token: %w", err)
+		}
+		run.NgrokToken = token
+	}
+	if run.StatsFile == "" {
+		run.StatsFile = a.statsFile(run)
 	}
 
-	listener, err := net.Listen("tcp", port)
+	s, err := a.sessions.StartShare(run)
 	if err != nil {
-		// Try to find a better error message or recovery?
-		// For now return error
-		return "", fmt.Errorf("failed to listen on port %s: %w", port, err)
+		return nil, err
 	}
 
-	// Create server
-	a.server = &http.Server{Handler: handler}
+	// The last session is saved to disk, so a typed password goes to the vault
+	if cfg.Password != "" {
+		name := "session/" + s.ID()
+		if a.storeSecret(name, cfg.Password) == nil {
+			cfg.PasswordSecret = name
+		}
+		cfg.Password = ""
+	}
+	a.sharesMu.Lock()
+	a.shares = append(a.shares, runningShare{id: s.ID(), cfg: cfg})
+	a.sharesMu.Unlock()
+	a.saveShares(func(st *settings.Settings) {
+		for _, m := range cfg.Mounts {
+			st.AddRecentPath(m.Path)
+		}
+		st.AddRecentPath(cfg.Path)
+	})
+
+	return s, nil
+}
 
-	// Get preferred local IP for display
-	localIP := utils.GetPreferredLocalIP()
+// statsFile names the file the download stats of a share are saved to when
+// it stops, or "" when the settings do not ask for it
+func (a *App) statsFile(cfg session.ShareConfig) string {
+	st, err := a.settings.Load()
+	if err != nil || (st.StatsExport != "csv" && st.StatsExport != "json") {
+		return ""
+	}
+	dir := st.StatsDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, "Downloads", "JustServe")
+	}
+	name := filepath.Base(cfg.Path)
+	if len(cfg.Mounts) > 0 {
+		name = cfg.Mounts[0].Name
+	}
+	if strings.ContainsAny(name, `/\:`) { // A drive or file system root
+		name = "share"
+	}
+	return filepath.Join(dir, fmt.Sprintf("downloads-%s-%s.%s", name, time.Now().Format("20060102-150405"), st.StatsExport))
+}
+
+// forgetShare drops a stopped share from the recorded last session
+func (a *App) forgetShare(id string) {
+	a.sharesMu.Lock()
+	var stopped *runningShare
+	for i, rs := range a.shares {
+		if rs.id == id {
+			stopped = &rs
+			a.shares = append(a.shares[:i], a.shares[i+1:]...)
+			break
+		}
+	}
+	a.sharesMu.Unlock()
+	if stopped == nil {
+		return
+	}
+	a.saveShares(nil)
+	if name := stopped.cfg.PasswordSecret; strings.HasPrefix(name, "session/") || strings.HasPrefix(name, "last-session/") {
+		if a.vault != nil {
+			a.vault.Delete(name)
+		}
+	}
+}
 
-	// We need to get the actual port if ":0" was used, though here 'port' might be fixed?
-	// listener.Addr() ensures we get the real port
-	actualPort := listener.Addr().(*net.TCPAddr).Port
-	url := fmt.Sprintf("http://%s:%d", localIP, actualPort)
+// saveShares records the running shares as the last session, applying
+// fn first if set. Quitting the app keeps the record for the next launch.
+func (a *App) saveShares(fn func(st *settings.Settings)) {
+	a.sharesMu.Lock()
+	last := make([]session.ShareConfig, len(a.shares))
+	for i, rs := range a.shares {
+		last[i] = rs.cfg
+	}
+	a.sharesMu.Unlock()
 
-	// Start serving in a goroutine
-	go func() {
-		if err := a.server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			runtime.EventsEmit(a.ctx, "server-error", err.Error())
+	_, err := a.settings.Update(func(st *settings.Settings) {
+		if fn != nil {
+			fn(st)
 		}
-	}()
+		st.LastSession = last
+	})
+	if err != nil && a.ctx != nil {
+		runtime.LogErrorf(a.ctx, "Failed to save settings: %v", err)
+	}
+}
 
-	return url, nil
+// LoadSettings returns the persisted preferences, recent paths and presets
+func (a *App) LoadSettings() (settings.Settings, error) {
+	st, err := a.settings.Load()
+	st.LegacySecrets = nil // Cleartext until moved to the vault; never sent to the UI
+	return st, err
 }
 
-// StartPublicServer starts a publicly accessible tunnel using ngrok
-func (a *App) StartPublicServer(token string, path string, password string, allowUpload bool) (string, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// SaveSettings stores the user preferences. Recent paths, the last session, the
+// control API, mDNS and metrics settings are maintained by the app and are kept
+// as they are on disk.
+func (a *App) SaveSettings(st settings.Settings) error {
+	_, err := a.settings.Update(func(cur *settings.Settings) {
+		st.RecentPaths = cur.RecentPaths
+		st.LastSession = cur.LastSession
+		st.LegacySecrets = cur.LegacySecrets
+		st.ControlAPI, st.ControlPort = cur.ControlAPI, cur.ControlPort
+		st.MDNS, st.MDNSHostname = cur.MDNS, cur.MDNSHostname
+		st.Metrics, st.MetricsPort = cur.Metrics, cur.MetricsPort
+		*cur = st
+	})
+	return err
+}
 
-	// Stop any existing server
-	a.stopServerInternal()
+// SavePreset adds or replaces a named share preset. A password in the preset
+// is moved to the vault.
+func (a *App) SavePreset(preset settings.Preset) error {
+	if preset.Share.Password != "" {
+		name := settings.PresetPasswordSecret(strings.TrimSpace(preset.Name))
+		if err := a.storeSecret(name, preset.Share.Password); err != nil {
+			return err
+		}
+		preset.Share.Password = ""
+		preset.Share.PasswordSecret = name
+	}
+	var presetErr error
+	_, err := a.settings.Update(func(st *settings.Settings) {
+		presetErr = st.SetPreset(preset)
+	})
+	if presetErr != nil {
+		return presetErr
+	}
+	return err
+}
 
-	// Start ngrok tunnel using pkg/tunnel helper
-	// We use a background context for the tunnel itself so it doesn't die if the request context cancels (though wails calls are one-off?)
-	// Actually, keeping it simple with context.Background() is safer for persistence until StopServer is called.
-	tun, err := tunnel.StartNgrokTunnel(context.Background(), token)
+// DeletePreset removes a named share preset
+func (a *App) DeletePreset(name string) error {
+	_, err := a.settings.Update(func(st *settings.Settings) {
+		st.DeletePreset(name)
+	})
+	if err == nil && a.vault != nil {
+		a.vault.Delete(settings.PresetPasswordSecret(name))
+	}
+	return err
+}
+
+// StartPreset starts the share saved under name and returns its URL
+func (a *App) StartPreset(name string) (string, error) {
+	st, err := a.settings.Load()
 	if err != nil {
-		return "", fmt.Errorf("failed to start ngrok tunnel: %w", err)
+		return "", err
 	}
+	preset, ok := st.Preset(name)
+	if !ok {
+		return "", fmt.Errorf("no preset named %q", name)
+	}
+	return a.startShare(preset.Share, st.NgrokTokenSecret)
+}
 
-	a.ngrokTunnel = tun
+// ListSessions returns every running share and proxy
+func (a *App) ListSessions() []session.Info {
+	return a.sessions.List()
+}
 
-	// Start file server on the tunnel
-	handler := server.NewFileHandler(path, password, allowUpload)
-	a.server = &http.Server{Handler: handler}
+// StopSession stops a single share or proxy
+func (a *App) StopSession(id string) error {
+	return a.sessions.Stop(id)
+}
 
-	go func() {
-		if err := a.server.Serve(tun); err != nil && err != http.ErrServerClosed {
-			runtime.EventsEmit(a.ctx, "server-error", err.Error())
-		}
-	}()
+// ListConnections returns the clients connected to a share or proxy right now
+func (a *App) ListConnections(sessionID string) ([]server.Conn, error) {
+	return a.sessions.Connections(sessionID)
+}
+
+// GetDownloadStats returns the completed downloads of a running share by path
+func (a *App) GetDownloadStats(sessionID string) ([]session.FileStats, error) {
+	return a.sessions.Downloads(sessionID)
+}
+
+// KillConnection disconnects one client of a share or proxy
+func (a *App) KillConnection(sessionID string, connID string) error {
+	return a.sessions.KillConnection(sessionID, connID)
+}
+
+// DrainSession stops a single share or proxy once its transfers are done,
+// waiting at most timeout seconds (0 for the default)
+func (a *App) DrainSession(id string, timeout int) error {
+	return a.sessions.Drain(id, drainTimeout(timeout))
+}
 
-	return tun.URL(), nil
+// StopAll stops every running share and proxy
+func (a *App) StopAll() {
+	a.sessions.StopAll()
 }
 
-// StopServer stops any running server from frontend
-func (a *App) StopServer() {
-	a.mu.Lock()
-	a.stopServerInternal()
-	a.mu.Unlock()
+// drainTimeout converts a drain timeout in seconds from the frontend
+func drainTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return session.DefaultDrainTimeout
+	}
+	return time.Duration(seconds) * time.Second
 }
 
-// GetLocalIPs returns a list of all non-loopback IP addresses
+// GetLocalIPs returns a list of all non-loopback IP addresses, IPv4 first
 func (a *App) GetLocalIPs() ([]string, error) {
 	return utils.GetAllLocalIPs()
 }
 
-// Internal helper to stop servers without locking (called from within locked methods)
-func (a *App) stopServerInternal() {
-	if a.server != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		defer cancel()
-		// We use Shutdown for graceful shutdown, but with a short timeout.
-		// If it takes too long, we call Close().
-		if err := a.server.Shutdown(ctx); err != nil {
-			a.server.Close()
-		}
-		a.server = nil
-	}
-	// Ensure ngrok tunnel reference is cleared and closed.
-	if a.ngrokTunnel != nil {
-		a.ngrokTunnel.Close()
-		a.ngrokTunnel = nil
-	}
+// GetNetworkInterfaces lists the network adapters, best suited for LAN
+// sharing first, for choosing the one a share or P2P transfer binds to
+func (a *App) GetNetworkInterfaces() ([]utils.NetInterface, error) {
+	return utils.Interfaces()
 }
 
 // CheckUpdate checks GitHub for latest release
@@ -221,81 +530,26 @@ func (a *App) GetAppVersion() string {
 	return update.CurrentVersion
 }
 
-// StartProxy exposes a local port via Ngrok (HTTP) or net.Listen (TCP)
-func (a *App) StartProxy(token string, port string, protocol string) (string, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	if a.cancel != nil {
-		a.cancel()
+// StartProxy exposes a local port via Ngrok (HTTP) or net.Listen (TCP).
+// tokenSecret names the vault secret holding the ngrok token (HTTP only).
+func (a *App) StartProxy(tokenSecret string, port string, protocol string) (string, error) {
+	s, err := a.startProxySession(tokenSecret, port, protocol)
+	if err != nil {
+		return "", err
 	}
+	return s.Info().URLs[0], nil
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	a.cancel = cancel
-
+func (a *App) startProxySession(tokenSecret string, port string, protocol string) (*session.Session, error) {
+	cfg := session.ProxyConfig{Port: port, Protocol: protocol}
 	if protocol == "http" {
-		// Use Ngrok for HTTP (Public URL)
-		tun, err := tunnel.StartNgrokTunnel(ctx, token)
-		if err != nil {
-			cancel()
-			return "", err
-		}
-
-		a.ngrokTunnel = tun
-		tunURL := tun.URL()
-
-		go func() {
-			target := fmt.Sprintf("http://localhost:%s", port)
-			server, err := tunnel.CreateProxyHandler(target)
-			if err != nil {
-				runtime.EventsEmit(a.ctx, "server-error", err.Error())
-				return
-			}
-			
-			a.server = server
-			
-			if err := a.server.Serve(tun); err != nil && err != http.ErrServerClosed {
-				runtime.EventsEmit(a.ctx, "server-error", err.Error())
-			}
-		}()
-
-		return tunURL, nil
-	} else {
-		// Use local net.Listen for TCP (Sidesteps Ngrok Credit Card requirement)
-		listener, err := net.Listen("tcp", ":0")
+		token, err := a.secret(tokenSecret)
 		if err != nil {
-			cancel()
-			return "", fmt.Errorf("failed to start local TCP listener: %w", err)
+			return nil, fmt.Errorf("ngrok token: %w", err)
 		}
-
-		assignedPort := listener.Addr().(*net.TCPAddr).Port
-		localIP := utils.GetPreferredLocalIP()
-		proxyURL := fmt.Sprintf("tcp://%s:%d", localIP, assignedPort)
-
-		go func() {
-			<-ctx.Done()
-			listener.Close()
-		}()
-
-		go func() {
-			defer listener.Close()
-			for {
-				conn, err := listener.Accept()
-				if err != nil {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-						runtime.EventsEmit(a.ctx, "server-error", "Accept error: "+err.Error())
-						continue
-					}
-				}
-				go tunnel.HandleTCPConnection(conn, port)
-			}
-		}()
-
-		return proxyURL, nil
+		cfg.NgrokToken = token
 	}
+	return a.sessions.StartProxy(cfg)
 }
 
 // OpenInExplorer opens the OS file explorer at the given path
@@ -320,20 +574,33 @@ func (a *App) OpenInExplorer(path string) error {
 // P2P Direct Transfer Implementation (Delegated to P2P Manager)
 // ============================================================
 
-// StartP2PSend starts a P2P send server for the given file or folder
-func (a *App) StartP2PSend(filePath string) (string, error) {
-	// Delegate to P2P manager
-	return a.p2pManager.StartSend(filePath)
+// StartP2PSend starts a P2P send server for the given file or folder, bound to
+// the named network interface or to all of them when iface is "". It stops
+// after expiry ("2h", "2025-06-01T18:00", "" for never) or after
+// maxDownloads completed downloads (0 for no limit).
+func (a *App) StartP2PSend(filePath string, iface string, expiry string, maxDownloads int) (*p2p.TransferInfo, error) {
+	expires, err := parseExpiry(expiry)
+	if err != nil {
+		return nil, err
+	}
+	return a.p2pManager.SendWith(filePath, p2p.SendOptions{Interface: iface, ExpiresAt: expires, MaxDownloads: maxDownloads})
 }
 
 // DiscoverP2PPeers listens for P2P broadcast messages on the LAN
-func (a *App) DiscoverP2PPeers(timeoutSeconds int) (string, error) {
-	return a.p2pManager.DiscoverPeers(timeoutSeconds)
+func (a *App) DiscoverP2PPeers(timeoutSeconds int) ([]p2p.Peer, error) {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 10
+	}
+	peers, err := a.p2pManager.Discover(time.Duration(timeoutSeconds)*time.Second, nil)
+	if peers == nil {
+		peers = []p2p.Peer{}
+	}
+	return peers, err
 }
 
-// ConnectP2P connects to a peer using transfer code and IP address
-func (a *App) ConnectP2P(address string) (string, error) {
-	return a.p2pManager.ConnectToPeer(address)
+// ConnectP2P fetches the transfer offered by the sender at address
+func (a *App) ConnectP2P(address string) (*p2p.PeerInfo, error) {
+	return p2p.FetchPeerInfo(address)
 }
 
 // StopP2PTransfer stops the current P2P transfer session
@@ -341,7 +608,36 @@ func (a *App) StopP2PTransfer() {
 	a.p2pManager.StopTransfer()
 }
 
-// GetP2PStatus returns the current P2P transfer status
-func (a *App) GetP2PStatus() string {
-	return a.p2pManager.GetStatus()
+// DrainP2PTransfer stops the current P2P transfer session once the download
+// in progress is done, waiting at most timeout seconds (0 for the default).
+// It returns at once; a "p2p-status" of "stopped" follows.
+func (a *App) DrainP2PTransfer(timeout int) {
+	go a.p2pManager.DrainTransfer(drainTimeout(timeout))
+}
+
+// GetP2PStatus returns the current P2P transfer, or nil when not sending
+func (a *App) GetP2PStatus() *p2p.TransferInfo {
+	return a.p2pManager.Info()
+}
+
+// GetQRCode returns a data URI of a QR code for text, usually a session URL.
+// format is "svg" or "png" (the default).
+func (a *App) GetQRCode(text string, format string) (string, error) {
+	if text == "" {
+		return "", errcode.New(errcode.InvalidInput, "nothing to encode")
+	}
+	if format == "svg" {
+		return qr.SVGDataURI(text)
+	}
+	return qr.PNGDataURI(text, 8)
+}
+
+// GetP2PQRCode returns a QR code for the download page of the current P2P
+// transfer, with the transfer code in the URL
+func (a *App) GetP2PQRCode(format string) (string, error) {
+	info := a.p2pManager.Info()
+	if info == nil {
+		return "", errcode.New(errcode.NotFound, "no P2P transfer is running")
+	}
+	return a.GetQRCode(p2p.CodeURL(info.URL, info.Code), format)
 }
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"JustServe/pkg/events"
+	"JustServe/pkg/mdns"
+	"JustServe/pkg/metrics"
+	"JustServe/pkg/secrets"
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+	"JustServe/pkg/settings"
+	"JustServe/pkg/update"
+	"JustServe/pkg/utils"
+)
+
+// cliCommands are the subcommands that run JustServe without the Wails window
+var cliCommands = map[string]func(args []string) error{
+	"serve":      cmdServe,
+	"proxy":      cmdProxy,
+	"send":       cmdSend,
+	"receive":    cmdReceive,
+	"daemon":     cmdDaemon,
+	"interfaces": cmdInterfaces,
+	"version":    cmdVersion,
+	"help":       cmdHelp,
+}
+
+const cliUsage = `JustServe - headless mode
+
+Usage:
+  justserve serve <path> [flags]    Share a folder or file over HTTP
+  justserve proxy [flags]           Expose a local port (HTTP via ngrok, or TCP on the LAN)
+  justserve send <path> [flags]     Send a file or folder to another machine on the LAN
+  justserve receive <code> [flags]  Receive a file or folder by its 6-digit code
+  justserve daemon [flags]          Run the shares, proxies and inboxes of a config file
+  justserve interfaces              List network interfaces, best suited for LAN sharing first
+  justserve version                 Print the version
+  justserve help                    Show this help
+
+Run "justserve <command> -h" for the flags of a command.
+Without a command the desktop app is started.
+`
+
+// isCLICommand reports whether the process was started with a headless subcommand
+func isCLICommand(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	_, ok := cliCommands[args[1]]
+	return ok || args[1] == "-h" || args[1] == "--help"
+}
+
+// runCLI executes a headless subcommand and returns the process exit code
+func runCLI(args []string) int {
+	cmd, ok := cliCommands[args[0]]
+	if !ok {
+		cmd = cmdHelp
+	}
+	if err := cmd(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+func cmdHelp(args []string) error {
+	fmt.Print(cliUsage)
+	return nil
+}
+
+func cmdVersion(args []string) error {
+	fmt.Println("JustServe", update.CurrentVersion)
+	return nil
+}
+
+func cmdInterfaces(args []string) error {
+	ifaces, err := utils.Interfaces()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKIND\tSTATE\tMAC\tADDRESSES")
+	for _, ifi := range ifaces {
+		state := "down"
+		if ifi.Up {
+			state = "up"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ifi.Name, ifi.Kind, state, ifi.MAC, strings.Join(ifi.CIDRs, ", "))
+	}
+	return w.Flush()
+}
+
+// mountFlag collects repeated --mount name=path flags
+type mountFlag []server.Mount
+
+func (m *mountFlag) String() string { return fmt.Sprint(*m) }
+
+func (m *mountFlag) Set(v string) error {
+	name, path, ok := strings.Cut(v, "=")
+	if !ok {
+		name, path = "", v
+	}
+	*m = append(*m, server.Mount{Name: name, Path: path})
+	return nil
+}
+
+func cmdServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: justserve serve <path> [flags]\n       justserve serve --mount builds=./builds --mount docs=./docs [flags]")
+		fs.PrintDefaults()
+	}
+	port := fs.String("port", "8080", "local port to listen on")
+	portFallback := fs.Int("port-fallback", 0, "if the port is busy, try up to this many following ports")
+	iface := fs.String("interface", "", `listen on this network interface only (see "justserve interfaces")`)
+	password := fs.String("password", "", "require this password (HTTP basic auth)")
+	upload := fs.Bool("upload", false, "allow visitors to upload files")
+	public := fs.Bool("public", false, "serve through a public ngrok tunnel")
+	token := fs.String("token", os.Getenv("NGROK_AUTHTOKEN"), "ngrok auth token (default $NGROK_AUTHTOKEN, then the app's saved token)")
+	maxFile := fs.String("max-file-size", "", "largest accepted upload, e.g. 500MB")
+	quota := fs.String("upload-quota", "", "total upload quota for the share, e.g. 10GB")
+	minFree := fs.String("min-free-space", "", "refuse uploads below this free disk space, e.g. 1GB")
+	allowExt := fs.String("allow-ext", "", "comma-separated list of accepted upload extensions")
+	blockExt := fs.String("block-ext", "", "comma-separated list of refused upload extensions")
+	advertise := fs.Bool("mdns", false, "advertise the share on the local network (mDNS/DNS-SD)")
+	alias := fs.Bool("mdns-alias", false, "with --mdns, also publish justserve.local")
+	metricsPort := fs.Int("metrics-port", 0, "serve Prometheus /metrics on this 127.0.0.1 port")
+	drain := fs.Duration("drain", 0, "on Ctrl+C, let transfers in progress finish for up to this long (e.g. 30s)")
+	expire := fs.String("expire", "", "stop after this long or at this time, e.g. 2h, 7d or 2025-06-01T18:00")
+	maxDownloads := fs.Int("max-downloads", 0, "stop after this many completed downloads")
+	statsFile := fs.String("stats-file", "", "on exit, save per-file download stats here (.csv, else JSON)")
+	var mounts mountFlag
+	fs.Var(&mounts, "mount", "share a folder under /name (name=path, repeatable)")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg := session.ShareConfig{
+		Port:         *port,
+		PortFallback: *portFallback,
+		Interface:    *iface,
+		Password:     *password,
+		AllowUpload:  *upload,
+		Public:       *public,
+		NgrokToken:   *token,
+		MaxDownloads: *maxDownloads,
+		StatsFile:    *statsFile,
+	}
+	switch {
+	case len(mounts) > 0:
+		for i := range mounts {
+			mounts[i].AllowUpload = *upload
+		}
+		cfg.Mounts = mounts
+	case len(positional) == 1:
+		cfg.Path = positional[0]
+	default:
+		fs.Usage()
+		return errors.New("expected exactly one path to share")
+	}
+	if cfg.Public && cfg.NgrokToken == "" {
+		if cfg.NgrokToken = savedNgrokToken(); cfg.NgrokToken == "" {
+			return errors.New("--public needs an ngrok token (--token or $NGROK_AUTHTOKEN)")
+		}
+	}
+
+	if cfg.Upload.MaxFileSize, err = utils.ParseSize(*maxFile); err != nil {
+		return err
+	}
+	if cfg.Upload.Quota, err = utils.ParseSize(*quota); err != nil {
+		return err
+	}
+	if cfg.Upload.MinFreeSpace, err = utils.ParseSize(*minFree); err != nil {
+		return err
+	}
+	cfg.Upload.AllowedExts = splitList(*allowExt)
+	cfg.Upload.BlockedExts = splitList(*blockExt)
+	if cfg.ExpiresAt, err = utils.ParseExpiry(*expire, time.Now()); err != nil {
+		return err
+	}
+
+	var responder *mdns.Responder
+	if *advertise && !cfg.Public {
+		if responder, err = mdns.Start(mdns.Options{Alias: *alias}); err != nil {
+			return err
+		}
+		defer responder.Close()
+	}
+
+	return runHeadless(*metricsPort, *drain, func(m *session.Manager) (*session.Session, error) {
+		if responder != nil {
+			m.SetAdvertiser(responder)
+		}
+		return m.StartShare(cfg)
+	})
+}
+
+func cmdProxy(args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	port := fs.String("port", "3000", "local port to expose")
+	protocol := fs.String("protocol", "http", `"http" (public ngrok URL) or "tcp" (LAN listener)`)
+	token := fs.String("token", os.Getenv("NGROK_AUTHTOKEN"), "ngrok auth token (default $NGROK_AUTHTOKEN, then the app's saved token)")
+	metricsPort := fs.Int("metrics-port", 0, "serve Prometheus /metrics on this 127.0.0.1 port")
+	drain := fs.Duration("drain", 0, "on Ctrl+C, let open connections finish for up to this long (e.g. 30s)")
+
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	if *protocol != "http" && *protocol != "tcp" {
+		return fmt.Errorf("unknown protocol %q (use http or tcp)", *protocol)
+	}
+	if *protocol == "http" && *token == "" {
+		if *token = savedNgrokToken(); *token == "" {
+			return errors.New("http proxy needs an ngrok token (--token or $NGROK_AUTHTOKEN)")
+		}
+	}
+
+	return runHeadless(*metricsPort, *drain, func(m *session.Manager) (*session.Session, error) {
+		return m.StartProxy(session.ProxyConfig{Port: *port, Protocol: *protocol, NgrokToken: *token})
+	})
+}
+
+// runHeadless starts one session, logs to stdout and blocks until SIGINT/SIGTERM
+// or until the session dies, then shuts everything down cleanly. A non-zero
+// metricsPort also serves Prometheus /metrics on loopback; a non-zero drain
+// lets transfers in progress finish, until a second signal.
+func runHeadless(metricsPort int, drain time.Duration, start func(m *session.Manager) (*session.Session, error)) error {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sink := events.NewChannel(64)
+	m := session.NewManager(sink)
+	m.SetAccessLog(os.Stdout)
+	logged := logEvents(logger, sink, func(ev events.Event) {
+		if ev.Name == "session-stopped" {
+			stop()
+		}
+	})
+	defer func() {
+		sink.Close()
+		<-logged
+	}()
+
+	if metricsPort != 0 {
+		srv, err := metrics.Start(metricsPort, m)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		logger.Printf("Metrics at %s", srv.URL())
+	}
+
+	s, err := start(m)
+	if err != nil {
+		return err
+	}
+	for _, url := range s.Info().URLs {
+		logger.Printf("Serving at %s", url)
+	}
+	logger.Printf("Press Ctrl+C to stop")
+
+	<-ctx.Done()
+	logger.Printf("Shutting down...")
+	if drain > 0 && len(m.List()) > 0 {
+		logger.Printf("Waiting up to %s for transfers in progress, press Ctrl+C again to stop now", drain)
+		untilSignal(func() { m.DrainAll(drain) })
+	}
+	m.StopAll()
+	return nil
+}
+
+// untilSignal runs wait until it returns or SIGINT/SIGTERM arrives
+func untilSignal(wait func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-sigs:
+	}
+}
+
+// logEvents prints the events of a headless run until ch is closed, calling
+// then (if set) after each one. The returned channel is closed once the last
+// event was printed.
+func logEvents(logger *log.Logger, ch *events.Channel, then func(events.Event)) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range ch.C {
+			switch v := ev.Data.(type) {
+			case session.Event:
+				logger.Printf("%s: %s %s (%s)", ev.Name, v.Session.Kind, v.Session.ID, v.Session.Name)
+			case server.UploadRejection:
+				logger.Printf("%s: %s (%s): %s", ev.Name, v.FileName, v.Reason, v.Message)
+			case session.Expired:
+				logger.Printf("%s: %s %s reached its %s limit", ev.Name, v.Session.Kind, v.Session.ID, v.Reason)
+			case session.StatsExported:
+				logger.Printf("Download stats of %d path(s) saved to %s", v.Paths, v.File)
+			case session.Draining:
+				logger.Printf("%s: %s %s, %d transfer(s) in progress", ev.Name, v.Session.Kind, v.Session.ID, len(v.Session.Transfers))
+			case session.PortFallback:
+				if v.Owner != nil {
+					logger.Printf("Port %d is used by %s, listening on %d instead", v.Requested, v.Owner, v.Port)
+				} else {
+					logger.Printf("Port %d is busy, listening on %d instead", v.Requested, v.Port)
+				}
+			case events.Error:
+				logger.Printf("%s: %s", ev.Name, v.Message)
+			case events.Notice:
+				logger.Printf("%s", ev.Name)
+			default:
+				logger.Printf("%s: %v", ev.Name, v)
+			}
+			if then != nil {
+				then(ev)
+			}
+		}
+	}()
+	return done
+}
+
+// savedNgrokToken returns the ngrok token saved by the desktop app, if its
+// vault uses the machine key (a passphrase vault cannot be opened headless)
+func savedNgrokToken() string {
+	path, err := secrets.DefaultPath()
+	if err != nil {
+		return ""
+	}
+	vault, err := secrets.Open(path)
+	if err != nil {
+		return ""
+	}
+	name := settings.NgrokTokenSecret
+	if settingsPath, err := settings.DefaultPath(); err == nil {
+		if st, err := settings.NewStore(settingsPath).Load(); err == nil && st.NgrokTokenSecret != "" {
+			name = st.NgrokTokenSecret
+		}
+	}
+	token, _ := vault.Get(name)
+	return token
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments (the flag package stops at the first positional one)
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"JustServe/pkg/daemon"
+	"JustServe/pkg/events"
+	"JustServe/pkg/session"
+)
+
+func cmdDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: justserve daemon --config justserve.yaml [flags]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "justserve.yaml", "declarative config of shares, proxies and P2P inboxes")
+	check := fs.Bool("check", false, "validate the config and exit")
+
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	cfg, err := daemon.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *check {
+		fmt.Printf("%s: %d share(s), %d proxy(ies), %d inbox(es)\n",
+			*configPath, len(cfg.Shares), len(cfg.Proxies), len(cfg.Inboxes))
+		return nil
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	sink := events.NewChannel(64)
+	m := session.NewManager(sink)
+	logged := logEvents(logger, sink, nil)
+	defer func() {
+		sink.Close()
+		<-logged
+	}()
+
+	d := daemon.New(m, logger)
+	d.Apply(cfg) // Failed sections are logged; the rest keep running
+	logger.Printf("Daemon running with %s. Send SIGHUP to reload, Ctrl+C to stop", *configPath)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		logger.Printf("Reloading %s", *configPath)
+		cfg, err := daemon.LoadConfig(*configPath)
+		if err != nil {
+			logger.Printf("Reload failed, keeping the current config: %v", err)
+			continue
+		}
+		d.Apply(cfg)
+	}
+
+	logger.Printf("Shutting down...")
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop() // Waits for draining sessions
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-sigs:
+		logger.Printf("Stopping now")
+	}
+	m.StopAll()
+	<-stopped
+	return nil
+}
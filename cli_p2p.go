@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"JustServe/pkg/p2p"
+	"JustServe/pkg/utils"
+)
+
+func cmdSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: justserve send <path> [flags]")
+		fs.PrintDefaults()
+	}
+	keep := fs.Bool("keep", false, "keep sending after the first completed download")
+	iface := fs.String("interface", "", `serve and announce on this network interface only (see "justserve interfaces")`)
+	drain := fs.Duration("drain", 0, "on Ctrl+C, let a download in progress finish for up to this long (e.g. 30s)")
+	expire := fs.String("expire", "", "stop after this long or at this time, e.g. 2h, 7d or 2025-06-01T18:00")
+	maxDownloads := fs.Int("max-downloads", 0, "stop after this many completed downloads (implies --keep)")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one file or folder to send")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	expires, err := utils.ParseExpiry(*expire, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m := p2p.NewManager(nil)
+	info, err := m.SendWith(positional[0], p2p.SendOptions{Interface: *iface, ExpiresAt: expires, MaxDownloads: *maxDownloads})
+	if err != nil {
+		return err
+	}
+	defer m.StopTransfer()
+
+	fmt.Printf("Sending %s (%s)\n", info.FileName, sizeLabel(info.FileSize, info.IsDir))
+	fmt.Printf("Code: %s\n", info.Code)
+	fmt.Printf("On the other machine run: justserve receive %s\n", info.Code)
+	fmt.Printf("Or open %s in a browser. Press Ctrl+C to stop.\n", info.URL)
+	if !expires.IsZero() {
+		fmt.Printf("Stops at %s\n", expires.Format("Mon 2 Jan 15:04"))
+	}
+
+	bar := newProgressBar(info.FileSize)
+	if info.IsDir {
+		bar = newProgressBar(-1)
+	}
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	last := info
+	for {
+		select {
+		case <-ctx.Done():
+			bar.finish()
+			if current := m.Info(); *drain > 0 && current != nil && current.Status == "transferring" {
+				fmt.Printf("Waiting up to %s for the download in progress, press Ctrl+C again to stop now\n", *drain)
+				untilSignal(func() { m.DrainTransfer(*drain) })
+			}
+			fmt.Println("Stopped.")
+			return nil
+		case <-ticker.C:
+		}
+
+		current := m.Info()
+		if current == nil {
+			if limitReached(last) {
+				fmt.Println("Limit reached, stopped.")
+				return nil
+			}
+			return errors.New("transfer stopped")
+		}
+		last = current
+		switch current.Status {
+		case "transferring":
+			bar.update(current.BytesTransferred)
+		case "completed":
+			bar.update(current.BytesTransferred)
+			bar.finish()
+			if !*keep && *maxDownloads == 0 {
+				fmt.Println("Transfer completed.")
+				return nil
+			}
+			fmt.Println("Transfer completed, waiting for the next receiver...")
+			bar = newProgressBar(bar.total)
+			m.ResetStatus()
+		case "waiting":
+			if bar.current > 0 {
+				bar.finish()
+				fmt.Println("Download interrupted, waiting for the next receiver...")
+				bar = newProgressBar(bar.total)
+			}
+		case "error":
+			bar.finish()
+			return errors.New("transfer failed")
+		}
+	}
+}
+
+// limitReached reports whether a send stopped at its expiry time or download limit
+func limitReached(info *p2p.TransferInfo) bool {
+	if info.MaxDownloads > 0 && info.Downloads >= info.MaxDownloads {
+		return true
+	}
+	return !info.ExpiresAt.IsZero() && !time.Now().Before(info.ExpiresAt)
+}
+
+func cmdReceive(args []string) error {
+	fs := flag.NewFlagSet("receive", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: justserve receive <code> [flags]")
+		fs.PrintDefaults()
+	}
+	dir := fs.String("dir", ".", "folder to save the received file or folder in")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to search the network for the sender")
+	address := fs.String("address", "", "sender URL (e.g. http://192.168.1.20:41000), skips discovery")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("expected the 6-digit transfer code")
+	}
+	code := strings.TrimSpace(positional[0])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m := p2p.NewManager(nil)
+	url := *address
+	if url == "" {
+		fmt.Printf("Looking for sender %s on the network...\n", code)
+		peer, err := m.FindPeer(code, *timeout)
+		if err != nil {
+			return err
+		}
+		url = peer.URL
+	}
+
+	info, err := p2p.FetchPeerInfo(url)
+	if err != nil {
+		return err
+	}
+	if info.Code != code {
+		return fmt.Errorf("sender at %s has code %s, not %s", url, info.Code, code)
+	}
+	fmt.Printf("Receiving %s (%s) from %s\n", info.FileName, sizeLabel(info.FileSize, info.IsDir), url)
+
+	var bar *progressBar
+	path, err := m.Receive(ctx, url, *dir, func(received, total int64) {
+		if bar == nil {
+			bar = newProgressBar(total)
+		}
+		bar.update(received)
+	})
+	if bar != nil {
+		bar.finish()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved to %s\n", path)
+	return nil
+}
+
+// sizeLabel describes the size of a transfer; folders are zipped on the fly
+func sizeLabel(size int64, isDir bool) string {
+	if isDir {
+		return "folder"
+	}
+	return formatBytes(size)
+}
+
+// progressBar draws a single-line transfer progress bar on stdout
+type progressBar struct {
+	total   int64 // -1 when unknown
+	current int64
+	shown   int64 // Value of the last draw
+	start   time.Time
+	drawn   time.Time
+}
+
+func newProgressBar(total int64) *progressBar {
+	return &progressBar{total: total, start: time.Now()}
+}
+
+func (b *progressBar) update(current int64) {
+	b.current = current
+	if time.Since(b.drawn) < 100*time.Millisecond && current != b.total {
+		return
+	}
+	b.drawn = time.Now()
+	b.draw()
+}
+
+func (b *progressBar) draw() {
+	const width = 30
+	b.shown = b.current
+	speed := ""
+	if elapsed := time.Since(b.start).Seconds(); elapsed > 0 {
+		speed = formatBytes(int64(float64(b.current)/elapsed)) + "/s"
+	}
+	if b.total <= 0 {
+		fmt.Printf("\r%s received  %s   ", formatBytes(b.current), speed)
+		return
+	}
+	filled := int(b.current * width / b.total)
+	if filled > width {
+		filled = width
+	}
+	fmt.Printf("\r[%s%s] %3d%%  %s / %s  %s   ",
+		strings.Repeat("#", filled), strings.Repeat("-", width-filled),
+		b.current*100/b.total, formatBytes(b.current), formatBytes(b.total), speed)
+}
+
+// finish redraws the final state and ends the line
+func (b *progressBar) finish() {
+	if b.drawn.IsZero() {
+		return
+	}
+	if b.shown != b.current {
+		b.draw()
+	}
+	fmt.Println()
+}
+
+// formatBytes formats a byte count for terminal output
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"JustServe/pkg/control"
+	"JustServe/pkg/p2p"
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+	"JustServe/pkg/settings"
+	"JustServe/pkg/update"
+)
+
+// ControlAPIStatus describes the loopback REST API. The bearer token is only
+// written to EndpointFile, never sent to the UI.
+type ControlAPIStatus struct {
+	Enabled      bool   `json:"enabled"`
+	Running      bool   `json:"running"`
+	URL          string `json:"url"`
+	EndpointFile string `json:"endpointFile"` // JSON file with the URL and bearer token
+	Error        string `json:"error"`        // Why the API is enabled but not running
+}
+
+// controlBackend serves control API requests through the same code paths as
+// the App bindings, so shares started by scripts show up in the UI
+type controlBackend struct {
+	a *App
+}
+
+func (b controlBackend) StartShare(req control.ShareRequest) (session.Info, error) {
+	expires, err := parseExpiry(req.Expires)
+	if err != nil {
+		return session.Info{}, err
+	}
+	cfg := session.ShareConfig{
+		Path:           req.Path,
+		Mounts:         req.Mounts,
+		Port:           req.Port,
+		PortFallback:   req.PortFallback,
+		Password:       req.Password,
+		PasswordSecret: req.PasswordSecret,
+		AllowUpload:    req.AllowUpload,
+		Upload:         req.Upload,
+		Public:         req.Public,
+		Interface:      req.Interface,
+		ExpiresAt:      expires,
+		MaxDownloads:   req.MaxDownloads,
+		StatsFile:      req.StatsFile,
+	}
+	s, err := b.a.startShareSession(cfg, b.tokenSecret(req.TokenSecret))
+	if err != nil {
+		return session.Info{}, err
+	}
+	return s.Info(), nil
+}
+
+func (b controlBackend) StartProxy(req control.ProxyRequest) (session.Info, error) {
+	if req.Protocol == "" {
+		req.Protocol = "http"
+	}
+	s, err := b.a.startProxySession(b.tokenSecret(req.TokenSecret), req.Port, req.Protocol)
+	if err != nil {
+		return session.Info{}, err
+	}
+	return s.Info(), nil
+}
+
+// tokenSecret falls back to the ngrok token chosen in the settings
+func (b controlBackend) tokenSecret(name string) string {
+	if name != "" {
+		return name
+	}
+	if st, err := b.a.settings.Load(); err == nil && st.NgrokTokenSecret != "" {
+		return st.NgrokTokenSecret
+	}
+	return settings.NgrokTokenSecret
+}
+
+func (b controlBackend) StopSession(id string) error  { return b.a.sessions.Stop(id) }
+func (b controlBackend) StopAll()                     { b.a.sessions.StopAll() }
+func (b controlBackend) ListSessions() []session.Info { return b.a.sessions.List() }
+func (b controlBackend) StopP2P()                     { b.a.p2pManager.StopTransfer() }
+func (b controlBackend) P2PStatus() *p2p.TransferInfo { return b.a.p2pManager.Info() }
+func (b controlBackend) Version() string              { return update.CurrentVersion }
+
+func (b controlBackend) ListConnections(id string) ([]server.Conn, error) {
+	return b.a.sessions.Connections(id)
+}
+
+func (b controlBackend) KillConnection(id string, connID string) error {
+	return b.a.sessions.KillConnection(id, connID)
+}
+
+func (b controlBackend) ListDownloads(id string) ([]session.FileStats, error) {
+	return b.a.sessions.Downloads(id)
+}
+
+func (b controlBackend) DrainSession(id string, timeout time.Duration) error {
+	return b.a.sessions.Drain(id, timeout)
+}
+
+// The stops run in the background so the API answers right away
+func (b controlBackend) DrainAll(timeout time.Duration) { go b.a.sessions.DrainAll(timeout) }
+func (b controlBackend) DrainP2P(timeout time.Duration) { go b.a.p2pManager.DrainTransfer(timeout) }
+
+func (b controlBackend) StartP2PSend(path string, iface string, expires string, maxDownloads int) (*p2p.TransferInfo, error) {
+	return b.a.StartP2PSend(path, iface, expires, maxDownloads)
+}
+
+// GetControlAPIStatus reports whether the control API is enabled and where it listens
+func (a *App) GetControlAPIStatus() ControlAPIStatus {
+	st, _ := a.settings.Load()
+	status := ControlAPIStatus{Enabled: st.ControlAPI, EndpointFile: a.controlEndpointFile()}
+
+	a.controlMu.Lock()
+	defer a.controlMu.Unlock()
+	if a.control != nil {
+		status.Running = true
+		status.URL = a.control.URL()
+	} else if a.controlErr != nil {
+		status.Error = a.controlErr.Error()
+	}
+	return status
+}
+
+// SetControlAPIEnabled turns the control API on or off and remembers the choice
+func (a *App) SetControlAPIEnabled(enabled bool) (ControlAPIStatus, error) {
+	if _, err := a.settings.Update(func(st *settings.Settings) {
+		st.ControlAPI = enabled
+	}); err != nil {
+		return a.GetControlAPIStatus(), err
+	}
+	a.stopControlAPI()
+	a.startControlAPI()
+	status := a.GetControlAPIStatus()
+	if status.Error != "" {
+		return status, a.controlErr
+	}
+	return status, nil
+}
+
+// startControlAPI starts the control API if it is enabled in the settings
+func (a *App) startControlAPI() {
+	st, err := a.settings.Load()
+	if err != nil || !st.ControlAPI {
+		return
+	}
+
+	a.controlMu.Lock()
+	defer a.controlMu.Unlock()
+	if a.control != nil {
+		return
+	}
+	a.control, a.controlErr = control.Start(controlBackend{a}, st.ControlPort, a.controlEndpointFile())
+	if a.controlErr != nil {
+		runtime.LogErrorf(a.ctx, "Control API: %v", a.controlErr)
+	}
+}
+
+func (a *App) stopControlAPI() {
+	a.controlMu.Lock()
+	defer a.controlMu.Unlock()
+	if a.control != nil {
+		a.control.Close()
+		a.control = nil
+	}
+	a.controlErr = nil
+}
+
+// publishControlEvent forwards an app event to control API event streams
+func (a *App) publishControlEvent(name string, data interface{}) {
+	a.controlMu.Lock()
+	c := a.control
+	a.controlMu.Unlock()
+	if c != nil {
+		c.Publish(name, data)
+	}
+}
+
+// controlEndpointFile sits next to settings.json
+func (a *App) controlEndpointFile() string {
+	return filepath.Join(filepath.Dir(a.settings.Path()), "control.json")
+}
@@ -1,16 +1,16 @@
-import { useEffect } from 'react';
+import { useEffect, useState } from 'react';
 import './style.css';
 import * as runtime from '../wailsjs/runtime/runtime';
-import QRCode from 'react-qr-code';
 import {
     FolderOpen, Settings, Copy, Globe, Wifi, Square,
     Loader2, ArrowRight, Shield, CheckCircle2, UploadCloud,
     Terminal, Zap, Server, FileText,
     Sun, Moon, Share2, Network, Info, X, RefreshCw,
     Send, Download, Radio, Link2, Hash, ArrowUpCircle, ArrowDownCircle,
-    Radar, Eye
+    Radar, Eye, History, Lock, Search, Cable, Activity, Hourglass, Users, Unplug, Timer, FileDown
 } from 'lucide-react';
 import Logo from './components/Logo';
+import QrCode from './components/QrCode';
 import { ToastProvider, useToast } from './components/Toast';
 import { useAppStore } from './store/useAppStore';
 import { useActions, formatBytes } from './store/actions';
@@ -56,11 +56,145 @@ const ConfigToggle = ({ label, active, onChange, icon: Icon }) => {
     );
 };
 
+// Adapter local shares and P2P sends bind to; all interfaces when empty
+const InterfaceSelect = ({ t, disabled }) => {
+    const { networkInterfaces, bindInterface, setBindInterface } = useAppStore();
+    const usable = networkInterfaces.filter(i => i.up && i.kind !== 'loopback' && i.cidrs.length > 0);
+    return (
+        <section className="space-y-3">
+            <label className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider">{t('network_interface')}</label>
+            <div className="relative">
+                <select value={bindInterface} onChange={e => setBindInterface(e.target.value)} disabled={disabled}
+                    className="w-full bg-[var(--input-bg)] border border-[var(--input-border)] rounded-xl py-2.5 pl-10 pr-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 appearance-none cursor-pointer">
+                    <option value="">{t('all_interfaces')}</option>
+                    {usable.map(i => (
+                        <option key={i.name} value={i.name}>
+                            {i.name} · {t('iface_' + i.kind)} · {(i.cidrs.find(c => !c.includes(':')) || i.cidrs[0]).split('/')[0]}
+                        </option>
+                    ))}
+                </select>
+                <Cable size={16} className="absolute left-3.5 top-1/2 -translate-y-1/2 text-[var(--text-secondary)] pointer-events-none" />
+            </div>
+        </section>
+    );
+};
+
+// Transfers a draining server is waiting for, with the time left until they are cut off
+const DrainingPanel = ({ t, draining }) => {
+    const left = Math.max(0, Math.ceil((new Date(draining.deadline) - Date.now()) / 1000));
+    return (
+        <div className="mb-3 p-4 rounded-xl bg-amber-500/10 border border-amber-500/20 space-y-3">
+            <div className="flex items-center justify-between text-sm">
+                <span className="flex items-center gap-2 font-semibold text-amber-500"><Hourglass size={16} /> {t('draining')}</span>
+                <span className="font-mono text-xs text-[var(--text-secondary)]">{left}s</span>
+            </div>
+            {draining.transfers.length === 0 && <div className="text-xs text-[var(--text-secondary)]">{t('draining_idle')}</div>}
+            {draining.transfers.map((tr, i) => (
+                <div key={`${tr.remote}-${tr.path}-${i}`} className="space-y-1">
+                    <div className="flex justify-between gap-2 text-xs">
+                        <span className="font-mono truncate text-[var(--text-primary)]">{tr.method ? `${tr.method} ${tr.path}` : tr.remote}</span>
+                        <span className="font-mono shrink-0 text-[var(--text-secondary)]">{formatBytes(tr.bytes)}{tr.size ? ` / ${formatBytes(tr.size)}` : ''}</span>
+                    </div>
+                    {tr.size > 0 && (
+                        <div className="h-1.5 bg-[var(--bg-secondary)] rounded-full overflow-hidden">
+                            <div className="h-full bg-amber-500 rounded-full transition-all duration-300" style={{ width: `${Math.min((tr.bytes / tr.size) * 100, 100)}%` }} />
+                        </div>
+                    )}
+                </div>
+            ))}
+        </div>
+    );
+};
+
+// Clients of the running sessions with what they are fetching; each can be cut off
+const ConnectionsPanel = ({ t, actions, connections }) => (
+    <div className="mt-5 pt-5 border-t border-[var(--card-border)]">
+        <div className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider mb-2 flex items-center gap-2">
+            <Users size={14} /> {t('connections')} ({connections.length})
+        </div>
+        <div className="bg-[var(--bg-secondary)] rounded-lg border border-[var(--card-border)] max-h-48 overflow-y-auto divide-y divide-[var(--card-border)]">
+            {connections.length === 0 && <div className="p-3 text-xs text-[var(--text-secondary)] italic opacity-50">{t('no_connections')}</div>}
+            {connections.map(c => (
+                <div key={`${c.sessionId}-${c.id}`} className="flex items-center gap-3 px-3 py-2 text-xs">
+                    <div className={`w-1.5 h-1.5 rounded-full shrink-0 ${c.active ? 'bg-emerald-500 animate-pulse' : 'bg-[var(--input-border)]'}`} />
+                    <div className="flex-1 min-w-0">
+                        <div className="font-mono text-[var(--text-primary)] truncate">{c.remote}{c.user ? ` · ${c.user}` : ''}</div>
+                        <div className="font-mono text-[var(--text-secondary)] truncate">{c.method ? `${c.method} ${c.path}` : (c.active ? 'TCP' : t('conn_idle'))}</div>
+                    </div>
+                    <div className="font-mono text-right shrink-0 text-[var(--text-secondary)]">
+                        <div>{formatBytes(c.bytes)}</div>
+                        {c.active && <div className="text-emerald-500">{formatBytes(c.speed)}/s</div>}
+                    </div>
+                    <button onClick={() => actions.killConnection(c.sessionId, c.id)} title={t('disconnect')}
+                        className="p-1.5 rounded-lg text-[var(--text-secondary)] hover:text-red-500 hover:bg-red-500/10 transition-colors">
+                        <Unplug size={14} />
+                    </button>
+                </div>
+            ))}
+        </div>
+    </div>
+);
+
+// Completed downloads of the running shares by path, most downloaded first
+const DownloadsPanel = ({ t, stats }) => (
+    <div className="mt-5 pt-5 border-t border-[var(--card-border)]">
+        <div className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider mb-2 flex items-center gap-2">
+            <FileDown size={14} /> {t('download_stats')} ({stats.reduce((n, f) => n + f.downloads, 0)})
+        </div>
+        <div className="bg-[var(--bg-secondary)] rounded-lg border border-[var(--card-border)] max-h-48 overflow-y-auto divide-y divide-[var(--card-border)]">
+            {stats.map(f => (
+                <div key={`${f.sessionId}-${f.path}`} className="flex items-center gap-3 px-3 py-2 text-xs">
+                    <div className="flex-1 min-w-0">
+                        <div className="font-mono text-[var(--text-primary)] truncate" title={f.path}>{f.path}</div>
+                        <div className="text-[var(--text-secondary)] truncate">
+                            {f.clients} {t('stats_clients')} · {t('stats_last')} {new Date(f.lastAccess).toLocaleTimeString()}
+                        </div>
+                    </div>
+                    <div className="font-mono text-right shrink-0 text-[var(--text-secondary)]">
+                        <div className="text-[var(--text-primary)]">{f.downloads}×</div>
+                        <div>{formatBytes(f.bytes)}</div>
+                    </div>
+                </div>
+            ))}
+        </div>
+    </div>
+);
+
+// When a share or P2P send stops by itself: after a time and/or a number of
+// completed downloads
+const AutoStopSelect = ({ t, disabled }) => {
+    const { autoStop, autoStopAt, maxDownloads, setAutoStop, setAutoStopAt, setMaxDownloads } = useAppStore();
+    const inputClass = "w-full bg-[var(--input-bg)] border border-[var(--input-border)] rounded-xl py-2.5 px-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 transition-colors";
+    return (
+        <section className="space-y-3">
+            <label className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider">{t('auto_stop')}</label>
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
+                <div className="relative">
+                    <select value={autoStop} onChange={e => setAutoStop(e.target.value)} disabled={disabled}
+                        className="w-full bg-[var(--input-bg)] border border-[var(--input-border)] rounded-xl py-2.5 pl-10 pr-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 appearance-none cursor-pointer">
+                        <option value="">{t('auto_stop_never')}</option>
+                        <option value="1h">{t('auto_stop_1h')}</option>
+                        <option value="24h">{t('auto_stop_24h')}</option>
+                        <option value="7d">{t('auto_stop_7d')}</option>
+                        <option value="custom">{t('auto_stop_custom')}</option>
+                    </select>
+                    <Timer size={16} className="absolute left-3.5 top-1/2 -translate-y-1/2 text-[var(--text-secondary)] pointer-events-none" />
+                </div>
+                <input type="number" min="0" value={maxDownloads || ''} onChange={e => setMaxDownloads(Math.max(0, parseInt(e.target.value, 10) || 0))}
+                    disabled={disabled} placeholder={t('max_downloads')} title={t('max_downloads')} className={inputClass} />
+            </div>
+            {autoStop === 'custom' && (
+                <input type="datetime-local" value={autoStopAt} onChange={e => setAutoStopAt(e.target.value)} disabled={disabled} className={inputClass} />
+            )}
+        </section>
+    );
+};
+
 // ── Tab: Serve ────────────────────────────────────────────────────────────────
 const ServeTab = ({ t, actions }) => {
     const {
-        folderPath, serveMode, serveType, serverPort, usePassword, password, allowUpload,
-        isServing, setServeMode, setServerPort, setUsePassword, setPassword, setAllowUpload, clearSelection
+        folderPath, serveMode, serveType, serverPort, portFallback, usePassword, password, allowUpload,
+        isServing, setServeMode, setServerPort, setPortFallback, setUsePassword, setPassword, setAllowUpload, clearSelection
     } = useAppStore();
 
     return (
@@ -119,9 +253,19 @@ const ServeTab = ({ t, actions }) => {
                             className="w-full bg-[var(--input-bg)] border border-[var(--input-border)] rounded-xl py-2.5 pl-10 pr-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 transition-colors" />
                         <Settings size={16} className="absolute left-3.5 top-1/2 -translate-y-1/2 text-[var(--text-secondary)]" />
                     </div>
+                    {serveMode === 'local' && (
+                        <label className="flex items-center gap-2 text-xs text-[var(--text-secondary)] cursor-pointer">
+                            <input type="checkbox" checked={portFallback} onChange={e => setPortFallback(e.target.checked)} disabled={isServing} />
+                            {t('port_fallback')}
+                        </label>
+                    )}
                 </section>
             </div>
 
+            {serveMode === 'local' && <InterfaceSelect t={t} disabled={isServing} />}
+
+            <AutoStopSelect t={t} disabled={isServing} />
+
             <section className="space-y-3 pt-2">
                 <label className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider">{t('security_options')}</label>
                 <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
@@ -139,8 +283,9 @@ const ServeTab = ({ t, actions }) => {
 };
 
 // ── Tab: Proxy ────────────────────────────────────────────────────────────────
-const ProxyTab = ({ t }) => {
-    const { proxyPort, proxyProtocol, ngrokToken, isServing, setProxyPort, setProxyProtocol, setNgrokToken } = useAppStore();
+const ProxyTab = ({ t, actions }) => {
+    const { proxyPort, proxyProtocol, ngrokToken, ngrokTokenSecret, vaultStatus, isServing, setProxyPort, setProxyProtocol, setNgrokToken } = useAppStore();
+    const tokenSaved = !!vaultStatus?.names?.includes(ngrokTokenSecret);
     return (
         <div className="space-y-6">
             <section className="space-y-3">
@@ -162,7 +307,8 @@ const ProxyTab = ({ t }) => {
             <section className="space-y-3">
                 <label className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider">{t('ngrok_config')}</label>
                 <div className="relative">
-                    <input type="password" value={ngrokToken} onChange={e => setNgrokToken(e.target.value)} disabled={isServing} placeholder="Ngrok Authtoken"
+                    <input type="password" value={ngrokToken} onChange={e => setNgrokToken(e.target.value)} onBlur={actions.saveNgrokToken} disabled={isServing}
+                        placeholder={tokenSaved ? t('ngrok_token_saved') : 'Ngrok Authtoken'}
                         className="w-full bg-[var(--input-bg)] border border-[var(--input-border)] rounded-xl py-2.5 pl-10 pr-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 transition-colors" />
                     <Shield size={16} className="absolute left-3.5 top-1/2 -translate-y-1/2 text-[var(--text-secondary)]" />
                 </div>
@@ -227,6 +373,8 @@ const P2PTab = ({ t, actions }) => {
                             </div>
                         </div>
                     </section>
+                    <InterfaceSelect t={t} disabled={loading} />
+                    <AutoStopSelect t={t} disabled={loading} />
                     <section className="bg-emerald-600/5 border border-emerald-600/20 rounded-2xl p-5">
                         <div className="text-sm font-semibold text-emerald-400 mb-3 flex items-center gap-2"><Info size={16} /> {t('how_it_works')}</div>
                         <div className="grid grid-cols-3 gap-4 text-center">
@@ -263,7 +411,7 @@ const P2PTab = ({ t, actions }) => {
                     <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
                         <div className="bg-[var(--input-bg)] border border-[var(--card-border)] rounded-2xl p-6 flex flex-col items-center">
                             <div className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider mb-3">{t('scan_qr')}</div>
-                            <div className="bg-white p-3 rounded-xl"><QRCode value={p2pInfo.url} size={120} /></div>
+                            <div className="bg-white p-3 rounded-xl"><QrCode value={p2pInfo.code} p2p size={120} /></div>
                         </div>
                         <div className="bg-[var(--input-bg)] border border-[var(--card-border)] rounded-2xl p-6 space-y-4">
                             <div className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider">{t('transfer_details')}</div>
@@ -273,6 +421,14 @@ const P2PTab = ({ t, actions }) => {
                                 <div className="flex items-center gap-2"><Link2 size={14} className="text-[var(--text-secondary)]" /><span className="text-[var(--text-secondary)]">{t('url')}:</span>
                                     <span className="font-mono text-xs text-blue-400 truncate cursor-pointer hover:underline" onClick={() => actions.copyToClipboard(p2pInfo.url)}>{p2pInfo.url}</span>
                                 </div>
+                                {(p2pInfo.expiresAt || p2pInfo.maxDownloads > 0) && (
+                                    <div className="flex items-center gap-2"><Timer size={14} className="text-[var(--text-secondary)]" /><span className="text-[var(--text-secondary)]">{t('auto_stop')}:</span>
+                                        <span className="font-medium">
+                                            {[p2pInfo.expiresAt && new Date(p2pInfo.expiresAt).toLocaleString(),
+                                              p2pInfo.maxDownloads > 0 && `${p2pInfo.downloads || 0}/${p2pInfo.maxDownloads} ${t('downloads')}`].filter(Boolean).join(' · ')}
+                                        </span>
+                                    </div>
+                                )}
                             </div>
                             <div className="flex gap-2 pt-2">
                                 <button onClick={() => actions.copyToClipboard(p2pInfo.url)} className="flex-1 px-3 py-2 bg-[var(--bg-secondary)] hover:bg-[var(--input-border)] text-[var(--text-primary)] rounded-lg text-xs font-medium transition-colors border border-[var(--card-border)] flex items-center justify-center gap-1.5">
@@ -297,11 +453,14 @@ const P2PTab = ({ t, actions }) => {
                             {p2pInfo.status === 'completed' && (
                                 <div className="flex items-center gap-2 mt-3 text-sm text-emerald-400"><CheckCircle2 size={16} /> {t('transfer_completed')}</div>
                             )}
+                            {p2pInfo.draining && p2pInfo.status === 'transferring' && (
+                                <div className="flex items-center gap-2 mt-3 text-sm text-amber-500"><Hourglass size={16} /> {t('p2p_draining')}</div>
+                            )}
                         </div>
                     )}
-                    <button onClick={actions.stopP2P} disabled={loading}
+                    <button onClick={() => actions.stopP2P(p2pInfo.draining ? 'immediate' : undefined)} disabled={loading}
                         className="w-full py-3 bg-red-500/10 hover:bg-red-500/20 text-red-500 border border-red-500/20 rounded-xl font-bold text-base transition-all flex items-center justify-center gap-3 active:scale-[0.98]">
-                        {loading ? <Loader2 className="animate-spin" /> : <Square className="fill-current" size={18} />} {t('stop_sharing')}
+                        {loading ? <Loader2 className="animate-spin" /> : <Square className="fill-current" size={18} />} {p2pInfo.draining ? t('stop_now') : t('stop_sharing')}
                     </button>
                 </div>
             )}
@@ -385,9 +544,13 @@ const P2PTab = ({ t, actions }) => {
 // ── Tab: Settings ─────────────────────────────────────────────────────────────
 const SettingsTab = ({ t, actions }) => {
     const {
-        theme, lang, ngrokToken, autoStart, updateInfo, appVersion, loading,
-        setTheme, setLang, setNgrokToken, setAutoStart, resetSettings
+        theme, lang, ngrokToken, autoStart, restoreLastSession, updateInfo, appVersion, loading,
+        ngrokTokenSecret, vaultStatus, controlApi, advertise, metrics, nearbyShares, discoveringShares,
+        stopMode, drainTimeout, statsExport, statsDir,
+        setTheme, setLang, setNgrokToken, setAutoStart, setStopMode, setDrainTimeout, resetSettings
     } = useAppStore();
+    const [passphrase, setPassphrase] = useState('');
+    const tokenSaved = !!vaultStatus?.names?.includes(ngrokTokenSecret);
 
     return (
         <div className="space-y-6">
@@ -441,12 +604,26 @@ const SettingsTab = ({ t, actions }) => {
                 </div>
                 <div className="bg-[var(--input-bg)] border border-[var(--card-border)] rounded-xl p-5">
                     <label className="text-sm font-semibold text-[var(--text-primary)] flex items-center gap-2 mb-3"><Shield size={16} /> {t('ngrok_token')}</label>
-                    <input type="password" value={ngrokToken} onChange={e => setNgrokToken(e.target.value)}
+                    <input type="password" value={ngrokToken} onChange={e => setNgrokToken(e.target.value)} onBlur={actions.saveNgrokToken}
                         className="w-full bg-[var(--bg-secondary)] border border-[var(--input-border)] rounded-lg py-2.5 px-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500 focus:ring-2 focus:ring-blue-500/20 transition-all"
-                        placeholder={t('ngrok_token_placeholder')} />
+                        placeholder={tokenSaved ? t('ngrok_token_saved') : t('ngrok_token_placeholder')} />
                     <div className="flex items-start gap-2 text-xs text-[var(--text-secondary)] bg-blue-500/5 border border-blue-500/10 rounded-lg p-3 mt-3">
                         <Info size={14} className="mt-0.5 shrink-0 text-blue-500" /><span>{t('ngrok_token_help')}</span>
                     </div>
+                    <div className="h-px bg-[var(--card-border)] my-4" />
+                    <label className="text-sm font-semibold text-[var(--text-primary)] flex items-center gap-2 mb-1"><Lock size={16} /> {t('vault_passphrase')}</label>
+                    <p className="text-xs text-[var(--text-secondary)] mb-3">
+                        {vaultStatus?.locked ? t('vault_locked') : t('vault_passphrase_desc')}
+                    </p>
+                    <div className="flex gap-2">
+                        <input type="password" value={passphrase} onChange={e => setPassphrase(e.target.value)}
+                            className="flex-1 bg-[var(--bg-secondary)] border border-[var(--input-border)] rounded-lg py-2.5 px-4 text-sm text-[var(--text-primary)] focus:outline-none focus:border-blue-500 focus:ring-2 focus:ring-blue-500/20 transition-all"
+                            placeholder={t('vault_passphrase')} />
+                        <button onClick={() => { (vaultStatus?.locked ? actions.unlockVault : actions.setVaultPassphrase)(passphrase); setPassphrase(''); }}
+                            className="px-4 py-2 bg-blue-600 hover:bg-blue-500 text-white rounded-lg text-sm font-medium transition-colors">
+                            {vaultStatus?.locked ? t('vault_unlock') : t('vault_set_passphrase')}
+                        </button>
+                    </div>
                 </div>
             </section>
 
@@ -466,6 +643,147 @@ const SettingsTab = ({ t, actions }) => {
                             <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${autoStart ? 'translate-x-5' : 'translate-x-0'}`} />
                         </div>
                     </div>
+                    <div className="flex items-center justify-between p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)]">
+                        <div className="flex items-center gap-3">
+                            <History size={18} className="text-blue-500" />
+                            <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('restore_session')}</div><div className="text-xs text-[var(--text-secondary)]">{t('restore_session_desc')}</div></div>
+                        </div>
+                        <div className={`w-11 h-6 rounded-full relative transition-colors cursor-pointer ${restoreLastSession ? 'bg-blue-600' : 'bg-[var(--input-border)]'}`} onClick={() => actions.setRestoreLastSession(!restoreLastSession)}>
+                            <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${restoreLastSession ? 'translate-x-5' : 'translate-x-0'}`} />
+                        </div>
+                    </div>
+                    <div className="p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)] space-y-2">
+                        <div className="flex items-center justify-between">
+                            <div className="flex items-center gap-3">
+                                <Hourglass size={18} className="text-amber-500" />
+                                <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('graceful_stop')}</div><div className="text-xs text-[var(--text-secondary)]">{t('graceful_stop_desc')}</div></div>
+                            </div>
+                            <div className={`w-11 h-6 rounded-full relative transition-colors cursor-pointer ${stopMode === 'drain' ? 'bg-blue-600' : 'bg-[var(--input-border)]'}`} onClick={() => setStopMode(stopMode === 'drain' ? 'immediate' : 'drain')}>
+                                <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${stopMode === 'drain' ? 'translate-x-5' : 'translate-x-0'}`} />
+                            </div>
+                        </div>
+                        {stopMode === 'drain' && (
+                            <label className="flex items-center gap-2 text-xs text-[var(--text-secondary)] pl-8">
+                                {t('drain_timeout')}
+                                <input type="number" min="1" value={drainTimeout} onChange={e => setDrainTimeout(Math.max(1, parseInt(e.target.value, 10) || 1))}
+                                    className="w-20 bg-[var(--input-bg)] border border-[var(--input-border)] rounded-md py-1 px-2 text-xs text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50" />
+                            </label>
+                        )}
+                    </div>
+                    <div className="p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)] space-y-2">
+                        <div className="flex items-center justify-between">
+                            <div className="flex items-center gap-3">
+                                <FileDown size={18} className="text-cyan-500" />
+                                <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('stats_export')}</div><div className="text-xs text-[var(--text-secondary)]">{t('stats_export_desc')}</div></div>
+                            </div>
+                            <select value={statsExport} onChange={e => actions.setStatsExport(e.target.value)}
+                                className="bg-[var(--input-bg)] border border-[var(--input-border)] rounded-md py-1 px-2 text-xs text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50 cursor-pointer">
+                                <option value="">{t('stats_export_off')}</option>
+                                <option value="csv">CSV</option>
+                                <option value="json">JSON</option>
+                            </select>
+                        </div>
+                        {statsExport && (
+                            <div className="flex items-center gap-2 text-xs text-[var(--text-secondary)] pl-8">
+                                <span className="flex-1 font-mono truncate" title={statsDir}>{statsDir || t('stats_dir_default')}</span>
+                                {statsDir && (
+                                    <button onClick={() => actions.setStatsDir('')} title={t('stats_dir_reset')} className="p-1 rounded-md hover:text-red-500 transition-colors">
+                                        <X size={12} />
+                                    </button>
+                                )}
+                                <button onClick={() => actions.selectStatsDir()} className="flex items-center gap-1.5 px-3 py-1 bg-[var(--input-bg)] hover:bg-[var(--bg-secondary)] border border-[var(--input-border)] rounded-lg text-xs font-medium text-[var(--text-primary)] transition-colors">
+                                    <FolderOpen size={12} /> {t('stats_dir_choose')}
+                                </button>
+                            </div>
+                        )}
+                    </div>
+                    <div className="p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)] space-y-2">
+                        <div className="flex items-center justify-between">
+                            <div className="flex items-center gap-3">
+                                <Server size={18} className="text-purple-500" />
+                                <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('control_api')}</div><div className="text-xs text-[var(--text-secondary)]">{t('control_api_desc')}</div></div>
+                            </div>
+                            <div className={`w-11 h-6 rounded-full relative transition-colors cursor-pointer ${controlApi?.enabled ? 'bg-blue-600' : 'bg-[var(--input-border)]'}`} onClick={() => actions.setControlApiEnabled(!controlApi?.enabled)}>
+                                <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${controlApi?.enabled ? 'translate-x-5' : 'translate-x-0'}`} />
+                            </div>
+                        </div>
+                        {controlApi?.running && (
+                            <div className="text-xs text-[var(--text-secondary)] font-mono break-all pl-8">
+                                <div>{controlApi.url}</div>
+                                <div>{t('control_api_endpoint')}: {controlApi.endpointFile}</div>
+                            </div>
+                        )}
+                        {controlApi?.enabled && !controlApi?.running && controlApi?.error && (
+                            <div className="text-xs text-red-500 pl-8">{controlApi.error}</div>
+                        )}
+                    </div>
+                    <div className="p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)] space-y-2">
+                        <div className="flex items-center justify-between">
+                            <div className="flex items-center gap-3">
+                                <Radar size={18} className="text-green-500" />
+                                <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('mdns')}</div><div className="text-xs text-[var(--text-secondary)]">{t('mdns_desc')}</div></div>
+                            </div>
+                            <div className={`w-11 h-6 rounded-full relative transition-colors cursor-pointer ${advertise?.enabled ? 'bg-blue-600' : 'bg-[var(--input-border)]'}`} onClick={() => actions.setAdvertise(!advertise?.enabled, !!advertise?.hostname)}>
+                                <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${advertise?.enabled ? 'translate-x-5' : 'translate-x-0'}`} />
+                            </div>
+                        </div>
+                        {advertise?.enabled && (
+                            <label className="flex items-center gap-2 text-xs text-[var(--text-secondary)] pl-8 cursor-pointer">
+                                <input type="checkbox" checked={!!advertise.hostname} onChange={(e) => actions.setAdvertise(true, e.target.checked)} />
+                                {t('mdns_hostname')}
+                            </label>
+                        )}
+                        {advertise?.running && (
+                            <div className="text-xs text-[var(--text-secondary)] font-mono break-all pl-8">
+                                <div>{advertise.host}</div>
+                                {advertise.alias && <div>{advertise.alias}</div>}
+                            </div>
+                        )}
+                        {advertise?.enabled && advertise?.error && (
+                            <div className="text-xs text-red-500 pl-8">{advertise.error}</div>
+                        )}
+                        <div className="flex items-center justify-between pl-8 pt-1">
+                            <div className="text-xs font-semibold text-[var(--text-primary)]">{t('nearby_shares')}</div>
+                            <button onClick={actions.discoverShares} disabled={discoveringShares} className="flex items-center gap-1.5 px-3 py-1 bg-[var(--input-bg)] hover:bg-[var(--bg-secondary)] border border-[var(--input-border)] rounded-lg text-xs font-medium text-[var(--text-primary)] transition-colors disabled:opacity-50">
+                                {discoveringShares ? <Loader2 size={12} className="animate-spin" /> : <Search size={12} />} {t('find_shares')}
+                            </button>
+                        </div>
+                        {nearbyShares.length > 0 && (
+                            <div className="pl-8 space-y-1">
+                                {nearbyShares.map(svc => (
+                                    <button key={svc.instance} onClick={() => actions.openUrl(svc.url)} className="w-full text-left px-2 py-1.5 rounded-md hover:bg-[var(--input-bg)] transition-colors">
+                                        <div className={`text-xs truncate ${svc.justServe ? 'text-[var(--text-primary)] font-medium' : 'text-[var(--text-secondary)]'}`}>{svc.instance}</div>
+                                        <div className="text-[10px] font-mono text-[var(--text-secondary)] truncate">{svc.url}</div>
+                                    </button>
+                                ))}
+                            </div>
+                        )}
+                    </div>
+                    <div className="p-3 rounded-lg bg-[var(--bg-secondary)] border border-[var(--card-border)] space-y-2">
+                        <div className="flex items-center justify-between">
+                            <div className="flex items-center gap-3">
+                                <Activity size={18} className="text-orange-500" />
+                                <div><div className="text-sm font-semibold text-[var(--text-primary)]">{t('metrics')}</div><div className="text-xs text-[var(--text-secondary)]">{t('metrics_desc')}</div></div>
+                            </div>
+                            <div className={`w-11 h-6 rounded-full relative transition-colors cursor-pointer ${metrics?.enabled ? 'bg-blue-600' : 'bg-[var(--input-border)]'}`} onClick={() => actions.setMetrics(!metrics?.enabled, metrics?.port)}>
+                                <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${metrics?.enabled ? 'translate-x-5' : 'translate-x-0'}`} />
+                            </div>
+                        </div>
+                        {metrics?.enabled && (
+                            <label className="flex items-center gap-2 text-xs text-[var(--text-secondary)] pl-8">
+                                {t('metrics_port')}
+                                <input type="number" min="1" max="65535" key={metrics.port} defaultValue={metrics.port}
+                                    onBlur={(e) => { const port = parseInt(e.target.value, 10); if (port > 0 && port !== metrics.port) actions.setMetrics(true, port); }}
+                                    className="w-24 bg-[var(--input-bg)] border border-[var(--input-border)] rounded-md py-1 px-2 text-xs text-[var(--text-primary)] focus:outline-none focus:border-blue-500/50" />
+                            </label>
+                        )}
+                        {metrics?.running && (
+                            <div className="text-xs text-[var(--text-secondary)] font-mono break-all pl-8">{metrics.url}</div>
+                        )}
+                        {metrics?.enabled && !metrics?.running && metrics?.error && (
+                            <div className="text-xs text-red-500 pl-8">{metrics.error}</div>
+                        )}
+                    </div>
                     <div className="flex items-center justify-between p-3 rounded-lg bg-amber-500/10 border border-amber-500/20">
                         <div className="flex items-center gap-3">
                             <RefreshCw size={18} className="text-amber-600" />
@@ -537,7 +855,7 @@ const AppContent = () => {
     const actions = useActions(addToast);
 
     const {
-        activeTab, theme, lang, isServing, serverUrl, loading,
+        activeTab, theme, lang, isServing, serverUrl, loading, draining, connections, downloadStats,
         serveMode, updateInfo, logs, isHoveringStart, setIsHoveringStart,
         toggleTheme
     } = useAppStore();
@@ -611,7 +929,7 @@ const AppContent = () => {
 
                     <div className="max-w-4xl mx-auto space-y-6">
                         {activeTab === 'serve' && <ServeTab t={t} actions={actions} />}
-                        {activeTab === 'proxy' && <ProxyTab t={t} />}
+                        {activeTab === 'proxy' && <ProxyTab t={t} actions={actions} />}
                         {activeTab === 'p2p' && <P2PTab t={t} actions={actions} />}
                         {activeTab === 'settings' && <SettingsTab t={t} actions={actions} />}
                         {activeTab === 'about' && <AboutTab t={t} />}
@@ -627,11 +945,14 @@ const AppContent = () => {
                                         {t('start_server')}
                                     </button>
                                 ) : (
-                                    <button onClick={actions.stopServer} disabled={loading}
-                                        className="w-full py-4 bg-red-500/10 hover:bg-red-500/20 text-red-500 border border-red-500/20 rounded-xl font-bold text-lg transition-all flex items-center justify-center gap-3 active:scale-[0.98]">
-                                        {loading ? <Loader2 className="animate-spin" /> : <Square className="fill-current" />}
-                                        {t('stop_server')}
-                                    </button>
+                                    <>
+                                        {draining && <DrainingPanel t={t} draining={draining} />}
+                                        <button onClick={() => actions.stopServer(draining ? 'immediate' : undefined)} disabled={loading}
+                                            className="w-full py-4 bg-red-500/10 hover:bg-red-500/20 text-red-500 border border-red-500/20 rounded-xl font-bold text-lg transition-all flex items-center justify-center gap-3 active:scale-[0.98]">
+                                            {loading ? <Loader2 className="animate-spin" /> : <Square className="fill-current" />}
+                                            {draining ? t('stop_now') : t('stop_server')}
+                                        </button>
+                                    </>
                                 )}
                             </div>
                         )}
@@ -642,7 +963,7 @@ const AppContent = () => {
                         <div className="mt-8 max-w-3xl mx-auto bg-[var(--input-bg)] border border-emerald-500/20 rounded-xl p-5 backdrop-blur-md animate-in slide-in-from-bottom-4 duration-500">
                             <div className="flex flex-col md:flex-row gap-6 items-start">
                                 <div className="bg-white p-3 rounded-lg shrink-0 border border-[var(--card-border)]">
-                                    <QRCode value={serverUrl} size={100} />
+                                    <QrCode value={serverUrl} size={100} />
                                 </div>
                                 <div className="flex-1 w-full space-y-3">
                                     <div className="text-xs font-semibold text-emerald-500 uppercase tracking-wider mb-2 flex items-center gap-2">
@@ -661,6 +982,8 @@ const AppContent = () => {
                                     </div>
                                 </div>
                             </div>
+                            <ConnectionsPanel t={t} actions={actions} connections={connections} />
+                            {downloadStats.length > 0 && <DownloadsPanel t={t} stats={downloadStats} />}
                             {/* Logs */}
                             <div className="mt-5 pt-5 border-t border-[var(--card-border)]">
                                 <div className="text-xs font-semibold text-[var(--text-secondary)] uppercase tracking-wider mb-2">{t('system_logs')}</div>
@@ -0,0 +1,25 @@
+import { useEffect, useState } from 'react';
+import { GetQRCode, GetP2PQRCode } from '../../wailsjs/go/main/App';
+
+// QR code drawn by the Go encoder. With p2p set, value only triggers a
+// refresh and the code encodes the current transfer URL and code.
+const QrCode = ({ value, size = 120, p2p = false }) => {
+    const [src, setSrc] = useState('');
+
+    useEffect(() => {
+        let cancelled = false;
+        if (!value) {
+            setSrc('');
+            return;
+        }
+        (p2p ? GetP2PQRCode('svg') : GetQRCode(value, 'svg'))
+            .then(uri => { if (!cancelled) setSrc(uri); })
+            .catch(() => { if (!cancelled) setSrc(''); });
+        return () => { cancelled = true; };
+    }, [value, p2p]);
+
+    if (!src) return <div style={{ width: size, height: size }} />;
+    return <img src={src} width={size} height={size} alt="QR code" className="block" />;
+};
+
+export default QrCode;
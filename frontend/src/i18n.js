@@ -18,7 +18,7 @@ export const translations = {
         ngrok_token_placeholder:"Enter your Ngrok Authtoken...",
         ngrok_token_help:"This token is required to expose your services to the public internet. You can get a token at ngrok.com",
         advanced:"Advanced",advanced_desc:"Options for advanced users",auto_start:"Auto Start",
-        auto_start_desc:"Start server when app launches",reset_settings:"Reset Settings",
+        auto_start_desc:"Start server when app launches",restore_session:"Restore Last Session",restore_session_desc:"Restart the shares that were running when the app was closed",control_api:"Control API",control_api_desc:"Let scripts on this computer start shares through a local REST API",control_api_endpoint:"URL and token",toast_control_api_error:"Control API error",mdns:"Announce on LAN",mdns_desc:"Advertise local shares over mDNS so other devices can find them",mdns_hostname:"Also answer to justserve.local",nearby_shares:"Nearby shares",find_shares:"Find",toast_mdns_error:"LAN discovery error",metrics:"Prometheus Metrics",metrics_desc:"Serve request, traffic and transfer counters at /metrics on this computer only",metrics_port:"Port",toast_metrics_error:"Metrics error",graceful_stop:"Let Transfers Finish",graceful_stop_desc:"On stop, refuse new downloads and wait for the ones in progress",drain_timeout:"Wait at most (seconds)",draining:"Stopping after transfers in progress",draining_idle:"No transfers left, stopping...",stop_now:"Stop Now",p2p_draining:"Stopping after this download",toast_p2p_stopped:"P2P session stopped.",connections:"Connected Clients",no_connections:"No clients connected",conn_idle:"Idle",disconnect:"Disconnect",toast_connection_killed:"Client disconnected",toast_failed_disconnect:"Failed to disconnect",port_fallback:"Use the next free port if it is busy",toast_port_fallback:"Port busy, switched",auto_stop:"Auto-stop",auto_stop_never:"Never",auto_stop_1h:"After 1 hour",auto_stop_24h:"After 24 hours",auto_stop_7d:"After 7 days",auto_stop_custom:"At a set time...",max_downloads:"Max downloads (no limit)",downloads:"downloads",toast_share_expired:"Share expired, stopping",toast_download_limit:"Download limit reached, stopping",download_stats:"Downloads",stats_clients:"clients",stats_last:"last at",stats_export:"Save Download Stats",stats_export_desc:"When a share stops, save its downloads per file (count, clients, bytes, last access)",stats_export_off:"Off",stats_dir_default:"Downloads/JustServe",stats_dir_choose:"Choose",stats_dir_reset:"Use the default folder",toast_stats_saved:"Download stats saved to",toast_stats_error:"Could not save download stats",network_interface:"Network Interface",all_interfaces:"All interfaces",iface_ethernet:"Ethernet",iface_wifi:"Wi-Fi",iface_vpn:"VPN",iface_virtual:"Virtual",iface_other:"Other",ngrok_token_saved:"Saved in the encrypted vault - type to replace",vault_passphrase:"Vault Passphrase",vault_passphrase_desc:"Secrets are encrypted with a key tied to this computer. Set a passphrase for extra protection, or save it empty to go back to the machine key.",vault_locked:"The secrets vault is locked. Enter its passphrase to use your saved token and passwords.",vault_unlock:"Unlock",vault_set_passphrase:"Set",toast_vault_unlocked:"Secrets vault unlocked",toast_vault_passphrase_set:"Vault passphrase updated",toast_vault_error:"Secrets vault error",reset_settings:"Reset Settings",
         reset_settings_desc:"Return to default values",reset:"Reset",help:"Help",system_logs:"System Logs",
         active_connection:"Active Connection",copy_url:"Copy URL",open_browser:"Open in Browser",
         desc_serve:"Host files and folders on your local network or public internet.",
@@ -35,9 +35,10 @@ export const translations = {
         update_available:"Update Available",current_version:"Current Version",check_for_updates:"Check for Updates",
         toast_downloading:"Downloading & installing update...", toast_updating:"Update applied! Restarting...",
         toast_update_failed:"Update failed", toast_p2p_downloading:"Someone is downloading your file!",
-        toast_p2p_completed:"Transfer completed successfully!", toast_p2p_error:"P2P Error",
+        toast_p2p_completed:"Transfer completed successfully!", toast_p2p_interrupted:"Download interrupted, waiting for the receiver", toast_p2p_error:"P2P Error",
+        err_not_found:"The file or folder no longer exists", err_permission_denied:"Permission denied", err_port_in_use:"The port is already in use", err_unreachable:"Could not reach the other computer", err_ngrok_auth:"ngrok rejected the auth token", err_tunnel_failed:"Could not open the ngrok tunnel", err_vault_locked:"The secrets vault is locked", err_vault_wrong_key:"Wrong vault passphrase", err_secret_not_found:"Saved secret not found", err_session_not_found:"The share is no longer running", err_connection_not_found:"The client has already disconnected", err_no_sender:"No sender found with that code", err_transfer_corrupted:"The transfer was corrupted, please try again",err_expired:"The expiry time has already passed",
         toast_server_started:"Server started!", toast_server_stopped:"Server stopped.",
-        toast_server_error:"Server Error", toast_proxy_started:"Proxy started at",
+        toast_server_error:"Server Error",toast_tunnel_disconnected:"Lost connection to ngrok, reconnecting...",toast_tunnel_reconnected:"Reconnected to ngrok", toast_upload_rejected:"Upload rejected", toast_proxy_started:"Proxy started at",
         toast_copied:"Copied!", toast_select_content:"Please select content first.",
         toast_select_sender:"Please enter a sender address.", toast_missing_token:"Ngrok Token is required",
         toast_no_peers:"No peers found on the network.", toast_found_peers:"Found peer(s)!"
@@ -61,7 +62,7 @@ export const translations = {
         ngrok_token_placeholder:"ใส่ Ngrok Authtoken ของคุณ...",
         ngrok_token_help:"Token นี้จำเป็นสำหรับการเปิดเผยบริการของคุณสู่อินเทอร์เน็ตสาธารณะ คุณสามารถรับ token ได้ที่ ngrok.com",
         advanced:"ขั้นสูง",advanced_desc:"ตัวเลือกสำหรับผู้ใช้ขั้นสูง",auto_start:"เริ่มต้นอัตโนมัติ",
-        auto_start_desc:"เปิดเซิร์ฟเวอร์เมื่อเปิดแอป",reset_settings:"รีเซ็ตการตั้งค่า",
+        auto_start_desc:"เปิดเซิร์ฟเวอร์เมื่อเปิดแอป",restore_session:"กู้คืนเซสชันล่าสุด",restore_session_desc:"เปิดการแชร์ที่ทำงานอยู่ตอนปิดแอปอีกครั้ง",control_api:"Control API",control_api_desc:"ให้สคริปต์บนเครื่องนี้เริ่มการแชร์ผ่าน REST API ภายในเครื่อง",control_api_endpoint:"URL และโทเค็น",toast_control_api_error:"ข้อผิดพลาดของ Control API",mdns:"ประกาศบน LAN",mdns_desc:"ประกาศการแชร์ภายในผ่าน mDNS เพื่อให้อุปกรณ์อื่นค้นหาได้",mdns_hostname:"ตอบชื่อ justserve.local ด้วย",nearby_shares:"การแชร์ใกล้เคียง",find_shares:"ค้นหา",toast_mdns_error:"ข้อผิดพลาดการค้นหาบน LAN",metrics:"Prometheus Metrics",metrics_desc:"ให้บริการตัวนับคำขอ ปริมาณข้อมูล และการโอนที่ /metrics เฉพาะบนเครื่องนี้",metrics_port:"พอร์ต",toast_metrics_error:"ข้อผิดพลาดของ Metrics",graceful_stop:"รอให้การโอนเสร็จก่อน",graceful_stop_desc:"เมื่อหยุด จะปฏิเสธการดาวน์โหลดใหม่และรอรายการที่กำลังดำเนินอยู่",drain_timeout:"รอสูงสุด (วินาที)",draining:"จะหยุดหลังการโอนที่กำลังดำเนินอยู่",draining_idle:"ไม่มีการโอนเหลือแล้ว กำลังหยุด...",stop_now:"หยุดทันที",p2p_draining:"จะหยุดหลังการดาวน์โหลดนี้",toast_p2p_stopped:"หยุดเซสชัน P2P แล้ว",connections:"ไคลเอนต์ที่เชื่อมต่อ",no_connections:"ไม่มีไคลเอนต์เชื่อมต่อ",conn_idle:"ว่าง",disconnect:"ตัดการเชื่อมต่อ",toast_connection_killed:"ตัดการเชื่อมต่อไคลเอนต์แล้ว",toast_failed_disconnect:"ตัดการเชื่อมต่อไม่สำเร็จ",port_fallback:"ใช้พอร์ตว่างถัดไปหากพอร์ตนี้ไม่ว่าง",toast_port_fallback:"พอร์ตไม่ว่าง เปลี่ยนพอร์ต",auto_stop:"หยุดอัตโนมัติ",auto_stop_never:"ไม่หยุด",auto_stop_1h:"หลัง 1 ชั่วโมง",auto_stop_24h:"หลัง 24 ชั่วโมง",auto_stop_7d:"หลัง 7 วัน",auto_stop_custom:"ตามเวลาที่กำหนด...",max_downloads:"ดาวน์โหลดสูงสุด (ไม่จำกัด)",downloads:"ครั้ง",toast_share_expired:"การแชร์หมดอายุ กำลังหยุด",toast_download_limit:"ถึงจำนวนดาวน์โหลดสูงสุดแล้ว กำลังหยุด",download_stats:"การดาวน์โหลด",stats_clients:"ไคลเอนต์",stats_last:"ล่าสุดเมื่อ",stats_export:"บันทึกสถิติการดาวน์โหลด",stats_export_desc:"เมื่อการแชร์หยุด บันทึกการดาวน์โหลดของแต่ละไฟล์ (จำนวน ไคลเอนต์ ไบต์ และเวลาล่าสุด)",stats_export_off:"ปิด",stats_dir_default:"Downloads/JustServe",stats_dir_choose:"เลือก",stats_dir_reset:"ใช้โฟลเดอร์เริ่มต้น",toast_stats_saved:"บันทึกสถิติการดาวน์โหลดไว้ที่",toast_stats_error:"บันทึกสถิติการดาวน์โหลดไม่สำเร็จ",network_interface:"อินเทอร์เฟซเครือข่าย",all_interfaces:"ทุกอินเทอร์เฟซ",iface_ethernet:"อีเทอร์เน็ต",iface_wifi:"Wi-Fi",iface_vpn:"VPN",iface_virtual:"เสมือน",iface_other:"อื่นๆ",ngrok_token_saved:"บันทึกในห้องนิรภัยที่เข้ารหัสแล้ว - พิมพ์เพื่อแทนที่",vault_passphrase:"รหัสผ่านห้องนิรภัย",vault_passphrase_desc:"ข้อมูลลับถูกเข้ารหัสด้วยคีย์ที่ผูกกับเครื่องนี้ ตั้งรหัสผ่านเพื่อป้องกันเพิ่ม หรือบันทึกค่าว่างเพื่อกลับไปใช้คีย์ของเครื่อง",vault_locked:"ห้องนิรภัยถูกล็อก ใส่รหัสผ่านเพื่อใช้โทเค็นและรหัสผ่านที่บันทึกไว้",vault_unlock:"ปลดล็อก",vault_set_passphrase:"ตั้งค่า",toast_vault_unlocked:"ปลดล็อกห้องนิรภัยแล้ว",toast_vault_passphrase_set:"อัปเดตรหัสผ่านห้องนิรภัยแล้ว",toast_vault_error:"ข้อผิดพลาดของห้องนิรภัย",reset_settings:"รีเซ็ตการตั้งค่า",
         reset_settings_desc:"กลับไปยังค่าเริ่มต้น",reset:"รีเซ็ต",help:"ช่วยเหลือ",system_logs:"บันทึกระบบ",
         active_connection:"การเชื่อมต่อที่ใช้งานอยู่",copy_url:"คัดลอก URL",open_browser:"เปิดในเบราว์เซอร์",
         desc_serve:"โฮสต์ไฟล์และโฟลเดอร์บนเครือข่ายภายในหรืออินเทอร์เน็ตสาธารณะ",
@@ -78,9 +79,10 @@ export const translations = {
         update_available:"มีอัปเดตใหม่",current_version:"เวอร์ชันปัจจุบัน",check_for_updates:"ตรวจสอบอัปเดต",
         toast_downloading:"กำลังดาวน์โหลดและติดตั้งอัปเดต...", toast_updating:"อัปเดตเสร็จสิ้น! กำลังรีสตาร์ท...",
         toast_update_failed:"การอัปเดตล้มเหลว", toast_p2p_downloading:"มีคนกำลังดาวน์โหลดไฟล์ของคุณ!",
-        toast_p2p_completed:"การโอนย้ายเสร็จสมบูรณ์!", toast_p2p_error:"ข้อผิดพลาด P2P",
+        toast_p2p_completed:"การโอนย้ายเสร็จสมบูรณ์!", toast_p2p_interrupted:"การดาวน์โหลดถูกขัดจังหวะ กำลังรอผู้รับ", toast_p2p_error:"ข้อผิดพลาด P2P",
+        err_not_found:"ไม่พบไฟล์หรือโฟลเดอร์แล้ว", err_permission_denied:"ไม่มีสิทธิ์เข้าถึง", err_port_in_use:"พอร์ตนี้ถูกใช้งานอยู่", err_unreachable:"ไม่สามารถเชื่อมต่อกับเครื่องปลายทางได้", err_ngrok_auth:"ngrok ปฏิเสธโทเค็น", err_tunnel_failed:"ไม่สามารถเปิดอุโมงค์ ngrok ได้", err_vault_locked:"ห้องนิรภัยถูกล็อก", err_vault_wrong_key:"รหัสผ่านห้องนิรภัยไม่ถูกต้อง", err_secret_not_found:"ไม่พบข้อมูลลับที่บันทึกไว้", err_session_not_found:"การแชร์นี้หยุดทำงานแล้ว", err_connection_not_found:"ไคลเอนต์ตัดการเชื่อมต่อไปแล้ว", err_no_sender:"ไม่พบผู้ส่งที่ใช้รหัสนี้", err_transfer_corrupted:"ข้อมูลที่โอนเสียหาย กรุณาลองใหม่",err_expired:"เวลาหมดอายุผ่านไปแล้ว",
         toast_server_started:"เริ่มเซิร์ฟเวอร์แล้ว!", toast_server_stopped:"หยุดเซิร์ฟเวอร์แล้ว",
-        toast_server_error:"ข้อผิดพลาดเซิร์ฟเวอร์", toast_proxy_started:"เริ่ม Proxy ที่",
+        toast_server_error:"ข้อผิดพลาดเซิร์ฟเวอร์",toast_tunnel_disconnected:"การเชื่อมต่อกับ ngrok ขาดหาย กำลังเชื่อมต่อใหม่...",toast_tunnel_reconnected:"เชื่อมต่อกับ ngrok อีกครั้งแล้ว", toast_upload_rejected:"ปฏิเสธการอัปโหลด", toast_proxy_started:"เริ่ม Proxy ที่",
         toast_copied:"คัดลอกแล้ว!", toast_select_content:"กรุณาเลือกเนื้อหาก่อน",
         toast_select_sender:"กรุณาใส่ที่อยู่ผู้ส่ง", toast_missing_token:"จำเป็นต้องใช้ Ngrok Token",
         toast_no_peers:"ไม่พบอุปกรณ์ในเครือข่าย", toast_found_peers:"พบอุปกรณ์!",
@@ -107,7 +109,7 @@ export const translations = {
         ngrok_token_placeholder:"输入您的 Ngrok Authtoken...",
         ngrok_token_help:"此令牌是将您的服务暴露到公共互联网所必需的。您可以在 ngrok.com 获取令牌",
         advanced:"高级",advanced_desc:"高级用户选项",auto_start:"自动启动",
-        auto_start_desc:"应用启动时启动服务器",reset_settings:"重置设置",
+        auto_start_desc:"应用启动时启动服务器",restore_session:"恢复上次会话",restore_session_desc:"重新启动关闭应用时正在运行的共享",control_api:"控制 API",control_api_desc:"允许本机脚本通过本地 REST API 启动共享",control_api_endpoint:"URL 和令牌",toast_control_api_error:"控制 API 错误",mdns:"在局域网中广播",mdns_desc:"通过 mDNS 广播本地共享，方便其他设备发现",mdns_hostname:"同时响应 justserve.local",nearby_shares:"附近的共享",find_shares:"查找",toast_mdns_error:"局域网发现错误",metrics:"Prometheus 指标",metrics_desc:"仅在本机的 /metrics 提供请求、流量和传输计数",metrics_port:"端口",toast_metrics_error:"指标服务错误",graceful_stop:"等待传输完成",graceful_stop_desc:"停止时拒绝新的下载，并等待进行中的下载完成",drain_timeout:"最长等待（秒）",draining:"将在进行中的传输完成后停止",draining_idle:"没有剩余传输，正在停止...",stop_now:"立即停止",p2p_draining:"将在本次下载完成后停止",toast_p2p_stopped:"P2P 会话已停止",connections:"已连接的客户端",no_connections:"没有客户端连接",conn_idle:"空闲",disconnect:"断开连接",toast_connection_killed:"已断开客户端",toast_failed_disconnect:"断开连接失败",port_fallback:"端口被占用时使用下一个空闲端口",toast_port_fallback:"端口被占用，已切换",auto_stop:"自动停止",auto_stop_never:"从不",auto_stop_1h:"1 小时后",auto_stop_24h:"24 小时后",auto_stop_7d:"7 天后",auto_stop_custom:"指定时间...",max_downloads:"最大下载次数（不限）",downloads:"次下载",toast_share_expired:"共享已到期，正在停止",toast_download_limit:"已达到下载次数上限，正在停止",download_stats:"下载",stats_clients:"个客户端",stats_last:"最近于",stats_export:"保存下载统计",stats_export_desc:"共享停止时，按文件保存下载情况（次数、客户端、字节数、最近访问时间）",stats_export_off:"关闭",stats_dir_default:"Downloads/JustServe",stats_dir_choose:"选择",stats_dir_reset:"使用默认文件夹",toast_stats_saved:"下载统计已保存到",toast_stats_error:"无法保存下载统计",network_interface:"网络接口",all_interfaces:"所有接口",iface_ethernet:"以太网",iface_wifi:"Wi-Fi",iface_vpn:"VPN",iface_virtual:"虚拟",iface_other:"其他",ngrok_token_saved:"已保存在加密保险库中 - 输入以替换",vault_passphrase:"保险库密码",vault_passphrase_desc:"机密使用与本机绑定的密钥加密。可设置密码以增强保护，留空保存则恢复使用本机密钥。",vault_locked:"机密保险库已锁定。输入密码以使用已保存的令牌和密码。",vault_unlock:"解锁",vault_set_passphrase:"设置",toast_vault_unlocked:"机密保险库已解锁",toast_vault_passphrase_set:"保险库密码已更新",toast_vault_error:"机密保险库错误",reset_settings:"重置设置",
         reset_settings_desc:"恢复默认值",reset:"重置",help:"帮助",system_logs:"系统日志",
         active_connection:"当前连接",copy_url:"复制链接",open_browser:"在浏览器打开",
         desc_serve:"在局域网或公网上托管文件和文件夹。",desc_proxy:"安全地将本地端口暴露给公共互联网。",
@@ -123,9 +125,10 @@ export const translations = {
         update_available:"有可用更新",current_version:"当前版本",check_for_updates:"检查更新",
         toast_downloading:"正在下载并安装更新...", toast_updating:"更新完成！正在重启...",
         toast_update_failed:"更新失败", toast_p2p_downloading:"有人正在下载您的文件！",
-        toast_p2p_completed:"传输成功完成！", toast_p2p_error:"P2P 错误",
+        toast_p2p_completed:"传输成功完成！", toast_p2p_interrupted:"下载已中断，正在等待接收方", toast_p2p_error:"P2P 错误",
+        err_not_found:"文件或文件夹已不存在", err_permission_denied:"权限不足", err_port_in_use:"端口已被占用", err_unreachable:"无法连接到对方电脑", err_ngrok_auth:"ngrok 拒绝了该令牌", err_tunnel_failed:"无法打开 ngrok 隧道", err_vault_locked:"机密保险库已锁定", err_vault_wrong_key:"保险库密码错误", err_secret_not_found:"未找到已保存的机密", err_session_not_found:"该共享已停止运行", err_connection_not_found:"该客户端已断开连接", err_no_sender:"未找到使用该代码的发送方", err_transfer_corrupted:"传输数据已损坏，请重试",err_expired:"到期时间已过",
         toast_server_started:"服务器已启动！", toast_server_stopped:"服务器已停止",
-        toast_server_error:"服务器错误", toast_proxy_started:"Proxy 已启动于",
+        toast_server_error:"服务器错误",toast_tunnel_disconnected:"与 ngrok 的连接已断开，正在重新连接...",toast_tunnel_reconnected:"已重新连接到 ngrok", toast_upload_rejected:"上传被拒绝", toast_proxy_started:"Proxy 已启动于",
         toast_copied:"已复制！", toast_select_content:"请先选择内容",
         toast_select_sender:"请输入发送者地址", toast_missing_token:"需要 Ngrok Token",
         toast_no_peers:"未发现网络中的设备", toast_found_peers:"发现设备！",
@@ -2,9 +2,15 @@ import { useAppStore } from './useAppStore';
 import { translations } from '../i18n';
 import {
     SelectFolder, SelectFile, StartLocalServer, StartPublicServer,
-    StopServer, GetLocalIPs, StartProxy, OpenInExplorer,
-    StartP2PSend, StopP2PTransfer, ConnectP2P, DiscoverP2PPeers,
-    CheckUpdate, InstallUpdate, GetAppVersion
+    StopSession, DrainSession, GetLocalIPs, StartProxy, OpenInExplorer,
+    StartP2PSend, StopP2PTransfer, DrainP2PTransfer, ConnectP2P, DiscoverP2PPeers, GetP2PStatus,
+    CheckUpdate, InstallUpdate, GetAppVersion,
+    LoadSettings, SaveSettings, ListSessions,
+    GetVaultStatus, UnlockVault, SetVaultPassphrase, SetSecret,
+    GetControlAPIStatus, SetControlAPIEnabled,
+    GetAdvertiseStatus, SetAdvertise, DiscoverShares,
+    GetNetworkInterfaces, GetMetricsStatus, SetMetrics,
+    ListConnections, KillConnection, GetDownloadStats
 } from '../../wailsjs/go/main/App';
 import * as runtime from '../../wailsjs/runtime/runtime';
 
@@ -12,17 +18,35 @@ import * as runtime from '../../wailsjs/runtime/runtime';
 export const formatBytes = (bytes) => {
     if (!bytes || bytes === 0) return '0 B';
     const sizes = ['B', 'KB', 'MB', 'GB'];
+    const i = Math.min(Math.floor(Math.log(bytes) / Math.log(1024)), sizes.length - 1);
     return (bytes / Math.pow(1024, i)).toFixed(2) + ' ' + sizes[i];
 };
 
 // Shorthand: always reads fresh state (no stale closure)
 const gs = () => useAppStore.getState();
 
+// How many ports after the chosen one a local share may fall back to
+const PORT_FALLBACK_RANGE = 10;
+
+// Expiry passed to shares and P2P sends: a duration, a local time, or '' for never
+const autoStopExpiry = () => {
+    const { autoStop, autoStopAt } = gs();
+    return autoStop === 'custom' ? autoStopAt : autoStop;
+};
+
 const t = (key) => {
     const lang = gs().lang || 'en';
     return translations[lang]?.[key] ?? key;
 };
 
+// Bindings reject with { version, code, message } and error events carry the
+// same fields; show the translated code when there is one
+const errorText = (err) => {
+    if (!err || typeof err !== 'object') return String(err);
+    const lang = gs().lang || 'en';
+    return translations[lang]?.['err_' + err.code] ?? err.message ?? String(err);
+};
+
 // ── useActions hook ───────────────────────────────────────────────────────────
 export const useActions = (addToast) => {
 
@@ -36,12 +60,16 @@ export const useActions = (addToast) => {
             .then(ips => gs().setLocalIPs(ips || []))
             .catch(console.error);
 
+        refreshInterfaces();
+
         GetAppVersion()
             .then(v => gs().setAppVersion(v))
             .catch(err => console.error('Failed to get version:', err));
 
         checkForUpdates(false);
 
+        loadSettings();
+
         // 🛡️ Self-Healing: Reset runtime states to avoid zombies
         if (gs().loading) gs().setLoading(false);
         if (gs().isServing) {
@@ -51,32 +79,120 @@ export const useActions = (addToast) => {
         }
 
         runtime.EventsOn('server-error', (err) => {
-            log('Error', err);
-            addToast(t('toast_server_error') + ': ' + err, 'error');
+            log('Error', err.message);
+            addToast(t('toast_server_error') + ': ' + errorText(err), 'error');
             gs().stopServerState();
         });
 
-        runtime.EventsOn('p2p-status', (status) => {
-            log('P2P', `Transfer status: ${status}`);
+        runtime.EventsOn('session-started', ({ session }) => {
+            log('Session', `${session.kind} ${session.id} started at ${session.urls.join(', ')}`);
+        });
+
+        runtime.EventsOn('session-stopped', ({ session }) => {
+            log('Session', `${session.kind} ${session.id} stopped`);
+            const ids = gs().sessionIds;
+            if (!ids.includes(session.id)) return; // Started elsewhere, e.g. through the control API
+            const left = ids.filter(id => id !== session.id);
+            gs().setSessionIds(left);
+            if (gs().draining && left.length === 0) {
+                gs().stopServerState();
+                addToast(t('toast_server_stopped'), 'info');
+            }
+        });
+
+        runtime.EventsOn('session-draining', ({ session, deadline }) => {
+            const transfers = session.transfers || [];
+            log('Session', `${session.kind} ${session.id} draining, ${transfers.length} transfer(s) in progress`);
+            if (gs().isServing) {
+                gs().setDraining({ deadline, transfers });
+                pollDraining();
+            }
+        });
+
+        runtime.EventsOn('session-expired', ({ session, reason }) => {
+            log('Session', `${session.kind} ${session.id} reached its ${reason === 'downloads' ? 'download limit' : 'expiry time'}, stopping`);
+            addToast(t(reason === 'downloads' ? 'toast_download_limit' : 'toast_share_expired'), 'info');
+        });
+
+        runtime.EventsOn('stats-exported', ({ file, paths }) => {
+            log('Stats', `Download stats of ${paths} path(s) saved to ${file}`);
+            addToast(t('toast_stats_saved') + ` ${file}`, 'success');
+        });
+
+        runtime.EventsOn('stats-export-error', (err) => {
+            log('Error', err.message);
+            addToast(t('toast_stats_error') + ': ' + errorText(err), 'error');
+        });
+
+        runtime.EventsOn('port-fallback', ({ requested, port, owner }) => {
+            const by = owner ? ` (${owner.pid ? `${owner.name}, pid ${owner.pid}` : owner.user})` : '';
+            log('Warning', `Port ${requested} is busy${by}, serving on ${port}`);
+            addToast(t('toast_port_fallback') + ` ${requested} → ${port}`, 'info');
+        });
+        runtime.EventsOn('upload-rejected', (rej) => {
+            log('Upload', `Rejected ${rej.fileName || 'upload'} (${rej.reason}): ${rej.message}`);
+            addToast(t('toast_upload_rejected') + ': ' + rej.message, 'warning');
+        });
+
+        runtime.EventsOn('proxy-error', (err) => {
+            log('Proxy', err.message);
+        });
+
+        runtime.EventsOn('tunnel-disconnected', (err) => {
+            log('Tunnel', `Connection to ngrok lost: ${err.message}`);
+            addToast(t('toast_tunnel_disconnected'), 'error');
+        });
+
+        runtime.EventsOn('tunnel-reconnected', () => {
+            log('Tunnel', 'Reconnected to ngrok');
+            addToast(t('toast_tunnel_reconnected'), 'success');
+        });
+
+        runtime.EventsOn('p2p-status', ({ status, reason }) => {
+            log('P2P', `Transfer status: ${status}${reason ? ` (${reason})` : ''}`);
             if (status === 'transferring') {
                 addToast(t('toast_p2p_downloading'), 'info');
             } else if (status === 'completed') {
                 addToast(t('toast_p2p_completed'), 'success');
                 gs().updateP2pInfoStatus('completed');
+                if (gs().p2pInfo?.maxDownloads) {
+                    // Refresh the download count shown next to the limit
+                    GetP2PStatus()
+                        .then(info => info && gs().p2pInfo && gs().setP2pInfo({ ...gs().p2pInfo, downloads: info.downloads }))
+                        .catch(() => {});
+                }
+            } else if (status === 'waiting') {
+                // The download broke off; the sender waits for a receiver again
+                addToast(t('toast_p2p_interrupted'), 'info');
+                gs().updateP2pInfoStatus('waiting');
+            } else if (status === 'expired') {
+                addToast(t(reason === 'downloads' ? 'toast_download_limit' : 'toast_share_expired'), 'info');
+            } else if (status === 'draining') {
+                gs().setP2pDraining();
+            } else if (status === 'stopped') {
+                gs().resetP2pSession();
+                addToast(t('toast_p2p_stopped'), 'info');
             }
         });
 
-        runtime.EventsOn('p2p-progress', (bytes) => {
+        runtime.EventsOn('p2p-progress', ({ bytes }) => {
             gs().setP2pProgress(bytes);
         });
 
         runtime.EventsOn('p2p-error', (err) => {
-            log('P2P Error', err);
-            addToast(t('toast_p2p_error') + ': ' + err, 'error');
+            log('P2P Error', err.message);
+            addToast(t('toast_p2p_error') + ': ' + errorText(err), 'error');
         });
 
         return () => {
             runtime.EventsOff('server-error');
+            runtime.EventsOff('upload-rejected');
+            runtime.EventsOff('session-started');
+            runtime.EventsOff('session-stopped');
+            runtime.EventsOff('session-draining');
+            runtime.EventsOff('session-expired');
+            runtime.EventsOff('stats-exported');
+            runtime.EventsOff('stats-export-error');
             runtime.EventsOff('p2p-status');
             runtime.EventsOff('p2p-progress');
             runtime.EventsOff('p2p-error');
@@ -93,7 +209,7 @@ export const useActions = (addToast) => {
                 log('Info', `Selected ${type}: ${path}`);
             }
         } catch (err) {
-            addToast(t('toast_failed_select') + ': ' + err, 'error');
+            addToast(t('toast_failed_select') + ': ' + errorText(err), 'error');
         }
     };
 
@@ -103,29 +219,32 @@ export const useActions = (addToast) => {
         try {
             await OpenInExplorer(folderPath);
         } catch (err) {
-            addToast(t('toast_failed_explorer') + ': ' + err, 'error');
+            addToast(t('toast_failed_explorer') + ': ' + errorText(err), 'error');
         }
     };
 
-    const startServer = async (retryCount = 0) => {
+    const startServer = async () => {
         gs().setLoading(true);
         gs().clearLogs();
         try {
-            log('System', retryCount === 0 ? 'Starting server...' : `Retrying start (Attempt ${retryCount + 1})...`);
+            log('System', 'Starting server...');
             await new Promise(r => setTimeout(r, 400));
 
-            const { activeTab, ngrokToken, proxyPort, proxyProtocol,
-                folderPath, serveMode, serverPort, usePassword, password, allowUpload } = gs();
+            await saveNgrokToken();
+            const { activeTab, ngrokTokenSecret, proxyPort, proxyProtocol,
+                folderPath, serveMode, serverPort, usePassword, password, passwordSecret, allowUpload, uploadLimits, bindInterface, portFallback,
+                maxDownloads } = gs();
+            const hasToken = hasNgrokToken();
 
             let url = '';
             if (activeTab === 'proxy') {
                  // ... proxy logic (omitted for brevity, keep existing) ...
-                if (!ngrokToken) {
+                if (!hasToken) {
                     addToast(t('toast_missing_token'), 'error');
                     log('Error', 'Missing Ngrok Token');
                     return;
                 }
-                url = await StartProxy(ngrokToken, proxyPort, proxyProtocol);
+                url = await StartProxy(ngrokTokenSecret, proxyPort, proxyProtocol);
                 log('Success', `Proxy started at ${url}`);
                 addToast(t('toast_proxy_started') + ` ${url}`, 'success');
             } else {
@@ -134,61 +253,72 @@ export const useActions = (addToast) => {
                     return;
                 }
                 const pwd = usePassword ? password : '';
-                
-                try {
-                    if (serveMode === 'local') {
-                        url = await StartLocalServer(serverPort, folderPath, pwd, allowUpload);
-                        log('Success', `Local server started at ${url}`);
-                    } else {
-                         // ... public logic ...
-                        if (!ngrokToken) {
-                            addToast(t('toast_missing_token'), 'error');
-                            log('Error', 'Missing Ngrok Token');
-                            return;
-                        }
-                        url = await StartPublicServer(ngrokToken, folderPath, pwd, allowUpload);
-                        log('Success', `Public server started at ${url}`);
-                    }
-                    addToast(t('toast_server_started'), 'success');
-                } catch (e) {
-                    // 🛡️ Self-Healing: Port Conflict Auto-Fix
-                    if (e.toString().includes('address already in use') && retryCount < 3) {
-                        log('Warning', `Port ${serverPort} matches conflict. Self-healing: Switching to ${parseInt(serverPort) + 1}...`);
-                        addToast(`Port ${serverPort} busy. Auto-switching to ${parseInt(serverPort) + 1}...`, 'warning');
-                        gs().setServerPort((parseInt(serverPort) + 1).toString());
-                        // Recursive retry with backoff
-                        setTimeout(() => startServer(retryCount + 1), 1000);
-                        return; // Exit this execution, next one is scheduled
+                // An empty password field falls back to the saved default password
+                const shareOptions = {
+                    upload: uploadLimits,
+                    passwordSecret: usePassword && !password ? passwordSecret : '',
+                    interface: serveMode === 'local' ? bindInterface : '', // Tunnels always listen on loopback
+                    portFallback: portFallback ? PORT_FALLBACK_RANGE : 0,
+                    expiry: autoStopExpiry(),
+                    maxDownloads: maxDownloads || 0,
+                };
+
+                if (serveMode === 'local') {
+                    // A busy port falls back to a following one (see 'port-fallback');
+                    // otherwise the error names the process holding it
+                    url = await StartLocalServer(serverPort, folderPath, pwd, allowUpload, shareOptions);
+                    log('Success', `Local server started at ${url}`);
+                } else {
+                    if (!hasToken) {
+                        addToast(t('toast_missing_token'), 'error');
+                        log('Error', 'Missing Ngrok Token');
+                        return;
                     }
-                    throw e; // Propagate other errors
+                    url = await StartPublicServer(ngrokTokenSecret, folderPath, pwd, allowUpload, shareOptions);
+                    log('Success', `Public server started at ${url}`);
                 }
+                addToast(t('toast_server_started'), 'success');
             }
+            // Stop acts on this session alone, not on others running alongside
+            const started = (await ListSessions() || []).find(s => (s.urls || []).includes(url));
+            gs().setSessionIds(started ? [started.id] : []);
             gs().setServerUrl(url);
             gs().setIsServing(true);
             gs().setLoading(false); // Success path
+            saveSettings();
+            pollConnections();
         } catch (err) {
-            // Check if we already handled retry (returned early)
-            if (gs().loading && retryCount < 3 && err.toString().includes('address already in use')) {
-                 // Do not set loading false, just exit. The next attempt is running.
-                 return;
-            }
-            log('Error', `Failed to start: ${err}`);
-            addToast('Failed: ' + err, 'error');
+            // The log keeps the full message, e.g. which process holds a busy port
+            log('Error', `Failed to start: ${err?.message ?? errorText(err)}`);
+            addToast('Failed: ' + errorText(err), 'error');
             gs().setLoading(false); // Failure path
         }
     };
 
-    const stopServer = async () => {
+    // Drains instead when the stop mode says so; mode 'immediate' cuts a
+    // draining stop short
+    const stopServer = async (mode = gs().stopMode) => {
+        if (mode === 'drain') {
+            if (gs().draining) return;
+            try {
+                await Promise.all(gs().sessionIds.map(id => DrainSession(id, gs().drainTimeout)));
+                log('System', `Stopping once transfers are done (at most ${gs().drainTimeout}s)...`);
+            } catch (err) {
+                addToast(t('toast_failed_stop') + ': ' + errorText(err), 'error');
+            }
+            return;
+        }
         gs().setLoading(true);
         try {
-            await StopServer();
+            // A session that already stopped (e.g. expired) is gone from the list
+            await Promise.all(gs().sessionIds.map(id => StopSession(id).catch(() => {})));
             log('System', 'Server stopped.');
             await new Promise(r => setTimeout(r, 300));
             gs().stopServerState();
             addToast(t('toast_server_stopped'), 'info');
         } catch (err) {
-            addToast(t('toast_failed_stop') + ': ' + err, 'error');
-            log('Error', `Failed to stop: ${err}`);
+            addToast(t('toast_failed_stop') + ': ' + errorText(err), 'error');
+            log('Error', `Failed to stop: ${errorText(err)}`);
         } finally {
             gs().setLoading(false);
         }
@@ -220,7 +350,7 @@ export const useActions = (addToast) => {
                 addToast(t('toast_latest_version'), 'success');
             }
         } catch (e) {
-            if (isManual) addToast(t('toast_check_failed') + ': ' + e, 'error');
+            if (isManual) addToast(t('toast_check_failed') + ': ' + errorText(e), 'error');
         } finally {
             if (isManual) gs().setLoading(false);
         }
@@ -236,7 +366,7 @@ export const useActions = (addToast) => {
             // App will auto-restart — show final message briefly
             addToast(t('toast_updating'), 'success');
         } catch (e) {
-            addToast(t('toast_update_failed') + `: ` + e, 'error');
+            addToast(t('toast_update_failed') + `: ` + errorText(e), 'error');
             gs().setLoading(false);
         }
         // Note: setLoading(false) is intentionally omitted on success
@@ -253,42 +383,107 @@ export const useActions = (addToast) => {
                 log('P2P', `Selected ${type}: ${path}`);
             }
         } catch (err) {
-            addToast(t('toast_failed_select') + ': ' + err, 'error');
+            addToast(t('toast_failed_select') + ': ' + errorText(err), 'error');
         }
     };
 
     const startP2PSend = async () => {
-        const { p2pSendPath } = gs();
+        const { p2pSendPath, bindInterface, maxDownloads } = gs();
         if (!p2pSendPath) {
             addToast(t('toast_select_content'), 'error');
             return;
         }
         gs().setLoading(true);
         try {
-            const result = await StartP2PSend(p2pSendPath);
-            const info = JSON.parse(result);
+            const info = await StartP2PSend(p2pSendPath, bindInterface, autoStopExpiry(), maxDownloads || 0);
             gs().setP2pInfo(info);
             gs().setP2pActive(true);
             gs().setP2pProgress(0);
             log('P2P', `Sharing started! Code: ${info.code}`);
             addToast(t('toast_sharing_ready') + ` ${info.code}`, 'success');
         } catch (err) {
-            log('P2P Error', err.toString());
-            addToast('Failed to start P2P: ' + err, 'error');
+            log('P2P Error', errorText(err));
+            addToast('Failed to start P2P: ' + errorText(err), 'error');
         } finally {
             gs().setLoading(false);
         }
     };
 
-    const stopP2P = async () => {
+    // Refreshes the transfers of draining sessions until they have stopped
+    const pollDraining = () => {
+        const timer = setInterval(async () => {
+            if (!gs().draining) {
+                clearInterval(timer);
+                return;
+            }
+            try {
+                const sessions = (await ListSessions() || []).filter(s => s.draining);
+                if (gs().draining) gs().setDraining({ ...gs().draining, transfers: sessions.flatMap(s => s.transfers || []) });
+            } catch (err) {
+                clearInterval(timer);
+            }
+        }, 1000);
+    };
+
+    // Refreshes the live connections and download stats of the running
+    // sessions while serving
+    const pollConnections = () => {
+        const refresh = async () => {
+            try {
+                const sessions = await ListSessions() || [];
+                const lists = await Promise.all(sessions.map(async s =>
+                    (await ListConnections(s.id) || []).map(c => ({ ...c, sessionId: s.id }))));
+                const stats = await Promise.all(sessions.filter(s => s.kind === 'share').map(async s =>
+                    (await GetDownloadStats(s.id) || []).map(f => ({ ...f, sessionId: s.id }))));
+                if (gs().isServing) {
+                    gs().setConnections(lists.flat());
+                    gs().setDownloadStats(stats.flat());
+                }
+            } catch (err) {
+                // The session may have stopped in between; the next tick catches up
+            }
+        };
+        refresh();
+        const timer = setInterval(() => {
+            if (!gs().isServing) {
+                clearInterval(timer);
+                return;
+            }
+            refresh();
+        }, 2000);
+    };
+
+    // Cuts off one client, ending whatever it is downloading
+    const killConnection = async (sessionId, id) => {
+        try {
+            await KillConnection(sessionId, id);
+            gs().setConnections(gs().connections.filter(c => !(c.sessionId === sessionId && c.id === id)));
+            addToast(t('toast_connection_killed'), 'info');
+        } catch (err) {
+            addToast(t('toast_failed_disconnect') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    const stopP2P = async (mode = gs().stopMode) => {
+        const info = gs().p2pInfo;
+        if (mode === 'drain' && info?.status === 'transferring') {
+            if (info.draining) return;
+            try {
+                await DrainP2PTransfer(gs().drainTimeout);
+                log('P2P', 'Stopping once the download in progress is done...');
+            } catch (err) {
+                addToast(t('toast_failed_stop') + ': ' + errorText(err), 'error');
+            }
+            return;
+        }
         gs().setLoading(true);
         try {
             await StopP2PTransfer();
             gs().resetP2pSession();
             log('P2P', 'Transfer session stopped.');
-            addToast('P2P session stopped.', 'info');
+            addToast(t('toast_p2p_stopped'), 'info');
         } catch (err) {
-            addToast(t('toast_failed_stop') + ': ' + err, 'error');
+            addToast(t('toast_failed_stop') + ': ' + errorText(err), 'error');
         } finally {
             gs().setLoading(false);
         }
@@ -302,14 +497,13 @@ export const useActions = (addToast) => {
         }
         gs().setLoading(true);
         try {
-            const result = await ConnectP2P(target);
-            const info = JSON.parse(result);
+            const info = await ConnectP2P(target);
             gs().setP2pPeerInfo({ ...info, url: target });
             log('P2P', `Connected to peer: ${info.fileName}`);
             addToast(`${t('toast_found_peers')}: ${info.fileName}`, 'success');
         } catch (err) {
-            log('P2P Error', err.toString());
-            addToast('Cannot connect: ' + err, 'error');
+            log('P2P Error', errorText(err));
+            addToast('Cannot connect: ' + errorText(err), 'error');
         } finally {
             gs().setLoading(false);
         }
@@ -319,23 +513,219 @@ export const useActions = (addToast) => {
         gs().setP2pDiscovering(true);
         gs().setP2pPeers([]);
         try {
-            const result = await DiscoverP2PPeers(5);
-            const peers = JSON.parse(result);
+            const peers = await DiscoverP2PPeers(5);
             gs().setP2pPeers(peers || []);
             if (!peers?.length) addToast(t('toast_no_peers'), 'info');
             else addToast(t('toast_found_peers').replace('(s)', '') + ` (${peers.length})`, 'success');
         } catch (err) {
-            addToast('Discovery failed: ' + err, 'error');
+            addToast('Discovery failed: ' + errorText(err), 'error');
         } finally {
             gs().setP2pDiscovering(false);
         }
     };
 
+    // ── Network interfaces ───────────────────────────────────────────────────
+    const refreshInterfaces = async () => {
+        try {
+            const list = await GetNetworkInterfaces() || [];
+            gs().setNetworkInterfaces(list);
+            // An adapter saved last time may be gone (VPN disconnected, dock unplugged)
+            const { bindInterface } = gs();
+            if (bindInterface && !list.some(i => i.name === bindInterface)) {
+                gs().setBindInterface('');
+            }
+        } catch (err) {
+            console.error('Failed to list network interfaces:', err);
+        }
+    };
+
+    // ── Settings ─────────────────────────────────────────────────────────────
+    const loadSettings = async () => {
+        try {
+            gs().applySettings(await LoadSettings());
+            await refreshVault();
+            gs().setControlApi(await GetControlAPIStatus());
+            gs().setAdvertise(await GetAdvertiseStatus());
+            gs().setMetrics(await GetMetricsStatus());
+            // Tokens kept in local storage by older versions move to the vault
+            await saveNgrokToken();
+            try { localStorage.removeItem('ngrok_token'); } catch { /* ignore */ }
+            // Shares restored from the last session are already running
+            const shares = (await ListSessions() || []).filter(s => s.kind === 'share');
+            if (shares.length > 0) {
+                gs().setSessionIds(shares.map(s => s.id));
+                gs().setServerUrl(shares[0].urls[0]);
+                gs().setIsServing(true);
+                pollConnections();
+                log('Session', `Restored ${shares.length} share(s) from the last session`);
+            }
+        } catch (err) {
+            log('Error', `Failed to load settings: ${errorText(err)}`);
+        }
+    };
+
+    const saveSettings = async () => {
+        const { serverPort, usePassword, password, allowUpload, uploadLimits,
+            ngrokTokenSecret, restoreLastSession, presets, statsExport, statsDir } = gs();
+        try {
+            let { passwordSecret } = gs();
+            if (!usePassword) {
+                passwordSecret = '';
+            } else if (password) {
+                passwordSecret = 'default-password';
+                await SetSecret(passwordSecret, password);
+            }
+            await SaveSettings({
+                version: 0,
+                defaultPort: serverPort,
+                passwordSecret,
+                allowUpload,
+                upload: uploadLimits,
+                ngrokTokenSecret,
+                restoreLastSession,
+                presets,
+                statsExport,
+                statsDir,
+                recentPaths: [],
+                lastSession: [],
+            });
+        } catch (err) {
+            log('Error', `Failed to save settings: ${errorText(err)}`);
+        }
+    };
+
+    // ── Secrets vault ────────────────────────────────────────────────────────
+    // Secret values only ever travel from the UI to Go, never back.
+    const refreshVault = async () => {
+        try {
+            gs().setVaultStatus(await GetVaultStatus());
+        } catch (err) {
+            log('Error', `Secrets vault unavailable: ${errorText(err)}`);
+        }
+    };
+
+    const hasNgrokToken = () => {
+        const { vaultStatus, ngrokTokenSecret } = gs();
+        return !!vaultStatus?.names?.includes(ngrokTokenSecret);
+    };
+
+    const saveNgrokToken = async () => {
+        const { ngrokToken, ngrokTokenSecret } = gs();
+        if (!ngrokToken) return;
+        try {
+            await SetSecret(ngrokTokenSecret, ngrokToken);
+            gs().setNgrokToken('');
+            await refreshVault();
+            log('System', 'Ngrok token saved to the secrets vault');
+        } catch (err) {
+            log('Error', `Failed to save ngrok token: ${errorText(err)}`);
+            addToast(t('toast_vault_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    const unlockVault = async (passphrase) => {
+        try {
+            await UnlockVault(passphrase);
+            await refreshVault();
+            await saveNgrokToken();
+            addToast(t('toast_vault_unlocked'), 'success');
+        } catch (err) {
+            addToast(t('toast_vault_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    const setVaultPassphrase = async (passphrase) => {
+        try {
+            await SetVaultPassphrase(passphrase);
+            await refreshVault();
+            addToast(t('toast_vault_passphrase_set'), 'success');
+        } catch (err) {
+            addToast(t('toast_vault_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    // ── Control API ──────────────────────────────────────────────────────────
+    // The bearer token stays in the endpoint file; the UI only shows where it is.
+    const setControlApiEnabled = async (enabled) => {
+        try {
+            const status = await SetControlAPIEnabled(enabled);
+            gs().setControlApi(status);
+            if (status.running) log('System', `Control API listening on ${status.url}`);
+        } catch (err) {
+            gs().setControlApi(await GetControlAPIStatus());
+            addToast(t('toast_control_api_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    // ── Prometheus metrics ───────────────────────────────────────────────────
+    const setMetrics = async (enabled, port) => {
+        try {
+            const status = await SetMetrics(enabled, port || 0);
+            gs().setMetrics(status);
+            if (status.running) log('System', `Metrics served at ${status.url}`);
+        } catch (err) {
+            gs().setMetrics(await GetMetricsStatus());
+            addToast(t('toast_metrics_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    // ── LAN Discovery (mDNS) ─────────────────────────────────────────────────
+    const setAdvertise = async (enabled, hostname) => {
+        try {
+            const status = await SetAdvertise(enabled, hostname);
+            gs().setAdvertise(status);
+            if (status.running) log('System', `Advertising local shares as ${status.host}`);
+            if (status.error) addToast(status.error, 'info');
+        } catch (err) {
+            gs().setAdvertise(await GetAdvertiseStatus());
+            addToast(t('toast_mdns_error') + ': ' + errorText(err), 'error');
+        }
+    };
+
+    const discoverShares = async () => {
+        gs().setDiscoveringShares(true);
+        try {
+            gs().setNearbyShares(await DiscoverShares(3) || []);
+        } catch (err) {
+            addToast(t('toast_mdns_error') + ': ' + errorText(err), 'error');
+        } finally {
+            gs().setDiscoveringShares(false);
+        }
+    };
+
+    const setRestoreLastSession = (v) => {
+        gs().setRestoreLastSession(v);
+        saveSettings();
+    };
+
+    const setStatsExport = (format) => {
+        gs().setStatsExport(format);
+        saveSettings();
+    };
+
+    // '' goes back to the default folder
+    const setStatsDir = (dir) => {
+        gs().setStatsDir(dir);
+        saveSettings();
+    };
+
+    const selectStatsDir = async () => {
+        try {
+            const dir = await SelectFolder();
+            if (dir) setStatsDir(dir);
+        } catch (err) {
+            addToast(t('toast_failed_select') + ': ' + errorText(err), 'error');
+        }
+    };
+
     return {
-        init, log,
-        handleSelectContent, openInExplorer, startServer, stopServer,
+        init, log, saveSettings, setRestoreLastSession, setControlApiEnabled,
+        setStatsExport, setStatsDir, selectStatsDir, setAdvertise, discoverShares, setMetrics,
+        saveNgrokToken, unlockVault, setVaultPassphrase,
+        handleSelectContent, openInExplorer, startServer, stopServer, killConnection,
         copyToClipboard, openUrl,
         checkForUpdates, installUpdate,
         handleP2PSelectContent, startP2PSend, stopP2P, connectToPeer, discoverPeers,
+        refreshInterfaces,
     };
 };
@@ -45,31 +45,66 @@ export const useAppStore = create(
                 serveMode: 'local',          // 'local' | 'public'
                 serveType: 'folder',         // 'folder' | 'file'
                 serverPort: getLS('server_port', '8080'),
+                portFallback: true,          // Use one of the next ports when serverPort is busy
                 usePassword: false,
                 password: '',
                 allowUpload: false,
+                uploadLimits: { maxFileSize: 0, quota: 0, allowedExts: [], blockedExts: [], minFreeSpace: 0 },
                 autoStart: false,
+                autoStop: '',                // '' (never) | '1h' | '24h' | '7d' | 'custom'; also used by P2P sends
+                autoStopAt: '',              // datetime-local value when autoStop is 'custom'
+                maxDownloads: 0,             // Stop after this many completed downloads, 0 = no limit
 
                 // Server Runtime
                 isServing: false,
                 serverUrl: '',
+                sessionIds: [],              // Sessions started from this window; Stop acts on these only
 
                 setFolderPath: (path) => set({ folderPath: path }),
                 setServeMode: (mode) => set({ serveMode: mode }),
                 setServeType: (type) => set({ serveType: type }),
                 setServerPort: (port) => set({ serverPort: port }),
+                setPortFallback: (v) => set({ portFallback: v }),
                 setUsePassword: (v) => set({ usePassword: v }),
                 setPassword: (v) => set({ password: v }),
                 setAllowUpload: (v) => set({ allowUpload: v }),
+                setUploadLimits: (limits) => set((state) => ({ uploadLimits: { ...state.uploadLimits, ...limits } })),
                 setAutoStart: (v) => set({ autoStart: v }),
+                setAutoStop: (v) => set({ autoStop: v }),
+                setAutoStopAt: (v) => set({ autoStopAt: v }),
+                setMaxDownloads: (n) => set({ maxDownloads: n }),
                 clearSelection: () => set({ folderPath: '' }),
 
                 setIsServing: (v) => set({ isServing: v }),
                 setServerUrl: (url) => set({ serverUrl: url }),
+                setSessionIds: (ids) => set({ sessionIds: ids }),
+
+                // ── Connections ──────────────────────────────────────────────
+                connections: [],             // Live clients of the running sessions, each with its sessionId
+
+                setConnections: (list) => set({ connections: list }),
+
+                // ── Download Stats ───────────────────────────────────────────
+                downloadStats: [],           // Completed downloads by path of the running shares, each with its sessionId
+
+                setDownloadStats: (list) => set({ downloadStats: list }),
+
+                // ── Stop Mode ────────────────────────────────────────────────
+                stopMode: 'immediate',       // 'immediate' | 'drain'
+                drainTimeout: 30,            // Seconds a draining stop waits for transfers
+                draining: null,              // { deadline, transfers } while sessions finish their transfers
+
+                setStopMode: (mode) => set({ stopMode: mode }),
+                setDrainTimeout: (seconds) => set({ drainTimeout: seconds }),
+                setDraining: (v) => set({ draining: v }),
 
                 stopServerState: () => set({
                     isServing: false,
                     serverUrl: '',
+                    sessionIds: [],
+                    draining: null,
+                    connections: [],
+                    downloadStats: [],
                 }),
 
                 // ── Proxy Config ─────────────────────────────────────────────
@@ -80,11 +115,53 @@ export const useAppStore = create(
                 setProxyProtocol: (proto) => set({ proxyProtocol: proto }),
 
                 // ── Global / Settings ────────────────────────────────────────
-                ngrokToken: getLS('ngrok_token', ''),
+                ngrokToken: getLS('ngrok_token', ''), // Input buffer only; the token is kept in the Go-side vault
                 localIPs: [],
+                networkInterfaces: [],       // Adapters, best suited for LAN sharing first
+                bindInterface: '',           // Adapter local shares and P2P sends bind to; '' = all
 
                 setNgrokToken: (token) => set({ ngrokToken: token }),
                 setLocalIPs: (ips) => set({ localIPs: ips }),
+                setNetworkInterfaces: (list) => set({ networkInterfaces: list }),
+                setBindInterface: (name) => set({ bindInterface: name }),
+
+                // ── Saved Settings (Go-side settings.json) ───────────────────
+                restoreLastSession: false,
+                recentPaths: [],
+                presets: [],
+                passwordSecret: '',          // Vault name of the default share password
+                ngrokTokenSecret: 'ngrok',   // Vault name of the ngrok token
+                vaultStatus: null,           // { exists, locked, mode, names } - never secret values
+                controlApi: null,            // { enabled, running, url, endpointFile, error }
+                advertise: null,             // { enabled, hostname, running, host, alias, error }
+                metrics: null,               // { enabled, port, running, url, error }
+                statsExport: '',             // '' | 'csv' | 'json': save download stats when a share stops
+                statsDir: '',                // Folder of those files; '' = Downloads/JustServe
+                nearbyShares: [],            // Services found by the last mDNS browse
+                discoveringShares: false,
+
+                setRestoreLastSession: (v) => set({ restoreLastSession: v }),
+                setVaultStatus: (status) => set({ vaultStatus: status }),
+                setControlApi: (status) => set({ controlApi: status }),
+                setAdvertise: (status) => set({ advertise: status }),
+                setMetrics: (status) => set({ metrics: status }),
+                setStatsExport: (format) => set({ statsExport: format }),
+                setStatsDir: (dir) => set({ statsDir: dir }),
+                setNearbyShares: (list) => set({ nearbyShares: list }),
+                setDiscoveringShares: (v) => set({ discoveringShares: v }),
+                applySettings: (st) => set((state) => ({
+                    serverPort: st.defaultPort || state.serverPort,
+                    usePassword: !!st.passwordSecret,
+                    passwordSecret: st.passwordSecret || '',
+                    allowUpload: st.allowUpload,
+                    uploadLimits: { ...state.uploadLimits, ...(st.upload || {}) },
+                    ngrokTokenSecret: st.ngrokTokenSecret || 'ngrok',
+                    restoreLastSession: st.restoreLastSession,
+                    recentPaths: st.recentPaths || [],
+                    presets: st.presets || [],
+                    statsExport: st.statsExport || '',
+                    statsDir: st.statsDir || '',
+                })),
 
                 // ── P2P State ────────────────────────────────────────────────
                 p2pMode: 'send',             // 'send' | 'receive'
@@ -107,6 +184,10 @@ export const useAppStore = create(
                     set((state) => ({
                         p2pInfo: state.p2pInfo ? { ...state.p2pInfo, status } : null,
                     })),
+                setP2pDraining: () =>
+                    set((state) => ({
+                        p2pInfo: state.p2pInfo ? { ...state.p2pInfo, draining: true } : null,
+                    })),
                 setP2pProgress: (bytes) => set({ p2pProgress: bytes }),
                 setP2pReceiveAddress: (addr) => set({ p2pReceiveAddress: addr }),
                 setP2pPeerInfo: (info) => set({ p2pPeerInfo: info }),
@@ -156,13 +237,19 @@ export const useAppStore = create(
                 partialize: (state) => ({
                     theme: state.theme,
                     lang: state.lang,
-                    ngrokToken: state.ngrokToken,
                     serverPort: state.serverPort,
+                    portFallback: state.portFallback,
+                    autoStop: state.autoStop === 'custom' ? '' : state.autoStop, // A fixed time is only good once
+                    maxDownloads: state.maxDownloads,
+                    stopMode: state.stopMode,
+                    drainTimeout: state.drainTimeout,
                     folderPath: state.folderPath,
                     autoStart: state.autoStart,
                     proxyPort: state.proxyPort,
                     proxyProtocol: state.proxyProtocol,
+                    uploadLimits: state.uploadLimits,
                     serveMode: state.serveMode,
+                    bindInterface: state.bindInterface,
                     p2pReceiveAddress: state.p2pReceiveAddress, // Persist receiver address too
                 }),
                 // Self-healing: If state version mismatches or error occurs, migrate/reset
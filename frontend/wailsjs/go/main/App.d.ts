@@ -1,35 +1,105 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {main} from '../models';
+import {mdns} from '../models';
+import {p2p} from '../models';
+import {secrets} from '../models';
+import {server} from '../models';
+import {session} from '../models';
+import {settings} from '../models';
 import {update} from '../models';
+import {utils} from '../models';
 
 export function CheckUpdate():Promise<update.Info>;
 
-export function ConnectP2P(arg1:string):Promise<string>;
+export function ConnectP2P(arg1:string):Promise<p2p.PeerInfo>;
 
-export function DiscoverP2PPeers(arg1:number):Promise<string>;
+export function DeletePreset(arg1:string):Promise<void>;
+
+export function DeleteSecret(arg1:string):Promise<void>;
+
+export function DiscoverP2PPeers(arg1:number):Promise<Array<p2p.Peer>>;
+
+export function DiscoverShares(arg1:number):Promise<Array<mdns.Service>>;
+
+export function DrainP2PTransfer(arg1:number):Promise<void>;
+
+
+export function DrainSession(arg1:string,arg2:number):Promise<void>;
+
+export function GetAdvertiseStatus():Promise<main.AdvertiseStatus>;
 
 export function GetAppVersion():Promise<string>;
 
+export function GetControlAPIStatus():Promise<main.ControlAPIStatus>;
+
+export function GetDownloadStats(arg1:string):Promise<Array<session.FileStats>>;
+
 export function GetLocalIPs():Promise<Array<string>>;
 
-export function GetP2PStatus():Promise<string>;
+export function GetMetricsStatus():Promise<main.MetricsStatus>;
+
+export function GetNetworkInterfaces():Promise<Array<utils.NetInterface>>;
+
+export function GetP2PQRCode(arg1:string):Promise<string>;
+
+export function GetP2PStatus():Promise<p2p.TransferInfo>;
+
+export function GetQRCode(arg1:string,arg2:string):Promise<string>;
+
+export function GetVaultStatus():Promise<secrets.Status>;
 
 export function InstallUpdate(arg1:string):Promise<string>;
 
+export function KillConnection(arg1:string,arg2:string):Promise<void>;
+
+export function ListConnections(arg1:string):Promise<Array<server.Conn>>;
+
+export function ListSessions():Promise<Array<session.Info>>;
+
+export function LoadSettings():Promise<settings.Settings>;
+
+export function LockVault():Promise<void>;
+
 export function OpenInExplorer(arg1:string):Promise<void>;
 
+export function SavePreset(arg1:settings.Preset):Promise<void>;
+
+export function SaveSettings(arg1:settings.Settings):Promise<void>;
+
 export function SelectFile():Promise<string>;
 
 export function SelectFolder():Promise<string>;
 
-export function StartLocalServer(arg1:string,arg2:string,arg3:string,arg4:boolean):Promise<string>;
+export function SetAdvertise(arg1:boolean,arg2:boolean):Promise<main.AdvertiseStatus>;
+
+export function SetControlAPIEnabled(arg1:boolean):Promise<main.ControlAPIStatus>;
+
+export function SetMetrics(arg1:boolean,arg2:number):Promise<main.MetricsStatus>;
+
+export function SetSecret(arg1:string,arg2:string):Promise<void>;
 
-export function StartP2PSend(arg1:string):Promise<string>;
+export function SetVaultPassphrase(arg1:string):Promise<void>;
+
+export function StartLocalMountServer(arg1:string,arg2:Array<server.Mount>,arg3:string,arg4:main.ShareOptions):Promise<string>;
+
+export function StartLocalServer(arg1:string,arg2:string,arg3:string,arg4:boolean,arg5:main.ShareOptions):Promise<string>;
+
+export function StartP2PSend(arg1:string,arg2:string,arg3:string,arg4:number):Promise<p2p.TransferInfo>;
+
+export function StartPreset(arg1:string):Promise<string>;
 
 export function StartProxy(arg1:string,arg2:string,arg3:string):Promise<string>;
 
-export function StartPublicServer(arg1:string,arg2:string,arg3:string,arg4:boolean):Promise<string>;
+export function StartPublicMountServer(arg1:string,arg2:Array<server.Mount>,arg3:string,arg4:main.ShareOptions):Promise<string>;
+
+export function StartPublicServer(arg1:string,arg2:string,arg3:string,arg4:boolean,arg5:main.ShareOptions):Promise<string>;
+
+export function StopAll():Promise<void>;
 
 export function StopP2PTransfer():Promise<void>;
 
-export function StopServer():Promise<void>;
+
+export function StopSession(arg1:string):Promise<void>;
+
+export function UnlockVault(arg1:string):Promise<void>;
@@ -10,30 +10,110 @@ export function ConnectP2P(arg1) {
   return window['go']['main']['App']['ConnectP2P'](arg1);
 }
 
+export function DeletePreset(arg1) {
+  return window['go']['main']['App']['DeletePreset'](arg1);
+}
+
+export function DeleteSecret(arg1) {
+  return window['go']['main']['App']['DeleteSecret'](arg1);
+}
+
 export function DiscoverP2PPeers(arg1) {
   return window['go']['main']['App']['DiscoverP2PPeers'](arg1);
 }
 
+export function DiscoverShares(arg1) {
+  return window['go']['main']['App']['DiscoverShares'](arg1);
+}
+
+export function DrainP2PTransfer(arg1) {
+  return window['go']['main']['App']['DrainP2PTransfer'](arg1);
+}
+
+export function DrainSession(arg1, arg2) {
+  return window['go']['main']['App']['DrainSession'](arg1, arg2);
+}
+
+export function GetAdvertiseStatus() {
+  return window['go']['main']['App']['GetAdvertiseStatus']();
+}
+
 export function GetAppVersion() {
   return window['go']['main']['App']['GetAppVersion']();
 }
 
+export function GetControlAPIStatus() {
+  return window['go']['main']['App']['GetControlAPIStatus']();
+}
+
+export function GetDownloadStats(arg1) {
+  return window['go']['main']['App']['GetDownloadStats'](arg1);
+}
+
 export function GetLocalIPs() {
   return window['go']['main']['App']['GetLocalIPs']();
 }
 
+export function GetMetricsStatus() {
+  return window['go']['main']['App']['GetMetricsStatus']();
+}
+
+export function GetNetworkInterfaces() {
+  return window['go']['main']['App']['GetNetworkInterfaces']();
+}
+
+export function GetP2PQRCode(arg1) {
+  return window['go']['main']['App']['GetP2PQRCode'](arg1);
+}
+
 export function GetP2PStatus() {
   return window['go']['main']['App']['GetP2PStatus']();
 }
 
+export function GetQRCode(arg1, arg2) {
+  return window['go']['main']['App']['GetQRCode'](arg1, arg2);
+}
+
+export function GetVaultStatus() {
+  return window['go']['main']['App']['GetVaultStatus']();
+}
+
 export function InstallUpdate(arg1) {
   return window['go']['main']['App']['InstallUpdate'](arg1);
 }
 
+export function KillConnection(arg1, arg2) {
+  return window['go']['main']['App']['KillConnection'](arg1, arg2);
+}
+
+export function ListConnections(arg1) {
+  return window['go']['main']['App']['ListConnections'](arg1);
+}
+
+export function ListSessions() {
+  return window['go']['main']['App']['ListSessions']();
+}
+
+export function LoadSettings() {
+  return window['go']['main']['App']['LoadSettings']();
+}
+
+export function LockVault() {
+  return window['go']['main']['App']['LockVault']();
+}
+
 export function OpenInExplorer(arg1) {
   return window['go']['main']['App']['OpenInExplorer'](arg1);
 }
 
+export function SavePreset(arg1) {
+  return window['go']['main']['App']['SavePreset'](arg1);
+}
+
+export function SaveSettings(arg1) {
+  return window['go']['main']['App']['SaveSettings'](arg1);
+}
+
 export function SelectFile() {
   return window['go']['main']['App']['SelectFile']();
 }
@@ -42,26 +122,66 @@ export function SelectFolder() {
   return window['go']['main']['App']['SelectFolder']();
 }
 
-export function StartLocalServer(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['StartLocalServer'](arg1, arg2, arg3, arg4);
+export function SetAdvertise(arg1, arg2) {
+  return window['go']['main']['App']['SetAdvertise'](arg1, arg2);
+}
+
+export function SetControlAPIEnabled(arg1) {
+  return window['go']['main']['App']['SetControlAPIEnabled'](arg1);
+}
+
+export function SetMetrics(arg1, arg2) {
+  return window['go']['main']['App']['SetMetrics'](arg1, arg2);
+}
+
+export function SetSecret(arg1, arg2) {
+  return window['go']['main']['App']['SetSecret'](arg1, arg2);
+}
+
+export function SetVaultPassphrase(arg1) {
+  return window['go']['main']['App']['SetVaultPassphrase'](arg1);
+}
+
+export function StartLocalMountServer(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['StartLocalMountServer'](arg1, arg2, arg3, arg4);
+}
+
+export function StartLocalServer(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['StartLocalServer'](arg1, arg2, arg3, arg4, arg5);
 }
 
-export function StartP2PSend(arg1) {
-  return window['go']['main']['App']['StartP2PSend'](arg1);
+export function StartP2PSend(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['StartP2PSend'](arg1, arg2, arg3, arg4);
+}
+
+export function StartPreset(arg1) {
+  return window['go']['main']['App']['StartPreset'](arg1);
 }
 
 export function StartProxy(arg1, arg2, arg3) {
   return window['go']['main']['App']['StartProxy'](arg1, arg2, arg3);
 }
 
-export function StartPublicServer(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['StartPublicServer'](arg1, arg2, arg3, arg4);
+export function StartPublicMountServer(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['StartPublicMountServer'](arg1, arg2, arg3, arg4);
+}
+
+export function StartPublicServer(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['StartPublicServer'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function StopAll() {
+  return window['go']['main']['App']['StopAll']();
 }
 
 export function StopP2PTransfer() {
   return window['go']['main']['App']['StopP2PTransfer']();
 }
 
-export function StopServer() {
-  return window['go']['main']['App']['StopServer']();
+export function StopSession(arg1) {
+  return window['go']['main']['App']['StopSession'](arg1);
+}
+
+export function UnlockVault(arg1) {
+  return window['go']['main']['App']['UnlockVault'](arg1);
 }
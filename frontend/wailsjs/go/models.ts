@@ -1,3 +1,676 @@
+export namespace main {
+	
+	export class AdvertiseStatus {
+	    enabled: boolean;
+	    hostname: boolean;
+	    running: boolean;
+	    host: string;
+	    alias: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AdvertiseStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.hostname = source["hostname"];
+	        this.running = source["running"];
+	        this.host = source["host"];
+	        this.alias = source["alias"];
+	        this.error = source["error"];
+	    }
+	}
+	
+	export class ControlAPIStatus {
+	    enabled: boolean;
+	    running: boolean;
+	    url: string;
+	    endpointFile: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ControlAPIStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.running = source["running"];
+	        this.url = source["url"];
+	        this.endpointFile = source["endpointFile"];
+	        this.error = source["error"];
+	    }
+	}
+	
+	export class MetricsStatus {
+	    enabled: boolean;
+	    port: number;
+	    running: boolean;
+	    url: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetricsStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.port = source["port"];
+	        this.running = source["running"];
+	        this.url = source["url"];
+	        this.error = source["error"];
+	    }
+	}
+	
+	export class ShareOptions {
+	    upload: server.UploadLimits;
+	    passwordSecret: string;
+	    interface: string;
+	    portFallback: number;
+	    expiry: string;
+	    maxDownloads: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ShareOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.upload = this.convertValues(source["upload"], server.UploadLimits);
+	        this.passwordSecret = source["passwordSecret"];
+	        this.interface = source["interface"];
+	        this.portFallback = source["portFallback"];
+	        this.expiry = source["expiry"];
+	        this.maxDownloads = source["maxDownloads"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace mdns {
+	
+	export class Service {
+	    instance: string;
+	    host: string;
+	    port: number;
+	    addrs: string[];
+	    url: string;
+	    justServe: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Service(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.instance = source["instance"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.addrs = source["addrs"];
+	        this.url = source["url"];
+	        this.justServe = source["justServe"];
+	    }
+	}
+
+}
+
+export namespace p2p {
+	
+	export class Peer {
+	    code: string;
+	    url: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Peer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.code = source["code"];
+	        this.url = source["url"];
+	    }
+	}
+	
+	export class PeerInfo {
+	    fileName: string;
+	    fileSize: number;
+	    isDir: boolean;
+	    code: string;
+	    sha256?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PeerInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fileName = source["fileName"];
+	        this.fileSize = source["fileSize"];
+	        this.isDir = source["isDir"];
+	        this.code = source["code"];
+	        this.sha256 = source["sha256"];
+	    }
+	}
+	
+	export class TransferInfo {
+	    code: string;
+	    url: string;
+	    filePath: string;
+	    fileName: string;
+	    fileSize: number;
+	    isDir: boolean;
+	    status: string;
+	    bytesTransferred: number;
+	    sha256?: string;
+	    interface?: string;
+	    draining?: boolean;
+	    expiresAt?: any;
+	    maxDownloads?: number;
+	    downloads: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TransferInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.code = source["code"];
+	        this.url = source["url"];
+	        this.filePath = source["filePath"];
+	        this.fileName = source["fileName"];
+	        this.fileSize = source["fileSize"];
+	        this.isDir = source["isDir"];
+	        this.status = source["status"];
+	        this.bytesTransferred = source["bytesTransferred"];
+	        this.sha256 = source["sha256"];
+	        this.interface = source["interface"];
+	        this.draining = source["draining"];
+	        this.expiresAt = this.convertValues(source["expiresAt"], null);
+	        this.maxDownloads = source["maxDownloads"];
+	        this.downloads = source["downloads"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace secrets {
+	
+	export class Status {
+	    exists: boolean;
+	    locked: boolean;
+	    mode: string;
+	    names: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Status(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.exists = source["exists"];
+	        this.locked = source["locked"];
+	        this.mode = source["mode"];
+	        this.names = source["names"];
+	    }
+	}
+
+}
+
+export namespace server {
+	
+	export class Conn {
+	    id: string;
+	    remote: string;
+	    user?: string;
+	    method?: string;
+	    path?: string;
+	    active: boolean;
+	    since: any;
+	    requests: number;
+	    bytes: number;
+	    speed: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Conn(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.remote = source["remote"];
+	        this.user = source["user"];
+	        this.method = source["method"];
+	        this.path = source["path"];
+	        this.active = source["active"];
+	        this.since = this.convertValues(source["since"], null);
+	        this.requests = source["requests"];
+	        this.bytes = source["bytes"];
+	        this.speed = source["speed"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Mount {
+	    name: string;
+	    path: string;
+	    allowUpload: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Mount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.allowUpload = source["allowUpload"];
+	    }
+	}
+	
+	export class UploadLimits {
+	    maxFileSize: number;
+	    quota: number;
+	    allowedExts: string[];
+	    blockedExts: string[];
+	    minFreeSpace: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UploadLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxFileSize = source["maxFileSize"];
+	        this.quota = source["quota"];
+	        this.allowedExts = source["allowedExts"];
+	        this.blockedExts = source["blockedExts"];
+	        this.minFreeSpace = source["minFreeSpace"];
+	    }
+	}
+
+}
+
+export namespace session {
+	
+	export class FileStats {
+	    path: string;
+	    downloads: number;
+	    clients: number;
+	    bytes: number;
+	    lastAccess: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.downloads = source["downloads"];
+	        this.clients = source["clients"];
+	        this.bytes = source["bytes"];
+	        this.lastAccess = this.convertValues(source["lastAccess"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Info {
+	    id: string;
+	    kind: string;
+	    name: string;
+	    public: boolean;
+	    urls: string[];
+	    port?: number;
+	    startedAt: any;
+	    stats: Stats;
+	    draining?: boolean;
+	    transfers?: Transfer[];
+	    expiresAt?: any;
+	    maxDownloads?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Info(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.kind = source["kind"];
+	        this.name = source["name"];
+	        this.public = source["public"];
+	        this.urls = source["urls"];
+	        this.port = source["port"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.stats = this.convertValues(source["stats"], Stats);
+	        this.draining = source["draining"];
+	        this.transfers = this.convertValues(source["transfers"], Transfer);
+	        this.expiresAt = this.convertValues(source["expiresAt"], null);
+	        this.maxDownloads = source["maxDownloads"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ShareConfig {
+	    path: string;
+	    mounts: server.Mount[];
+	    port: string;
+	    password?: string;
+	    allowUpload: boolean;
+	    upload: server.UploadLimits;
+	    public: boolean;
+	    title?: string;
+	    interface?: string;
+	    portFallback?: number;
+	    passwordSecret?: string;
+	    expiresAt?: any;
+	    maxDownloads?: number;
+	    statsFile?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ShareConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.mounts = this.convertValues(source["mounts"], server.Mount);
+	        this.port = source["port"];
+	        this.password = source["password"];
+	        this.allowUpload = source["allowUpload"];
+	        this.upload = this.convertValues(source["upload"], server.UploadLimits);
+	        this.public = source["public"];
+	        this.title = source["title"];
+	        this.interface = source["interface"];
+	        this.portFallback = source["portFallback"];
+	        this.passwordSecret = source["passwordSecret"];
+	        this.expiresAt = this.convertValues(source["expiresAt"], null);
+	        this.maxDownloads = source["maxDownloads"];
+	        this.statsFile = source["statsFile"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Stats {
+	    requests: number;
+	    responses?: Record<number, number>;
+	    bytesSent: number;
+	    bytesUploaded: number;
+	    zipStreams: number;
+	    connections: number;
+	    activeConnections: number;
+	    downloads: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Stats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requests = source["requests"];
+	        this.responses = source["responses"];
+	        this.bytesSent = source["bytesSent"];
+	        this.bytesUploaded = source["bytesUploaded"];
+	        this.zipStreams = source["zipStreams"];
+	        this.connections = source["connections"];
+	        this.activeConnections = source["activeConnections"];
+	        this.downloads = source["downloads"];
+	    }
+	}
+	
+	export class Transfer {
+	    method?: string;
+	    path?: string;
+	    remote: string;
+	    startedAt: any;
+	    bytes: number;
+	    size?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Transfer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.method = source["method"];
+	        this.path = source["path"];
+	        this.remote = source["remote"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.bytes = source["bytes"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace settings {
+	
+	export class Preset {
+	    name: string;
+	    share: session.ShareConfig;
+	
+	    static createFrom(source: any = {}) {
+	        return new Preset(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.share = this.convertValues(source["share"], session.ShareConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Settings {
+	    version: number;
+	    defaultPort: string;
+	    allowUpload: boolean;
+	    upload: server.UploadLimits;
+	    recentPaths: string[];
+	    presets: Preset[];
+	    passwordSecret: string;
+	    ngrokTokenSecret: string;
+	    restoreLastSession: boolean;
+	    lastSession: session.ShareConfig[];
+	    controlApi: boolean;
+	    controlPort: number;
+	    mdns: boolean;
+	    mdnsHostname: boolean;
+	    metrics: boolean;
+	    metricsPort: number;
+	    statsExport: string;
+	    statsDir: string;
+	    legacySecrets?: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.defaultPort = source["defaultPort"];
+	        this.allowUpload = source["allowUpload"];
+	        this.upload = this.convertValues(source["upload"], server.UploadLimits);
+	        this.recentPaths = source["recentPaths"];
+	        this.presets = this.convertValues(source["presets"], Preset);
+	        this.passwordSecret = source["passwordSecret"];
+	        this.ngrokTokenSecret = source["ngrokTokenSecret"];
+	        this.restoreLastSession = source["restoreLastSession"];
+	        this.lastSession = this.convertValues(source["lastSession"], session.ShareConfig);
+	        this.controlApi = source["controlApi"];
+	        this.controlPort = source["controlPort"];
+	        this.mdns = source["mdns"];
+	        this.mdnsHostname = source["mdnsHostname"];
+	        this.metrics = source["metrics"];
+	        this.metricsPort = source["metricsPort"];
+	        this.statsExport = source["statsExport"];
+	        this.statsDir = source["statsDir"];
+	        this.legacySecrets = source["legacySecrets"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
 export namespace update {
 	
 	export class Info {
@@ -23,3 +696,30 @@ export namespace update {
 
 }
 
+export namespace utils {
+	
+	export class NetInterface {
+	    name: string;
+	    kind: string;
+	    cidrs: string[];
+	    mac: string;
+	    up: boolean;
+	    rank: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetInterface(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.kind = source["kind"];
+	        this.cidrs = source["cidrs"];
+	        this.mac = source["mac"];
+	        this.up = source["up"];
+	        this.rank = source["rank"];
+	    }
+	}
+
+}
+
@@ -2,11 +2,14 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
+
+	"JustServe/pkg/errcode"
 )
 
 //go:embed all:frontend/dist
@@ -14,6 +17,11 @@ var assets embed.FS
 
 
 func main() {
+	// Headless subcommands (serve, proxy, ...) run without the Wails window
+	if isCLICommand(os.Args) {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -28,6 +36,8 @@ func main() {
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
 		OnBeforeClose:    app.beforeClose,
+		OnShutdown:       app.shutdown,
+		ErrorFormatter:   errcode.Format,
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"JustServe/pkg/metrics"
+	"JustServe/pkg/settings"
+)
+
+// MetricsStatus describes the Prometheus metrics endpoint
+type MetricsStatus struct {
+	Enabled bool   `json:"enabled"`
+	Port    int    `json:"port"` // Configured port on 127.0.0.1
+	Running bool   `json:"running"`
+	URL     string `json:"url"`   // Scrape address while running
+	Error   string `json:"error"` // Why the endpoint is enabled but not running
+}
+
+// GetMetricsStatus reports whether /metrics is served and where
+func (a *App) GetMetricsStatus() MetricsStatus {
+	st, _ := a.settings.Load()
+	status := MetricsStatus{Enabled: st.Metrics, Port: metricsPort(st)}
+
+	a.metricsMu.Lock()
+	defer a.metricsMu.Unlock()
+	if a.metrics != nil {
+		status.Running = true
+		status.URL = a.metrics.URL()
+	} else if a.metricsErr != nil {
+		status.Error = a.metricsErr.Error()
+	}
+	return status
+}
+
+// SetMetrics turns the metrics endpoint on or off, moves it to port (0 keeps
+// the current one) and remembers the choice
+func (a *App) SetMetrics(enabled bool, port int) (MetricsStatus, error) {
+	if _, err := a.settings.Update(func(st *settings.Settings) {
+		st.Metrics = enabled
+		if port > 0 {
+			st.MetricsPort = port
+		}
+	}); err != nil {
+		return a.GetMetricsStatus(), err
+	}
+	a.stopMetrics()
+	err := a.startMetrics()
+	return a.GetMetricsStatus(), err
+}
+
+// startMetrics serves /metrics if it is enabled in the settings
+func (a *App) startMetrics() error {
+	st, err := a.settings.Load()
+	if err != nil || !st.Metrics {
+		return nil
+	}
+
+	a.metricsMu.Lock()
+	defer a.metricsMu.Unlock()
+	if a.metrics != nil {
+		return nil
+	}
+	a.metrics, a.metricsErr = metrics.Start(metricsPort(st), a.sessions, a.p2pManager)
+	if a.metricsErr != nil {
+		runtime.LogErrorf(a.ctx, "Metrics: %v", a.metricsErr)
+		return a.metricsErr
+	}
+	return nil
+}
+
+func (a *App) stopMetrics() {
+	a.metricsMu.Lock()
+	defer a.metricsMu.Unlock()
+	if a.metrics != nil {
+		a.metrics.Close()
+		a.metrics = nil
+	}
+	a.metricsErr = nil
+}
+
+func metricsPort(st settings.Settings) int {
+	if st.MetricsPort > 0 {
+		return st.MetricsPort
+	}
+	return metrics.DefaultPort
+}
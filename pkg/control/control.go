@@ -0,0 +1,443 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/p2p"
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+)
+
+// ShareRequest is the body of POST /api/v1/shares
+type ShareRequest struct {
+	Path           string              `json:"path"`
+	Mounts         []server.Mount      `json:"mounts"`
+	Port           string              `json:"port"`
+	PortFallback   int                 `json:"portFallback"` // Following ports to try when port is busy
+	Password       string              `json:"password"`
+	PasswordSecret string              `json:"passwordSecret"` // Vault secret used when password is empty
+	AllowUpload    bool                `json:"allowUpload"`
+	Upload         server.UploadLimits `json:"upload"`
+	Public         bool                `json:"public"`
+	TokenSecret    string              `json:"tokenSecret"`  // Vault secret of the ngrok token, for public shares
+	Interface      string              `json:"interface"`    // Network interface a local share listens on, "" for all
+	Expires        string              `json:"expires"`      // Stop after a duration or at a time, e.g. "2h" or RFC 3339
+	MaxDownloads   int                 `json:"maxDownloads"` // Stop after this many completed downloads
+	StatsFile      string              `json:"statsFile"`    // Download stats written here when the share stops (.csv or JSON)
+}
+
+// ProxyRequest is the body of POST /api/v1/proxies
+type ProxyRequest struct {
+	Port        string `json:"port"`
+	Protocol    string `json:"protocol"`
+	TokenSecret string `json:"tokenSecret"` // Vault secret of the ngrok token, for HTTP proxies
+}
+
+// Status is the response of GET /api/v1/status
+type Status struct {
+	Version  string            `json:"version"`
+	Sessions []session.Info    `json:"sessions"`
+	P2P      *p2p.TransferInfo `json:"p2p"`
+}
+
+// Backend performs the actions of the API. The desktop app implements it
+// with the same code paths as its bindings.
+type Backend interface {
+	StartShare(req ShareRequest) (session.Info, error)
+	StartProxy(req ProxyRequest) (session.Info, error)
+	StopSession(id string) error
+	StopAll()
+	DrainSession(id string, timeout time.Duration) error // Returns once draining started
+	DrainAll(timeout time.Duration)                      // Returns once draining started
+	ListSessions() []session.Info
+	ListConnections(id string) ([]server.Conn, error)
+	KillConnection(id string, connID string) error
+	ListDownloads(id string) ([]session.FileStats, error)
+	StartP2PSend(path string, iface string, expires string, maxDownloads int) (*p2p.TransferInfo, error)
+	StopP2P()
+	DrainP2P(timeout time.Duration) // Returns once draining started
+	P2PStatus() *p2p.TransferInfo
+	Version() string
+}
+
+// Endpoint tells scripts where the API listens. It is written to a file only
+// the current user can read.
+type Endpoint struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	PID   int    `json:"pid"`
+}
+
+// Server is the loopback control API
+type Server struct {
+	backend  Backend
+	token    string
+	srv      *http.Server
+	listener net.Listener
+	infoPath string
+
+	mu      sync.Mutex
+	clients map[chan event]struct{}
+}
+
+type event struct {
+	name string
+	data []byte
+}
+
+// Start listens on 127.0.0.1:port (0 picks a free port), generates a bearer
+// token and writes the endpoint to infoPath
+func Start(backend Backend, port int, infoPath string) (*Server, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start control API: %w", err)
+	}
+
+	s := &Server{
+		backend:  backend,
+		token:    token,
+		listener: listener,
+		infoPath: infoPath,
+		clients:  make(map[chan event]struct{}),
+	}
+	if err := s.writeEndpoint(); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s.srv = &http.Server{Handler: s.guard(s.routes()), ReadHeaderTimeout: 10 * time.Second}
+	go s.srv.Serve(listener)
+	return s, nil
+}
+
+// URL returns the base URL of the API
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// InfoPath returns the file holding the URL and token
+func (s *Server) InfoPath() string {
+	return s.infoPath
+}
+
+// Close stops the API, ends event streams and removes the endpoint file
+func (s *Server) Close() {
+	s.mu.Lock()
+	for ch := range s.clients {
+		close(ch)
+		delete(s.clients, ch)
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+	}
+	os.Remove(s.infoPath)
+}
+
+// Publish sends an event to every connected /api/v1/events stream. Slow
+// clients miss events instead of blocking the app.
+func (s *Server) Publish(name string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- event{name: name, data: payload}:
+		default:
+		}
+	}
+}
+
+func (s *Server) writeEndpoint() error {
+	data, err := json.MarshalIndent(Endpoint{URL: s.URL(), Token: s.token, PID: os.Getpid()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.infoPath), 0700); err != nil {
+		return fmt.Errorf("cannot create config directory: %w", err)
+	}
+	if err := os.WriteFile(s.infoPath, data, 0600); err != nil {
+		return fmt.Errorf("cannot write control API endpoint: %w", err)
+	}
+	return nil
+}
+
+// guard rejects requests without the bearer token, and requests whose Host
+// is not loopback (DNS rebinding from a web page)
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			writeError(w, http.StatusForbidden, errors.New("control API only accepts loopback requests"))
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="JustServe"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Status{
+			Version:  s.backend.Version(),
+			Sessions: s.backend.ListSessions(),
+			P2P:      s.backend.P2PStatus(),
+		})
+	})
+
+	mux.HandleFunc("GET /api/v1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.backend.ListSessions())
+	})
+
+	mux.HandleFunc("DELETE /api/v1/sessions", func(w http.ResponseWriter, r *http.Request) {
+		timeout, drain, ok := drainParam(w, r)
+		if !ok {
+			return
+		}
+		if drain {
+			s.backend.DrainAll(timeout)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		s.backend.StopAll()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		timeout, drain, ok := drainParam(w, r)
+		if !ok {
+			return
+		}
+		if !drain {
+			if err := s.backend.StopSession(r.PathValue("id")); err != nil {
+				writeError(w, http.StatusNotFound, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := s.backend.DrainSession(r.PathValue("id"), timeout); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	mux.HandleFunc("GET /api/v1/sessions/{id}/connections", func(w http.ResponseWriter, r *http.Request) {
+		conns, err := s.backend.ListConnections(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, conns)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/sessions/{id}/connections/{conn}", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.backend.KillConnection(r.PathValue("id"), r.PathValue("conn")); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /api/v1/sessions/{id}/downloads", func(w http.ResponseWriter, r *http.Request) {
+		files, err := s.backend.ListDownloads(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, files)
+	})
+
+	mux.HandleFunc("POST /api/v1/shares", func(w http.ResponseWriter, r *http.Request) {
+		var req ShareRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		info, err := s.backend.StartShare(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	})
+
+	mux.HandleFunc("POST /api/v1/proxies", func(w http.ResponseWriter, r *http.Request) {
+		var req ProxyRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		info, err := s.backend.StartProxy(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	})
+
+	mux.HandleFunc("GET /api/v1/p2p", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.backend.P2PStatus())
+	})
+
+	mux.HandleFunc("POST /api/v1/p2p/send", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path         string `json:"path"`
+			Interface    string `json:"interface"`
+			Expires      string `json:"expires"`
+			MaxDownloads int    `json:"maxDownloads"`
+		}
+		if !readJSON(w, r, &req) {
+			return
+		}
+		info, err := s.backend.StartP2PSend(req.Path, req.Interface, req.Expires, req.MaxDownloads)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/p2p", func(w http.ResponseWriter, r *http.Request) {
+		timeout, drain, ok := drainParam(w, r)
+		if !ok {
+			return
+		}
+		if drain {
+			s.backend.DrainP2P(timeout)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		s.backend.StopP2P()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /api/v1/events", s.serveEvents)
+
+	return mux
+}
+
+// serveEvents streams app events as Server-Sent Events until the client leaves
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	ch := make(chan event, 64)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if _, ok := s.clients[ch]; ok {
+			delete(s.clients, ch)
+			close(ch)
+		}
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	rc.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// drainParam reads the "drain" query parameter of a stop request: absent to
+// stop immediately, empty for session.DefaultDrainTimeout, or a duration such
+// as "2m". It writes the error response and reports false when it is invalid.
+func drainParam(w http.ResponseWriter, r *http.Request) (timeout time.Duration, drain bool, ok bool) {
+	q := r.URL.Query()
+	if !q.Has("drain") {
+		return 0, false, true
+	}
+	v := q.Get("drain")
+	if v == "" {
+		return session.DefaultDrainTimeout, true, true
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout <= 0 {
+		writeError(w, http.StatusBadRequest, errcode.New(errcode.InvalidInput, fmt.Sprintf("invalid drain timeout %q", v)))
+		return 0, false, false
+	}
+	return timeout, true, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends {"error": message, "code": errcode}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error(), "code": string(errcode.Of(err))})
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,319 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"JustServe/pkg/secrets"
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+	"JustServe/pkg/utils"
+)
+
+// Config is the declarative daemon file. ${VAR} references anywhere in the
+// file are replaced with environment variables before parsing, and *Secret
+// fields name secrets of the desktop app's vault (see resolveSecrets).
+type Config struct {
+	NgrokToken       string      `yaml:"ngrokToken"`       // Used by public shares and HTTP proxies
+	NgrokTokenSecret string      `yaml:"ngrokTokenSecret"` // Vault secret holding ngrokToken
+	AccessLog        bool        `yaml:"accessLog"`        // Log every HTTP request to stdout
+	MDNS             bool        `yaml:"mdns"`             // Advertise local shares on the LAN by their names
+	MDNSAlias        bool        `yaml:"mdnsAlias"`        // Also publish justserve.local (needs mdns)
+	Metrics          int         `yaml:"metrics"`          // Serve Prometheus /metrics on this 127.0.0.1 port, 0 for none
+	Drain            Duration    `yaml:"drain"`            // How long stopped sessions wait for transfers in progress, 0 for none
+	Shares           []ShareSpec `yaml:"shares"`
+	Proxies          []ProxySpec `yaml:"proxies"`
+	Inboxes          []InboxSpec `yaml:"inboxes"`
+}
+
+// ShareSpec declares a file share
+type ShareSpec struct {
+	Name           string            `yaml:"name"`
+	Path           string            `yaml:"path"`
+	Mounts         map[string]string `yaml:"mounts"` // Mount name -> folder, used instead of path
+	Port           string            `yaml:"port"`
+	PortFallback   int               `yaml:"portFallback"` // Following ports to try when port is busy
+	Interface      string            `yaml:"interface"`    // Listen on this network interface only
+	Password       string            `yaml:"password"`
+	PasswordSecret string            `yaml:"passwordSecret"` // Vault secret holding password
+	Public         bool              `yaml:"public"`
+	Upload         UploadSpec        `yaml:"upload"`
+	StatsFile      string            `yaml:"statsFile"` // Download stats written here when the share stops (.csv, else JSON)
+}
+
+// UploadSpec declares the upload rules of a share
+type UploadSpec struct {
+	Enabled      bool     `yaml:"enabled"`
+	MaxFileSize  Size     `yaml:"maxFileSize"`
+	Quota        Size     `yaml:"quota"`
+	MinFreeSpace Size     `yaml:"minFreeSpace"`
+	AllowExt     []string `yaml:"allowExt"`
+	BlockExt     []string `yaml:"blockExt"`
+}
+
+// ProxySpec declares a port proxy
+type ProxySpec struct {
+	Name     string `yaml:"name"`
+	Port     string `yaml:"port"`
+	Protocol string `yaml:"protocol"` // "http" (ngrok) or "tcp" (LAN listener)
+}
+
+// InboxSpec declares a folder that automatically receives P2P transfers
+// offered on the LAN (e.g. by `justserve send`)
+type InboxSpec struct {
+	Name    string   `yaml:"name"`
+	Dir     string   `yaml:"dir"`
+	Allow   []string `yaml:"allow"`   // Sender networks in CIDR form; empty accepts everyone
+	MaxSize Size     `yaml:"maxSize"` // Largest transfer to accept, 0 for no limit
+}
+
+// Size is a byte count written as a number or a string like "500MB"
+type Size int64
+
+// UnmarshalYAML accepts plain integers and human-readable sizes
+func (s *Size) UnmarshalYAML(value *yaml.Node) error {
+	n, err := utils.ParseSize(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*s = Size(n)
+	return nil
+}
+
+// Duration is a time span written like "30s" or "2m"
+type Duration time.Duration
+
+// UnmarshalYAML parses Go duration strings
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	v, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads and validates a daemon config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	expanded := os.Expand(string(data), func(name string) string {
+		if name == "$" {
+			return "$" // "$$" escapes a literal dollar sign
+		}
+		return os.Getenv(name)
+	})
+
+	var cfg Config
+	dec := yaml.NewDecoder(strings.NewReader(expanded))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Relative paths are relative to the config file, not the working directory
+	base := filepath.Dir(path)
+	for i := range cfg.Shares {
+		cfg.Shares[i].Path = resolvePath(base, cfg.Shares[i].Path)
+		cfg.Shares[i].StatsFile = resolvePath(base, cfg.Shares[i].StatsFile)
+		for name, dir := range cfg.Shares[i].Mounts {
+			cfg.Shares[i].Mounts[name] = resolvePath(base, dir)
+		}
+	}
+	for i := range cfg.Inboxes {
+		cfg.Inboxes[i].Dir = resolvePath(base, cfg.Inboxes[i].Dir)
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func resolvePath(base string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// VaultPassphraseEnv unlocks a passphrase-protected vault for *Secret fields
+const VaultPassphraseEnv = "JUSTSERVE_VAULT_PASSPHRASE"
+
+// resolveSecrets fills the ngrok token and share passwords named by *Secret
+// fields from the vault. The vault is only opened when one is set.
+func (c *Config) resolveSecrets() error {
+	var vault *secrets.Vault
+	get := func(field string, name string) (string, error) {
+		if vault == nil {
+			path, err := secrets.DefaultPath()
+			if err == nil {
+				vault, err = secrets.Open(path)
+			}
+			if err == nil && vault.Status().Locked {
+				if passphrase := os.Getenv(VaultPassphraseEnv); passphrase != "" {
+					err = vault.Unlock(passphrase)
+				}
+			}
+			if err != nil {
+				vault = nil
+				return "", fmt.Errorf("%s: %w", field, err)
+			}
+		}
+		value, err := vault.Get(name)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", field, err)
+		}
+		return value, nil
+	}
+
+	if c.NgrokTokenSecret != "" {
+		if c.NgrokToken != "" {
+			return fmt.Errorf("set either ngrokToken or ngrokTokenSecret")
+		}
+		token, err := get("ngrokTokenSecret", c.NgrokTokenSecret)
+		if err != nil {
+			return err
+		}
+		c.NgrokToken = token
+	}
+	for i, s := range c.Shares {
+		if s.PasswordSecret == "" {
+			continue
+		}
+		if s.Password != "" {
+			return fmt.Errorf("share %q: set either password or passwordSecret", s.Name)
+		}
+		password, err := get(fmt.Sprintf("share %q: passwordSecret", s.Name), s.PasswordSecret)
+		if err != nil {
+			return err
+		}
+		c.Shares[i].Password = password
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Metrics < 0 || c.Metrics > 65535 {
+		return fmt.Errorf("metrics: invalid port %d", c.Metrics)
+	}
+	if c.Drain < 0 {
+		return fmt.Errorf("drain cannot be negative")
+	}
+	names := make(map[string]bool)
+	unique := func(kind string, name string) error {
+		if name == "" {
+			return fmt.Errorf("every %s needs a name", kind)
+		}
+		key := kind + "/" + name
+		if names[key] {
+			return fmt.Errorf("duplicate %s name %q", kind, name)
+		}
+		names[key] = true
+		return nil
+	}
+
+	for _, s := range c.Shares {
+		if err := unique("share", s.Name); err != nil {
+			return err
+		}
+		if (s.Path == "") == (len(s.Mounts) == 0) {
+			return fmt.Errorf("share %q needs either a path or mounts", s.Name)
+		}
+		if s.Public && c.NgrokToken == "" {
+			return fmt.Errorf("share %q is public but no ngrokToken is set", s.Name)
+		}
+		if s.Public && s.Interface != "" {
+			return fmt.Errorf("share %q: interface only applies to local shares", s.Name)
+		}
+		if s.PortFallback < 0 {
+			return fmt.Errorf("share %q: portFallback cannot be negative", s.Name)
+		}
+	}
+	for _, p := range c.Proxies {
+		if err := unique("proxy", p.Name); err != nil {
+			return err
+		}
+		if p.Port == "" {
+			return fmt.Errorf("proxy %q needs a port", p.Name)
+		}
+		switch p.Protocol {
+		case "", "http":
+			if c.NgrokToken == "" {
+				return fmt.Errorf("http proxy %q needs ngrokToken", p.Name)
+			}
+		case "tcp":
+		default:
+			return fmt.Errorf("proxy %q: unknown protocol %q (use http or tcp)", p.Name, p.Protocol)
+		}
+	}
+	for _, in := range c.Inboxes {
+		if err := unique("inbox", in.Name); err != nil {
+			return err
+		}
+		if in.Dir == "" {
+			return fmt.Errorf("inbox %q needs a dir", in.Name)
+		}
+		for _, cidr := range in.Allow {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("inbox %q: %w", in.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// shareConfig converts a spec to the session config it starts
+func (c *Config) shareConfig(s ShareSpec) session.ShareConfig {
+	cfg := session.ShareConfig{
+		Title:        s.Name,
+		Path:         s.Path,
+		Port:         s.Port,
+		PortFallback: s.PortFallback,
+		Interface:    s.Interface,
+		Password:     s.Password,
+		AllowUpload:  s.Upload.Enabled,
+		Public:       s.Public,
+		StatsFile:    s.StatsFile,
+		Upload: server.UploadLimits{
+			MaxFileSize:  int64(s.Upload.MaxFileSize),
+			Quota:        int64(s.Upload.Quota),
+			MinFreeSpace: int64(s.Upload.MinFreeSpace),
+			AllowedExts:  s.Upload.AllowExt,
+			BlockedExts:  s.Upload.BlockExt,
+		},
+	}
+	if s.Public {
+		cfg.NgrokToken = c.NgrokToken
+	}
+	if len(s.Mounts) > 0 {
+		cfg.Path = ""
+		for _, name := range sortedKeys(s.Mounts) {
+			cfg.Mounts = append(cfg.Mounts, server.Mount{Name: name, Path: s.Mounts[name], AllowUpload: s.Upload.Enabled})
+		}
+	}
+	return cfg
+}
+
+// proxyConfig converts a spec to the session config it starts
+func (c *Config) proxyConfig(p ProxySpec) session.ProxyConfig {
+	cfg := session.ProxyConfig{Port: p.Port, Protocol: p.Protocol}
+	if cfg.Protocol == "" {
+		cfg.Protocol = "http"
+	}
+	if cfg.Protocol == "http" {
+		cfg.NgrokToken = c.NgrokToken
+	}
+	return cfg
+}
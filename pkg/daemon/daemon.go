@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"JustServe/pkg/mdns"
+	"JustServe/pkg/metrics"
+	"JustServe/pkg/session"
+)
+
+// Daemon keeps the running sessions in line with a Config. Applying a new
+// config only touches the sections that changed.
+type Daemon struct {
+	sessions *session.Manager
+	logger   *log.Logger
+
+	mu      sync.Mutex
+	running map[string]*entry // Keyed by "share/<name>" or "proxy/<name>"
+	inboxes []InboxSpec
+	inbox   *inboxRunner
+
+	mdns      *mdns.Responder
+	mdnsAlias bool
+
+	metrics     *metrics.Server
+	metricsPort int
+
+	drain time.Duration // Grace period of removed sections and of Stop
+}
+
+// entry is a declared session and the config it was started with
+type entry struct {
+	cfg interface{} // session.ShareConfig or session.ProxyConfig
+	id  string
+}
+
+// New creates a daemon that runs its sessions on sessions
+func New(sessions *session.Manager, logger *log.Logger) *Daemon {
+	return &Daemon{
+		sessions: sessions,
+		logger:   logger,
+		running:  make(map[string]*entry),
+	}
+}
+
+// Apply starts, restarts and stops sessions so they match cfg. Sections whose
+// config did not change keep running. Start failures are logged and returned
+// together; the other sections are still applied.
+func (d *Daemon) Apply(cfg *Config) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sessions.SetAccessLog(nil)
+	if cfg.AccessLog {
+		d.sessions.SetAccessLog(d.logger.Writer())
+	}
+
+	d.drain = time.Duration(cfg.Drain)
+	var errs []error
+	if err := d.setMDNS(cfg.MDNS, cfg.MDNSAlias); err != nil {
+		d.logger.Printf("mdns: %v", err)
+		errs = append(errs, err)
+	}
+	if err := d.setMetrics(cfg.Metrics); err != nil {
+		d.logger.Printf("metrics: %v", err)
+		errs = append(errs, err)
+	}
+
+	desired := make(map[string]interface{})
+	for _, s := range cfg.Shares {
+		desired["share/"+s.Name] = cfg.shareConfig(s)
+	}
+	for _, p := range cfg.Proxies {
+		desired["proxy/"+p.Name] = cfg.proxyConfig(p)
+	}
+
+	// Stop what was removed, changed, or died on its own
+	for _, key := range sortedKeys(d.running) {
+		e := d.running[key]
+		want, keep := desired[key]
+		_, alive := d.sessions.Get(e.id)
+		switch {
+		case !keep && alive && d.drain > 0:
+			// Nothing replaces it, so it may finish its transfers
+			d.logger.Printf("%s: removed, draining", key)
+			d.sessions.Drain(e.id, d.drain)
+			delete(d.running, key)
+			continue
+		case !keep:
+			d.logger.Printf("%s: removed, stopping", key)
+		case !reflect.DeepEqual(want, e.cfg):
+			d.logger.Printf("%s: changed, restarting", key)
+		case !alive:
+			d.logger.Printf("%s: not running, restarting", key)
+		default:
+			continue
+		}
+		d.sessions.Stop(e.id)
+		delete(d.running, key)
+	}
+
+	for _, key := range sortedKeys(desired) {
+		if _, ok := d.running[key]; ok {
+			continue
+		}
+		s, err := d.start(desired[key])
+		if err != nil {
+			d.logger.Printf("%s: %v", key, err)
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		d.running[key] = &entry{cfg: desired[key], id: s.ID()}
+		for _, url := range s.Info().URLs {
+			d.logger.Printf("%s: serving at %s", key, url)
+		}
+	}
+
+	if !reflect.DeepEqual(cfg.Inboxes, d.inboxes) {
+		if d.inbox != nil {
+			d.inbox.stop()
+			d.inbox = nil
+			d.logger.Printf("inboxes: changed, restarting")
+		}
+		if len(cfg.Inboxes) > 0 {
+			d.inbox = startInboxes(cfg.Inboxes, d.logger)
+		}
+		d.inboxes = cfg.Inboxes
+	}
+
+	return errors.Join(errs...)
+}
+
+// setMDNS starts, restarts or stops the mDNS responder that advertises the
+// local shares
+func (d *Daemon) setMDNS(enabled, alias bool) error {
+	if d.mdns != nil && (!enabled || alias != d.mdnsAlias) {
+		d.sessions.SetAdvertiser(nil)
+		d.mdns.Close()
+		d.mdns = nil
+		if !enabled {
+			d.logger.Printf("mdns: stopped")
+		}
+	}
+	if !enabled || d.mdns != nil {
+		return nil
+	}
+	r, err := mdns.Start(mdns.Options{Alias: alias})
+	if err != nil {
+		return err
+	}
+	d.mdns, d.mdnsAlias = r, alias
+	d.sessions.SetAdvertiser(r)
+	d.logger.Printf("mdns: advertising local shares from %s", r.Hostname())
+	return nil
+}
+
+// setMetrics starts, moves or stops the /metrics endpoint; port 0 stops it
+func (d *Daemon) setMetrics(port int) error {
+	if d.metrics != nil && port != d.metricsPort {
+		d.metrics.Close()
+		d.metrics = nil
+		if port == 0 {
+			d.logger.Printf("metrics: stopped")
+		}
+	}
+	if port == 0 || d.metrics != nil {
+		return nil
+	}
+	srv, err := metrics.Start(port, d.sessions)
+	if err != nil {
+		return err
+	}
+	d.metrics, d.metricsPort = srv, port
+	d.logger.Printf("metrics: serving at %s", srv.URL())
+	return nil
+}
+
+func (d *Daemon) start(cfg interface{}) (*session.Session, error) {
+	switch c := cfg.(type) {
+	case session.ShareConfig:
+		return d.sessions.StartShare(c)
+	case session.ProxyConfig:
+		return d.sessions.StartProxy(c)
+	}
+	return nil, fmt.Errorf("unknown section type %T", cfg)
+}
+
+// Stop shuts down every session and inbox the daemon started, and stops
+// advertising and serving metrics. With a drain period, sessions finish
+// their transfers first and Stop waits for them.
+func (d *Daemon) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.inbox != nil {
+		d.inbox.stop()
+		d.inbox = nil
+	}
+	d.inboxes = nil
+	var draining []*session.Session
+	for key, e := range d.running {
+		if s, ok := d.sessions.Get(e.id); ok && d.drain > 0 {
+			d.sessions.Drain(e.id, d.drain)
+			draining = append(draining, s)
+		} else {
+			d.sessions.Stop(e.id)
+		}
+		delete(d.running, key)
+	}
+	if len(draining) > 0 {
+		d.logger.Printf("Waiting up to %s for transfers in progress", d.drain)
+	}
+	for _, s := range draining {
+		<-s.Done()
+	}
+	d.setMDNS(false, false)
+	d.setMetrics(0)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"JustServe/pkg/p2p"
+	"JustServe/pkg/utils"
+)
+
+// seenTTL is how long a transfer code is ignored after it was handled
+const seenTTL = 10 * time.Minute
+
+// inboxRunner listens for P2P senders and receives their transfers into the
+// first inbox that accepts the sender. One runner serves every inbox because
+// discovery uses a single UDP port.
+type inboxRunner struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startInboxes(specs []InboxSpec, logger *log.Logger) *inboxRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &inboxRunner{cancel: cancel, done: make(chan struct{})}
+	for _, in := range specs {
+		logger.Printf("inbox/%s: receiving into %s", in.Name, in.Dir)
+	}
+	go func() {
+		defer close(r.done)
+		runInboxes(ctx, specs, logger)
+	}()
+	return r
+}
+
+func (r *inboxRunner) stop() {
+	r.cancel()
+	<-r.done
+}
+
+func runInboxes(ctx context.Context, specs []InboxSpec, logger *log.Logger) {
+	m := p2p.NewManager(nil)
+	seen := make(map[string]time.Time)
+
+	for ctx.Err() == nil {
+		for code, at := range seen {
+			if time.Since(at) > seenTTL {
+				delete(seen, code)
+			}
+		}
+
+		peers, err := m.Discover(3*time.Second, func(p p2p.Peer) bool {
+			_, ok := seen[p.Code]
+			return !ok
+		})
+		if err != nil {
+			logger.Printf("inboxes: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+			}
+			continue
+		}
+
+		for _, peer := range peers {
+			if _, ok := seen[peer.Code]; ok || ctx.Err() != nil {
+				continue
+			}
+			seen[peer.Code] = time.Now()
+			if in, ok := matchInbox(specs, peer.URL); ok {
+				receiveInto(ctx, in, peer, logger)
+			}
+		}
+	}
+}
+
+// matchInbox returns the first inbox whose allow list contains the sender
+func matchInbox(specs []InboxSpec, senderURL string) (InboxSpec, bool) {
+	u, err := url.Parse(senderURL)
+	if err != nil {
+		return InboxSpec{}, false
+	}
+	ip := utils.ParseHostIP(u.Host) // Drops the zone of link-local senders
+	for _, in := range specs {
+		if len(in.Allow) == 0 {
+			return in, true
+		}
+		for _, cidr := range in.Allow {
+			if _, network, err := net.ParseCIDR(cidr); err == nil && ip != nil && network.Contains(ip) {
+				return in, true
+			}
+		}
+	}
+	return InboxSpec{}, false
+}
+
+func receiveInto(ctx context.Context, in InboxSpec, peer p2p.Peer, logger *log.Logger) {
+	info, err := p2p.FetchPeerInfo(peer.URL)
+	if err != nil {
+		logger.Printf("inbox/%s: %s: %v", in.Name, peer.URL, err)
+		return
+	}
+	if in.MaxSize > 0 && !info.IsDir && info.FileSize > int64(in.MaxSize) {
+		logger.Printf("inbox/%s: skipping %s from %s: larger than maxSize", in.Name, info.FileName, peer.URL)
+		return
+	}
+
+	// Folder sizes are only known while streaming, so maxSize is also enforced there
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tooLarge := false
+	logger.Printf("inbox/%s: receiving %s from %s", in.Name, info.FileName, peer.URL)
+	path, err := p2p.NewManager(nil).Receive(rctx, peer.URL, in.Dir, func(received, total int64) {
+		if in.MaxSize > 0 && received > int64(in.MaxSize) {
+			tooLarge = true
+			cancel()
+		}
+	})
+	switch {
+	case tooLarge:
+		logger.Printf("inbox/%s: aborted %s: larger than maxSize", in.Name, info.FileName)
+	case err != nil:
+		logger.Printf("inbox/%s: %s: %v", in.Name, info.FileName, err)
+	default:
+		logger.Printf("inbox/%s: saved %s", in.Name, path)
+	}
+}
@@ -0,0 +1,117 @@
+// Package errcode gives errors a stable code that the UI can translate, so
+// messages do not have to be matched as text.
+package errcode
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.ngrok.com/ngrok"
+)
+
+// Code identifies a kind of failure. Values are part of the UI contract and
+// must not change.
+type Code string
+
+const (
+	Unknown          Code = "unknown"
+	InvalidInput     Code = "invalid_input"
+	NotFound         Code = "not_found"         // File or folder does not exist
+	PermissionDenied Code = "permission_denied" // File or folder is not accessible
+	PortInUse        Code = "port_in_use"
+	Unreachable      Code = "unreachable" // Peer or local service refused or timed out
+	NgrokAuth        Code = "ngrok_auth"  // ngrok rejected the auth token
+	TunnelFailed     Code = "tunnel_failed"
+	VaultLocked      Code = "vault_locked"
+	VaultWrongKey    Code = "vault_wrong_key"
+	SecretNotFound   Code = "secret_not_found"
+	SessionNotFound  Code = "session_not_found"
+	ConnNotFound     Code = "connection_not_found"
+	Expired          Code = "expired" // Expiry time of a share or transfer has already passed
+	NoSender         Code = "no_sender"
+	Corrupted        Code = "transfer_corrupted"
+)
+
+// Error is an error with a Code. Packages declare their sentinel errors with
+// New and wrap them with fmt.Errorf("%w ...") as usual.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// New returns a coded sentinel error
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ngrok error codes that mean the auth token is missing, invalid or revoked
+var ngrokAuthCodes = map[string]bool{
+	"ERR_NGROK_105":  true,
+	"ERR_NGROK_106":  true,
+	"ERR_NGROK_107":  true,
+	"ERR_NGROK_4018": true,
+}
+
+// Of returns the code of err: the first *Error in its chain, otherwise a code
+// derived from well-known standard library and ngrok errors
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+
+	var ngrokErr ngrok.Error
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return NotFound
+	case errors.Is(err, os.ErrPermission):
+		return PermissionDenied
+	case errors.Is(err, syscall.EADDRINUSE):
+		return PortInUse
+	case errors.As(err, &ngrokErr):
+		if ngrokAuthCodes[ngrokErr.ErrorCode()] {
+			return NgrokAuth
+		}
+		return TunnelFailed
+	case isUnreachable(err):
+		return Unreachable
+	}
+	return Unknown
+}
+
+func isUnreachable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// PayloadVersion is bumped when Payload changes incompatibly
+const PayloadVersion = 1
+
+// Payload is how errors reach the UI, both as rejected binding promises and
+// inside error events
+type Payload struct {
+	Version int    `json:"version"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Format converts err to a Payload. It is installed as the Wails error formatter.
+func Format(err error) any {
+	return Payload{Version: PayloadVersion, Code: Of(err), Message: err.Error()}
+}
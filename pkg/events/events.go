@@ -0,0 +1,135 @@
+// Package events carries notifications from the sharing, P2P and tunnel code
+// to whoever runs it: the desktop UI, the headless CLI, or a test.
+package events
+
+import "sync"
+
+// Sink receives named events. Emit must not block for long; it is called
+// from request handlers and transfer loops.
+type Sink interface {
+	Emit(name string, data interface{})
+}
+
+// Event is one emitted notification
+type Event struct {
+	Name string
+	Data interface{}
+}
+
+// Func adapts a plain function to a Sink
+type Func func(name string, data interface{})
+
+// Emit calls f
+func (f Func) Emit(name string, data interface{}) {
+	f(name, data)
+}
+
+// Discard is a Sink that drops every event
+var Discard Sink = Func(func(string, interface{}) {})
+
+// OrDiscard returns s, or Discard when s is nil
+func OrDiscard(s Sink) Sink {
+	if s == nil {
+		return Discard
+	}
+	return s
+}
+
+// Multi returns a Sink that forwards every event to each of sinks in order.
+// Nil sinks are skipped.
+func Multi(sinks ...Sink) Sink {
+	return Func(func(name string, data interface{}) {
+		for _, s := range sinks {
+			if s != nil {
+				s.Emit(name, data)
+			}
+		}
+	})
+}
+
+// Channel delivers events on C for headless consumers. Events are dropped
+// rather than blocking the emitter when the buffer is full.
+type Channel struct {
+	C <-chan Event
+
+	c       chan Event
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// NewChannel creates a Channel sink with the given buffer size
+func NewChannel(buffer int) *Channel {
+	c := make(chan Event, buffer)
+	return &Channel{C: c, c: c}
+}
+
+// Emit queues the event, or counts it as dropped if C is full or closed
+func (ch *Channel) Emit(name string, data interface{}) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		ch.dropped++
+		return
+	}
+	select {
+	case ch.c <- Event{Name: name, Data: data}:
+	default:
+		ch.dropped++
+	}
+}
+
+// Dropped returns how many events did not fit in the buffer
+func (ch *Channel) Dropped() int64 {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.dropped
+}
+
+// Close closes C; later events are dropped
+func (ch *Channel) Close() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if !ch.closed {
+		ch.closed = true
+		close(ch.c)
+	}
+}
+
+// Recorder keeps every event in memory, for tests
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Emit records the event
+func (r *Recorder) Emit(name string, data interface{}) {
+	r.mu.Lock()
+	r.events = append(r.events, Event{Name: name, Data: data})
+	r.mu.Unlock()
+}
+
+// Events returns a copy of the recorded events, oldest first
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Named returns the recorded events called name
+func (r *Recorder) Named(name string) []Event {
+	var out []Event
+	for _, e := range r.Events() {
+		if e.Name == name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Reset forgets the recorded events
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.events = nil
+	r.mu.Unlock()
+}
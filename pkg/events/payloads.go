@@ -0,0 +1,33 @@
+package events
+
+import "JustServe/pkg/errcode"
+
+// PayloadVersion is bumped when an event payload changes incompatibly. Every
+// payload carries it so consumers can skip payloads they do not understand.
+const PayloadVersion = 1
+
+// Error is the payload of "server-error", "proxy-error",
+// "tunnel-disconnected" and "p2p-error"
+type Error struct {
+	Version   int          `json:"version"`
+	Code      errcode.Code `json:"code"`
+	Message   string       `json:"message"`
+	SessionID string       `json:"sessionId,omitempty"` // Share or proxy the error belongs to, if any
+}
+
+// NewError builds an Error payload from err
+func NewError(err error) Error {
+	return Error{Version: PayloadVersion, Code: errcode.Of(err), Message: err.Error()}
+}
+
+// Notice is the payload of events that carry no data of their own, such as
+// "tunnel-reconnected"
+type Notice struct {
+	Version   int    `json:"version"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// NewNotice returns a Notice payload
+func NewNotice() Notice {
+	return Notice{Version: PayloadVersion}
+}
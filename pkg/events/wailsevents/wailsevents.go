@@ -0,0 +1,25 @@
+// Package wailsevents forwards events to the Wails frontend. It is kept apart
+// from package events so headless builds do not depend on the Wails runtime.
+package wailsevents
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"JustServe/pkg/events"
+)
+
+type sink struct {
+	ctx context.Context
+}
+
+// New returns a Sink that calls runtime.EventsEmit with the app context
+// received in OnStartup
+func New(ctx context.Context) events.Sink {
+	return sink{ctx: ctx}
+}
+
+func (s sink) Emit(name string, data interface{}) {
+	runtime.EventsEmit(s.ctx, name, data)
+}
@@ -0,0 +1,159 @@
+// Package justserve embeds JustServe shares, port proxies and P2P transfers in
+// other Go programs, without the desktop app.
+//
+// Each feature has an options struct and a constructor that validates it:
+// NewShare, NewProxy, NewP2PSender and NewP2PReceiver. Shares, proxies and
+// senders run from Start until their context is cancelled or Close is called;
+// Done and Err report when and why they stopped. Receivers run for one call
+// of Receive.
+//
+//	share, err := justserve.NewShare(justserve.ShareOptions{Path: "./dist", Port: "8080"})
+//	if err != nil {
+//		return err
+//	}
+//	if err := share.Start(ctx); err != nil {
+//		return err
+//	}
+//	fmt.Println("serving at", share.URL())
+//	<-share.Done()
+package justserve
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/p2p"
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+	"JustServe/pkg/utils"
+)
+
+// Types shared with the desktop app
+type (
+	// Mount is a folder published under its own name by a multi-folder share
+	Mount = server.Mount
+	// UploadLimits restricts uploads to a share
+	UploadLimits = server.UploadLimits
+	// UploadRejection is the payload of "upload-rejected" events
+	UploadRejection = server.UploadRejection
+	// Info describes a running share or proxy
+	Info = session.Info
+	// Stats are the traffic counters of a share or proxy
+	Stats = session.Stats
+	// Transfer is a request or proxied connection in progress, listed in Info
+	Transfer = session.Transfer
+	// FileStats are the completed downloads of one path of a share
+	FileStats = session.FileStats
+	// DownloadReport is what a share writes to ShareOptions.StatsFile in JSON
+	DownloadReport = session.DownloadReport
+	// TransferInfo describes a P2P send session
+	TransferInfo = p2p.TransferInfo
+	// NetInterface describes a network adapter, for the Interface options
+	NetInterface = utils.NetInterface
+)
+
+// Interfaces lists the network adapters, best suited for LAN sharing first
+func Interfaces() ([]NetInterface, error) {
+	return utils.Interfaces()
+}
+
+// Event is passed to the OnEvent callbacks of the options structs. Callbacks
+// run on serving goroutines and must return quickly. Every payload has a
+// Version field (PayloadVersion). The events are:
+//
+//	"session-started", "session-stopped"  SessionEvent     Share, Proxy
+//	"session-draining"                     DrainingEvent    Share, Proxy (Shutdown was called or a limit was reached)
+//	"session-expired"                      ExpiredEvent     Share (ExpiresAt or MaxDownloads reached)
+//	"stats-exported"                       StatsExported    Share (StatsFile was written on stop)
+//	"stats-export-error"                   ErrorEvent       Share (StatsFile could not be written)
+//	"server-error"                         ErrorEvent       Share, Proxy (serving stops)
+//	"upload-rejected"                      UploadRejection  Share
+//	"port-fallback"                        PortFallback     local Share (Port was busy)
+//	"tunnel-disconnected"                  ErrorEvent       public Share, HTTP Proxy
+//	"tunnel-reconnected"                   NoticeEvent      public Share, HTTP Proxy
+//	"proxy-error"                          ErrorEvent       TCP Proxy (one connection failed)
+//	"p2p-status"                           P2PStatusEvent   P2PSender ("transferring", "completed", "waiting", "expired", "draining", "stopped")
+//	"p2p-progress"                         P2PProgressEvent P2PSender
+//	"p2p-error"                            ErrorEvent       P2PSender
+type Event = events.Event
+
+// Event payloads
+type (
+	SessionEvent     = session.Event
+	DrainingEvent    = session.Draining
+	ExpiredEvent     = session.Expired
+	StatsExported    = session.StatsExported
+	PortFallback     = session.PortFallback
+	ErrorEvent       = events.Error
+	NoticeEvent      = events.Notice
+	P2PStatusEvent   = p2p.StatusEvent
+	P2PProgressEvent = p2p.ProgressEvent
+)
+
+// PayloadVersion is the Version of the event payloads sent by this build
+const PayloadVersion = events.PayloadVersion
+
+// Code classifies errors; see ErrorCode
+type Code = errcode.Code
+
+// ErrorCode returns the stable code of err (e.g. "port_in_use", "no_sender"),
+// or "unknown"
+func ErrorCode(err error) Code {
+	return errcode.Of(err)
+}
+
+var (
+	// ErrInvalidOptions is matched by every *OptionError
+	ErrInvalidOptions = errors.New("invalid options")
+	// ErrStarted is returned by Start when it was already called
+	ErrStarted = errors.New("already started")
+	// ErrClosed is returned by Start after Close
+	ErrClosed = errors.New("closed")
+	// ErrNoSender is returned by Receive when discovery finds no sender with the code
+	ErrNoSender = p2p.ErrNoSender
+	// ErrCodeMismatch is returned by Receive when the sender at Address offers another code
+	ErrCodeMismatch = errors.New("sender offers a different transfer code")
+	// ErrCorrupted is returned by Receive when the size or checksum does not match
+	ErrCorrupted = p2p.ErrCorrupted
+	// ErrExpired is returned by Start when ExpiresAt has already passed
+	ErrExpired = session.ErrExpired
+)
+
+// OptionError reports an invalid field of an options struct
+type OptionError struct {
+	Field  string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalidOptions) true
+func (e *OptionError) Is(target error) bool {
+	return target == ErrInvalidOptions
+}
+
+// checkInterface validates an Interface option
+func checkInterface(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := net.InterfaceByName(name); err != nil {
+		return &OptionError{Field: "Interface", Reason: "no network interface named " + name}
+	}
+	return nil
+}
+
+// sinkFor adapts an OnEvent callback
+func sinkFor(fn func(Event)) events.Sink {
+	if fn == nil {
+		return events.Discard
+	}
+	return events.Func(func(name string, data interface{}) {
+		fn(Event{Name: name, Data: data})
+	})
+}
@@ -0,0 +1,273 @@
+package justserve
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/p2p"
+)
+
+// P2PSenderOptions configures a P2P sender
+type P2PSenderOptions struct {
+	Path      string      // File or folder to send; folders are zipped on the fly
+	Once      bool        // Stop after the first completed transfer instead of waiting for more receivers
+	Interface string      // Serve and announce on this network interface only (see Interfaces), "" for all
+	OnEvent   func(Event) // Optional; see Event
+
+	// The sender stops by itself at ExpiresAt and after MaxDownloads
+	// completed transfers; zero values mean no limit
+	ExpiresAt    time.Time
+	MaxDownloads int
+}
+
+// P2PSender offers a file or folder to receivers on the LAN. It broadcasts a
+// 6-digit code that `justserve receive`, the desktop app and P2PReceiver
+// look for, and serves a download page for browsers.
+type P2PSender struct {
+	opts P2PSenderOptions
+	m    *p2p.Manager
+
+	mu       sync.Mutex
+	info     *TransferInfo
+	started  bool
+	closed   bool
+	err      error
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewP2PSender validates opts and returns a sender that is not yet offering
+func NewP2PSender(opts P2PSenderOptions) (*P2PSender, error) {
+	if opts.Path == "" {
+		return nil, &OptionError{Field: "Path", Reason: "required"}
+	}
+	if _, err := os.Stat(opts.Path); err != nil {
+		return nil, &OptionError{Field: "Path", Reason: err.Error()}
+	}
+	if err := checkInterface(opts.Interface); err != nil {
+		return nil, err
+	}
+	if opts.MaxDownloads < 0 {
+		return nil, &OptionError{Field: "MaxDownloads", Reason: "cannot be negative"}
+	}
+
+	s := &P2PSender{opts: opts, done: make(chan struct{})}
+	user := sinkFor(opts.OnEvent)
+	s.m = p2p.NewManager(events.Func(func(name string, data interface{}) {
+		user.Emit(name, data)
+		switch name {
+		case "p2p-status":
+			ev, _ := data.(p2p.StatusEvent)
+			switch {
+			case ev.Status == "stopped":
+				go s.stop(nil) // Drained after Shutdown or a limit
+			case ev.Status == "completed" && opts.Once:
+				go s.stop(nil)
+			case ev.Status == "completed":
+				s.m.ResetStatus()
+			}
+		case "p2p-error":
+			if e, ok := data.(events.Error); ok {
+				go s.stop(errcode.New(e.Code, e.Message))
+			}
+		}
+	}))
+	return s, nil
+}
+
+// Start begins offering the transfer and returns once the code is known. It
+// stops when ctx is cancelled, Close is called, the server fails, (with
+// Once) after the first completed transfer, or once a limit is reached.
+func (s *P2PSender) Start(ctx context.Context) error {
+	s.mu.Lock()
+	switch {
+	case s.closed:
+		s.mu.Unlock()
+		return ErrClosed
+	case s.started:
+		s.mu.Unlock()
+		return ErrStarted
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		s.stop(err)
+		return err
+	}
+	info, err := s.m.SendWith(s.opts.Path, p2p.SendOptions{
+		Interface:    s.opts.Interface,
+		ExpiresAt:    s.opts.ExpiresAt,
+		MaxDownloads: s.opts.MaxDownloads,
+	})
+	if err != nil {
+		s.stop(err)
+		return err
+	}
+	s.mu.Lock()
+	s.info = info
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.stop(ctx.Err())
+		case <-s.done:
+		}
+	}()
+	return nil
+}
+
+// Code returns the 6-digit transfer code, or "" before Start
+func (s *P2PSender) Code() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.info == nil {
+		return ""
+	}
+	return s.info.Code
+}
+
+// URL returns the address receivers download from, or "" before Start
+func (s *P2PSender) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.info == nil {
+		return ""
+	}
+	return s.info.URL
+}
+
+// Info returns the live transfer state, or nil when not offering
+func (s *P2PSender) Info() *TransferInfo {
+	return s.m.Info()
+}
+
+// Done is closed once the sender has stopped
+func (s *P2PSender) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err tells why the sender stopped: the context error, the server error, or
+// nil after Close or a completed Once transfer
+func (s *P2PSender) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops offering the transfer. It is safe to call more than once.
+func (s *P2PSender) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.stop(nil)
+	return nil
+}
+
+// Shutdown stops offering the transfer gracefully: announcements end, new
+// downloads are refused and Shutdown waits for the download in progress. If
+// ctx ends first the download is cut off and ctx.Err() is returned.
+func (s *P2PSender) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.m.DrainTransfer(untilDone(ctx))
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		s.stop(nil)
+		return nil
+	case <-ctx.Done():
+		s.stop(nil) // Ends DrainTransfer
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (s *P2PSender) stop(err error) {
+	s.doneOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		s.m.StopTransfer()
+		close(s.done)
+	})
+}
+
+// P2PReceiverOptions configures a P2P receiver
+type P2PReceiverOptions struct {
+	Code             string        // Transfer code shown by the sender
+	Address          string        // Sender URL (e.g. http://192.168.1.20:41000); skips discovery
+	Dir              string        // Destination folder, "" for the working directory
+	DiscoveryTimeout time.Duration // How long to look for the sender, 0 for 30 seconds
+
+	// OnProgress is called as data arrives; total is -1 for folders, whose
+	// size is only known once the zip is complete
+	OnProgress func(received, total int64)
+}
+
+// P2PReceiver downloads a transfer offered by a P2PSender, `justserve send`
+// or the desktop app. Files are checked against the sender's size and
+// SHA-256; folders are verified and extracted.
+type P2PReceiver struct {
+	opts P2PReceiverOptions
+}
+
+// NewP2PReceiver validates opts
+func NewP2PReceiver(opts P2PReceiverOptions) (*P2PReceiver, error) {
+	opts.Code = strings.TrimSpace(opts.Code)
+	if opts.Code == "" && opts.Address == "" {
+		return nil, &OptionError{Field: "Code", Reason: "a transfer code or Address is required"}
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.DiscoveryTimeout <= 0 {
+		opts.DiscoveryTimeout = 30 * time.Second
+	}
+	return &P2PReceiver{opts: opts}, nil
+}
+
+// Receive finds the sender, downloads the transfer and returns the saved
+// path. Names that already exist get a " (1)" suffix. Cancelling ctx aborts
+// the download and removes partial data.
+func (r *P2PReceiver) Receive(ctx context.Context) (string, error) {
+	m := p2p.NewManager(nil)
+	address := r.opts.Address
+	if address == "" {
+		timeout := r.opts.DiscoveryTimeout
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+		}
+		peer, err := m.FindPeer(r.opts.Code, timeout)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return "", ctxErr
+			}
+			return "", err
+		}
+		address = peer.URL
+	}
+
+	if r.opts.Code != "" {
+		info, err := p2p.FetchPeerInfo(address)
+		if err != nil {
+			return "", err
+		}
+		if info.Code != r.opts.Code {
+			return "", fmt.Errorf("%w: %s offers %s", ErrCodeMismatch, address, info.Code)
+		}
+	}
+	return m.Receive(ctx, address, r.opts.Dir, r.opts.OnProgress)
+}
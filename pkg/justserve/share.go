@@ -0,0 +1,308 @@
+package justserve
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/session"
+)
+
+// ShareOptions configures a file share
+type ShareOptions struct {
+	Path         string       // File or folder to share
+	Mounts       []Mount      // Several folders, each under /<Name>; used instead of Path
+	Port         string       // Local port, "" for 8080; ignored for public shares
+	PortFallback int          // Try up to this many following ports when Port is busy
+	Interface    string       // Listen on this network interface only (see Interfaces), "" for all
+	Password     string       // HTTP basic auth password, empty for none
+	AllowUpload  bool         // Accept uploads into Path (Mounts set this per mount)
+	Upload       UploadLimits // Size, quota, type and free-space limits for uploads
+	Public       bool         // Serve through an ngrok tunnel instead of a local port
+	NgrokToken   string       // Required when Public is set
+	ExpiresAt    time.Time    // Stop by itself at this time, zero for never
+	MaxDownloads int          // Stop by itself after this many completed downloads, 0 for no limit
+	StatsFile    string       // Write the download stats here on stop, CSV for .csv else JSON; "" for none
+	OnEvent      func(Event)  // Optional; see Event
+}
+
+// Share serves files over HTTP, locally or through ngrok
+type Share struct {
+	*runner
+}
+
+// NewShare validates opts and returns a share that is not yet serving
+func NewShare(opts ShareOptions) (*Share, error) {
+	switch {
+	case opts.Path == "" && len(opts.Mounts) == 0:
+		return nil, &OptionError{Field: "Path", Reason: "a file or folder, or Mounts, is required"}
+	case opts.Path != "" && len(opts.Mounts) > 0:
+		return nil, &OptionError{Field: "Mounts", Reason: "cannot be combined with Path"}
+	case opts.Public && opts.NgrokToken == "":
+		return nil, &OptionError{Field: "NgrokToken", Reason: "required for public shares"}
+	case opts.Public && opts.Interface != "":
+		return nil, &OptionError{Field: "Interface", Reason: "only applies to local shares"}
+	case opts.PortFallback < 0:
+		return nil, &OptionError{Field: "PortFallback", Reason: "cannot be negative"}
+	case opts.MaxDownloads < 0:
+		return nil, &OptionError{Field: "MaxDownloads", Reason: "cannot be negative"}
+	}
+	if err := checkInterface(opts.Interface); err != nil {
+		return nil, err
+	}
+	if opts.Path != "" {
+		if _, err := os.Stat(opts.Path); err != nil {
+			return nil, &OptionError{Field: "Path", Reason: err.Error()}
+		}
+	}
+
+	cfg := session.ShareConfig{
+		Path:         opts.Path,
+		Mounts:       opts.Mounts,
+		Port:         opts.Port,
+		PortFallback: opts.PortFallback,
+		Interface:    opts.Interface,
+		Password:     opts.Password,
+		AllowUpload:  opts.AllowUpload,
+		Upload:       opts.Upload,
+		Public:       opts.Public,
+		NgrokToken:   opts.NgrokToken,
+		ExpiresAt:    opts.ExpiresAt,
+		MaxDownloads: opts.MaxDownloads,
+		StatsFile:    opts.StatsFile,
+	}
+	return &Share{newRunner(opts.OnEvent, func(m *session.Manager) (*session.Session, error) {
+		return m.StartShare(cfg)
+	})}, nil
+}
+
+// Downloads returns the completed downloads by path, most downloaded first;
+// nil before Start
+func (s *Share) Downloads() []FileStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.s == nil {
+		return nil
+	}
+	return s.s.Downloads()
+}
+
+// ProxyOptions configures a port proxy
+type ProxyOptions struct {
+	Port       string      // Local port to expose
+	Protocol   string      // "http" (public ngrok URL, the default) or "tcp" (LAN listener)
+	NgrokToken string      // Required for HTTP proxies
+	OnEvent    func(Event) // Optional; see Event
+}
+
+// Proxy exposes a local port publicly (HTTP) or on the LAN (TCP)
+type Proxy struct {
+	*runner
+}
+
+// NewProxy validates opts and returns a proxy that is not yet running
+func NewProxy(opts ProxyOptions) (*Proxy, error) {
+	if opts.Protocol == "" {
+		opts.Protocol = "http"
+	}
+	switch {
+	case opts.Port == "":
+		return nil, &OptionError{Field: "Port", Reason: "required"}
+	case opts.Protocol != "http" && opts.Protocol != "tcp":
+		return nil, &OptionError{Field: "Protocol", Reason: `must be "http" or "tcp"`}
+	case opts.Protocol == "http" && opts.NgrokToken == "":
+		return nil, &OptionError{Field: "NgrokToken", Reason: "required for HTTP proxies"}
+	}
+
+	cfg := session.ProxyConfig{Port: opts.Port, Protocol: opts.Protocol, NgrokToken: opts.NgrokToken}
+	return &Proxy{newRunner(opts.OnEvent, func(m *session.Manager) (*session.Session, error) {
+		return m.StartProxy(cfg)
+	})}, nil
+}
+
+// runner runs a single session on its own session.Manager. It implements the
+// lifecycle shared by Share and Proxy.
+type runner struct {
+	onEvent func(Event)
+	start   func(m *session.Manager) (*session.Session, error)
+
+	mu       sync.Mutex
+	m        *session.Manager
+	s        *session.Session
+	started  bool
+	closed   bool
+	err      error
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newRunner(onEvent func(Event), start func(m *session.Manager) (*session.Session, error)) *runner {
+	return &runner{onEvent: onEvent, start: start, done: make(chan struct{})}
+}
+
+// Start begins serving and returns once the URL is known. Serving stops when
+// ctx is cancelled, Close is called, the server fails, or a share reaches
+// ExpiresAt or MaxDownloads.
+func (r *runner) Start(ctx context.Context) error {
+	r.mu.Lock()
+	switch {
+	case r.closed:
+		r.mu.Unlock()
+		return ErrClosed
+	case r.started:
+		r.mu.Unlock()
+		return ErrStarted
+	}
+	r.started = true
+	r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		r.stopped(err)
+		return err
+	}
+
+	user := sinkFor(r.onEvent)
+	m := session.NewManager(events.Func(func(name string, data interface{}) {
+		user.Emit(name, data)
+		switch name {
+		case "server-error":
+			if e, ok := data.(events.Error); ok {
+				r.setErr(errcode.New(e.Code, e.Message))
+			}
+		case "session-stopped":
+			r.stopped(nil)
+		}
+	}))
+	s, err := r.start(m)
+	if err != nil {
+		r.stopped(err)
+		return err
+	}
+
+	r.mu.Lock()
+	r.m, r.s = m, s
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		// Close ran while the session was starting
+		m.StopAll()
+		return ErrClosed
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.setErr(ctx.Err())
+			m.StopAll()
+		case <-r.done:
+		}
+	}()
+	return nil
+}
+
+// URL returns the address to share, or "" before Start
+func (r *runner) URL() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.s == nil {
+		return ""
+	}
+	return r.s.Info().URLs[0]
+}
+
+// Info returns the session details and traffic counters, or the zero Info
+// before Start
+func (r *runner) Info() Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.s == nil {
+		return Info{}
+	}
+	return r.s.Info()
+}
+
+// Done is closed once serving has stopped
+func (r *runner) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err tells why serving stopped: the context error, the server error, or nil
+// after Close. It is nil while still serving.
+func (r *runner) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stops serving and waits until the server is down. It is safe to call
+// more than once.
+func (r *runner) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	m := r.m
+	r.mu.Unlock()
+
+	if m == nil {
+		r.stopped(nil)
+	} else {
+		m.StopAll()
+	}
+	<-r.done
+	return nil
+}
+
+// Shutdown stops serving gracefully: new requests and connections are
+// refused ("session-draining" is sent) and Shutdown waits for the transfers
+// in progress, then closes. If ctx ends first the remaining transfers are cut
+// off and ctx.Err() is returned.
+func (r *runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	m, s := r.m, r.s
+	r.mu.Unlock()
+
+	if m == nil {
+		r.stopped(nil)
+		return nil
+	}
+	m.Drain(s.ID(), untilDone(ctx))
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		m.StopAll()
+		<-r.done
+		return ctx.Err()
+	}
+}
+
+// untilDone is the drain timeout for ctx: the time to its deadline, or
+// practically forever when it has none
+func untilDone(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d > 0 {
+			return d
+		}
+		return time.Nanosecond
+	}
+	return 100 * 365 * 24 * time.Hour
+}
+
+// setErr records the first reason serving stopped
+func (r *runner) setErr(err error) {
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mu.Unlock()
+}
+
+func (r *runner) stopped(err error) {
+	if err != nil {
+		r.setErr(err)
+	}
+	r.doneOnce.Do(func() { close(r.done) })
+}
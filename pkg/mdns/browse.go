@@ -0,0 +1,269 @@
+package mdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"JustServe/pkg/utils"
+)
+
+// Service is an HTTP service found on the local network
+type Service struct {
+	Instance  string   `json:"instance"`  // e.g. "JustServe on desk – Builds"
+	Host      string   `json:"host"`      // e.g. "desk.local"
+	Port      int      `json:"port"`      // TCP port
+	Addrs     []string `json:"addrs"`     // Addresses of Host, IPv4 first; link-local IPv6 ones carry a zone
+	URL       string   `json:"url"`       // Ready to open, built from the first address
+	JustServe bool     `json:"justServe"` // Advertised by JustServe
+}
+
+// Browse queries the local network for _http._tcp services until ctx is
+// done, over IPv4 and IPv6. It uses one-shot queries from ephemeral ports, so
+// it works next to the system responder and to a Responder in the same
+// process.
+func Browse(ctx context.Context) ([]Service, error) {
+	var conns []*net.UDPConn
+	conn4, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err == nil {
+		conns = append(conns, conn4)
+	}
+	conn6, err6 := net.ListenUDP("udp6", &net.UDPAddr{})
+	if err6 == nil {
+		conns = append(conns, conn6)
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("mdns: %w", err)
+	}
+	for _, conn := range conns {
+		defer conn.Close()
+	}
+
+	type instance struct {
+		host string
+		port int
+		txt  []string
+		src  *net.UDPAddr // Fallback when the host has no address record
+	}
+	instances := make(map[string]*instance) // By instance FQDN
+	hosts := make(map[string][]string)      // Lowercase host FQDN -> addresses
+	id := uint16(os.Getpid())
+
+	// ask queries the services, then the records still missing for each
+	// instance; not every responder sends them as additional records
+	ask := func() error {
+		qs := []dnsmessage.Question{question(serviceName, dnsmessage.TypePTR)}
+		for name, in := range instances {
+			switch {
+			case in.port == 0:
+				qs = append(qs, question(name, dnsmessage.TypeSRV))
+			case len(hosts[strings.ToLower(in.host)]) == 0:
+				qs = append(qs, question(in.host, dnsmessage.TypeA), question(in.host, dnsmessage.TypeAAAA))
+			}
+		}
+		q, err := query(id, qs...)
+		if err != nil {
+			return err
+		}
+		sent := false
+		if conn4 != nil {
+			if _, err := conn4.WriteToUDP(q, groupAddr); err == nil {
+				sent = true
+			}
+		}
+		if conn6 != nil {
+			// ff02::fb is link-local, so the query goes out on each interface
+			for _, ifi := range multicastInterfaces() {
+				if _, err := conn6.WriteToUDP(q, &net.UDPAddr{IP: groupAddr6.IP, Port: mdnsPort, Zone: ifi.Name}); err == nil {
+					sent = true
+				}
+			}
+		}
+		if !sent {
+			return errors.New("no interface to send the query on")
+		}
+		return nil
+	}
+	if err := ask(); err != nil {
+		return nil, fmt.Errorf("mdns: %w", err)
+	}
+
+	type packet struct {
+		data []byte
+		src  *net.UDPAddr
+	}
+	packets := make(chan packet)
+	done := make(chan struct{})
+	defer close(done)
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			for {
+				buf := make([]byte, 9000)
+				n, src, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return // Closed when Browse returns
+				}
+				select {
+				case packets <- packet{buf[:n], src}:
+				case <-done:
+					return
+				}
+			}
+		}(conn)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		var pkt packet
+		select {
+		case <-ctx.Done():
+			continue
+		case <-ticker.C:
+			ask()
+			continue
+		case pkt = <-packets:
+		}
+
+		var p dnsmessage.Parser
+		h, err := p.Start(pkt.data)
+		if err != nil || !h.Response {
+			continue
+		}
+		if err := p.SkipAllQuestions(); err != nil {
+			continue
+		}
+		answers, _ := p.AllAnswers()
+		p.SkipAllAuthorities()
+		extra, _ := p.AllAdditionals()
+
+		get := func(name string) *instance {
+			in := instances[name]
+			if in == nil {
+				in = &instance{}
+				instances[name] = in
+			}
+			return in
+		}
+		addHost := func(name string, ip net.IP) {
+			addr := ip.String()
+			if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+				if pkt.src.Zone == "" {
+					return // Unusable without the interface it is reachable on
+				}
+				addr += "%" + pkt.src.Zone
+			}
+			key := strings.ToLower(name)
+			if !contains(hosts[key], addr) {
+				hosts[key] = append(hosts[key], addr)
+			}
+		}
+		for _, rr := range append(answers, extra...) {
+			name := rr.Header.Name.String()
+			switch body := rr.Body.(type) {
+			case *dnsmessage.PTRResource:
+				if !strings.EqualFold(name, serviceName) {
+					break
+				}
+				if rr.Header.TTL == 0 {
+					delete(instances, body.PTR.String()) // Goodbye
+				} else {
+					get(body.PTR.String())
+				}
+			case *dnsmessage.SRVResource:
+				if strings.HasSuffix(strings.ToLower(name), serviceName) {
+					in := get(name)
+					in.host, in.port, in.src = body.Target.String(), int(body.Port), pkt.src
+				}
+			case *dnsmessage.TXTResource:
+				if strings.HasSuffix(strings.ToLower(name), serviceName) {
+					get(name).txt = body.TXT
+				}
+			case *dnsmessage.AResource:
+				addHost(name, net.IP(body.A[:]))
+			case *dnsmessage.AAAAResource:
+				addHost(name, net.IP(body.AAAA[:]))
+			}
+		}
+	}
+
+	var list []Service
+	for name, in := range instances {
+		if in.port == 0 {
+			continue
+		}
+		addrs := hosts[strings.ToLower(in.host)]
+		if len(addrs) == 0 && in.src != nil {
+			addr := in.src.IP.String()
+			if in.src.Zone != "" {
+				addr += "%" + in.src.Zone
+			}
+			addrs = []string{addr}
+		}
+		sortAddrs(addrs)
+		if len(addrs) == 0 {
+			continue
+		}
+		svc := Service{
+			Instance: strings.TrimSuffix(name, "."+serviceName),
+			Host:     strings.TrimSuffix(in.host, "."),
+			Port:     in.port,
+			Addrs:    addrs,
+		}
+		path := "/"
+		for _, kv := range in.txt {
+			key, value, _ := strings.Cut(kv, "=")
+			switch strings.ToLower(key) {
+			case "path":
+				if strings.HasPrefix(value, "/") {
+					path = value
+				}
+			case "app":
+				svc.JustServe = value == "justserve"
+			}
+		}
+		svc.URL = "http://" + utils.HostPort(addrs[0], in.port) + path
+		list = append(list, svc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].JustServe != list[j].JustServe {
+			return list[i].JustServe
+		}
+		return list[i].Instance < list[j].Instance
+	})
+	return list, nil
+}
+
+// sortAddrs puts IPv4 addresses first and link-local IPv6 ones last
+func sortAddrs(addrs []string) {
+	rank := func(addr string) int {
+		switch {
+		case !strings.Contains(addr, ":"):
+			return 0
+		case strings.Contains(addr, "%"):
+			return 2
+		}
+		return 1
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return rank(addrs[i]) < rank(addrs[j]) })
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
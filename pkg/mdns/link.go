@@ -0,0 +1,79 @@
+package mdns
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// link is the mDNS socket of one IP version
+type link struct {
+	conn  *net.UDPConn
+	group *net.UDPAddr
+	pc4   *ipv4.PacketConn // Set for IPv4
+	pc6   *ipv6.PacketConn // Set for IPv6
+}
+
+func listen4() (*link, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return nil, err
+	}
+	pc := ipv4.NewPacketConn(conn)
+	for _, ifi := range multicastInterfaces() {
+		pc.JoinGroup(&ifi, groupAddr) // Fails for interfaces already joined by ListenMulticastUDP
+	}
+	pc.SetMulticastTTL(255)
+	pc.SetMulticastLoopback(true)
+	// Not supported on Windows; answers then list the addresses of every interface
+	pc.SetControlMessage(ipv4.FlagInterface, true)
+	return &link{conn: conn, group: groupAddr, pc4: pc}, nil
+}
+
+func listen6() (*link, error) {
+	conn, err := net.ListenMulticastUDP("udp6", nil, groupAddr6)
+	if err != nil {
+		return nil, err
+	}
+	pc := ipv6.NewPacketConn(conn)
+	for _, ifi := range multicastInterfaces() {
+		pc.JoinGroup(&ifi, groupAddr6)
+	}
+	pc.SetMulticastHopLimit(255)
+	pc.SetMulticastLoopback(true)
+	pc.SetControlMessage(ipv6.FlagInterface, true)
+	return &link{conn: conn, group: groupAddr6, pc6: pc}, nil
+}
+
+// readFrom reads a packet and the index of the interface it arrived on, 0
+// when the platform does not tell
+func (l *link) readFrom(buf []byte) (n int, ifIndex int, src net.Addr, err error) {
+	if l.pc4 != nil {
+		var cm *ipv4.ControlMessage
+		n, cm, src, err = l.pc4.ReadFrom(buf)
+		if cm != nil {
+			ifIndex = cm.IfIndex
+		}
+		return n, ifIndex, src, err
+	}
+	var cm *ipv6.ControlMessage
+	n, cm, src, err = l.pc6.ReadFrom(buf)
+	if cm != nil {
+		ifIndex = cm.IfIndex
+	}
+	return n, ifIndex, src, err
+}
+
+// sendOn multicasts msg to the group on ifi
+func (l *link) sendOn(ifi *net.Interface, msg []byte) {
+	if l.pc4 != nil {
+		if l.pc4.SetMulticastInterface(ifi) == nil {
+			l.pc4.WriteTo(msg, nil, l.group)
+		}
+		return
+	}
+	if l.pc6.SetMulticastInterface(ifi) == nil {
+		l.pc6.WriteTo(msg, nil, l.group)
+	}
+}
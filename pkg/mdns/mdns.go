@@ -0,0 +1,552 @@
+// Package mdns advertises local shares with multicast DNS and DNS-SD (RFC 6762,
+// RFC 6763) as _http._tcp services, so browsers, Finder and other JustServe
+// instances find them without knowing the IP. It also browses for services
+// advertised by others.
+package mdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"JustServe/pkg/utils"
+)
+
+// Alias is the optional extra hostname published with Options.Alias
+const Alias = "justserve.local"
+
+const (
+	mdnsPort    = 5353
+	serviceName = "_http._tcp.local."
+	metaQuery   = "_services._dns-sd._udp.local."
+
+	hostTTL    = 120  // A, AAAA and SRV records (RFC 6762 section 10)
+	serviceTTL = 4500 // PTR and TXT records
+	legacyTTL  = 10   // Answers to one-shot queries from ports other than 5353
+
+	cacheFlush = 1 << 15 // Class bit marking records this host owns alone
+)
+
+var (
+	groupAddr  = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+	groupAddr6 = &net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: mdnsPort}
+)
+
+// txt is published with every service. path is the standard _http._tcp key;
+// app lets other JustServe instances tell their shares apart.
+var txt = []string{"path=/", "app=justserve"}
+
+// Options configures a Responder
+type Options struct {
+	Alias bool // Also answer for justserve.local, unless another device already does
+}
+
+// Responder answers mDNS queries for the advertised shares
+type Responder struct {
+	links []*link // IPv4 and IPv6, whichever could be opened
+	label string  // Hostname without domain, used in instance names
+	host  string  // "<label>.local."
+
+	sendMu sync.Mutex // Serializes SetMulticastInterface and WriteTo
+
+	mu       sync.Mutex
+	alias    string // "justserve.local." once probed
+	probing  string
+	conflict bool
+	services map[string]*service // By lowercase instance FQDN
+	closed   bool
+	done     chan struct{}
+}
+
+type service struct {
+	instance string // Instance label, e.g. "JustServe on desk – Builds"
+	fqdn     string
+	port     uint16
+}
+
+// Start joins the IPv4 and IPv6 mDNS groups on every multicast interface and
+// starts answering queries. It shares port 5353 with the system responder and
+// fails only when neither group can be joined.
+func Start(opts Options) (*Responder, error) {
+	var links []*link
+	l4, err := listen4()
+	if err == nil {
+		links = append(links, l4)
+	}
+	if l6, err6 := listen6(); err6 == nil {
+		links = append(links, l6)
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("mdns: %w", err)
+	}
+
+	label := hostLabel()
+	r := &Responder{
+		links:    links,
+		label:    label,
+		host:     label + ".local.",
+		services: make(map[string]*service),
+		done:     make(chan struct{}),
+	}
+	for _, l := range links {
+		go r.serve(l)
+	}
+	if opts.Alias {
+		go r.probeAlias()
+	}
+	return r, nil
+}
+
+// Advertise publishes a share listening on port as "JustServe on <host> –
+// <name>" and returns a function that withdraws it
+func (r *Responder) Advertise(name string, port int) (withdraw func()) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return func() {}
+	}
+	base := instanceLabel(fmt.Sprintf("JustServe on %s – %s", r.label, name))
+	svc := &service{instance: base, port: uint16(port)}
+	for i := 2; ; i++ {
+		svc.fqdn = svc.instance + "." + serviceName
+		if _, taken := r.services[strings.ToLower(svc.fqdn)]; !taken {
+			break
+		}
+		svc.instance = instanceLabel(fmt.Sprintf("%s (%d)", base, i))
+	}
+	r.services[strings.ToLower(svc.fqdn)] = svc
+	r.mu.Unlock()
+
+	go r.announce(svc)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.services, strings.ToLower(svc.fqdn))
+			closed := r.closed
+			r.mu.Unlock()
+			if !closed {
+				r.goodbye(svc)
+			}
+		})
+	}
+}
+
+// Hostname returns the .local name the shares point to
+func (r *Responder) Hostname() string {
+	return strings.TrimSuffix(r.host, ".")
+}
+
+// Alias returns "justserve.local" once it is published, or ""
+func (r *Responder) Alias() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.TrimSuffix(r.alias, ".")
+}
+
+// AliasConflict reports whether another device answered for justserve.local
+func (r *Responder) AliasConflict() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conflict
+}
+
+// Close withdraws every service and stops answering
+func (r *Responder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	services := make([]*service, 0, len(r.services))
+	for _, svc := range r.services {
+		services = append(services, svc)
+	}
+	r.services = map[string]*service{}
+	r.mu.Unlock()
+
+	for _, svc := range services {
+		r.goodbye(svc)
+	}
+	close(r.done)
+	for _, l := range r.links {
+		l.conn.Close()
+	}
+	return nil
+}
+
+// probeAlias claims justserve.local if nobody answers three queries for it
+func (r *Responder) probeAlias() {
+	name := Alias + "."
+	r.mu.Lock()
+	r.probing = name
+	r.mu.Unlock()
+
+	q, err := query(0, question(name, dnsmessage.TypeA))
+	if err != nil {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		r.sendAll(func(*net.Interface) []byte { return q })
+		select {
+		case <-time.After(250 * time.Millisecond):
+		case <-r.done:
+			return
+		}
+	}
+
+	r.mu.Lock()
+	r.probing = ""
+	if !r.conflict {
+		r.alias = name
+	}
+	r.mu.Unlock()
+}
+
+func (r *Responder) serve(l *link) {
+	buf := make([]byte, 9000)
+	for {
+		n, ifIndex, src, err := l.readFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		udp, ok := src.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		r.handle(l, buf[:n], udp, ifIndex)
+	}
+}
+
+func (r *Responder) handle(l *link, msg []byte, src *net.UDPAddr, ifIndex int) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+	if h.Response {
+		r.checkConflict(&p)
+		return
+	}
+
+	// Queries from a port other than 5353 come from simple resolvers that
+	// expect a unicast reply echoing the query (RFC 6762 section 6.7)
+	legacy := src.Port != mdnsPort
+	ttl := func(t uint32) uint32 {
+		if legacy {
+			return min(t, legacyTTL)
+		}
+		return t
+	}
+	build := func(ifi *net.Interface) []byte {
+		answers, extra := r.answer(questions, addrsOf(ifi), ttl)
+		if len(answers) == 0 {
+			return nil
+		}
+		m := dnsmessage.Message{
+			Header:      dnsmessage.Header{Response: true, Authoritative: true},
+			Answers:     answers,
+			Additionals: extra,
+		}
+		if legacy {
+			m.Header.ID = h.ID
+			m.Questions = questions
+		}
+		b, err := m.Pack()
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+
+	if legacy {
+		var ifi *net.Interface
+		if ifIndex != 0 {
+			ifi, _ = net.InterfaceByIndex(ifIndex)
+		}
+		if b := build(ifi); b != nil {
+			l.conn.WriteToUDP(b, src)
+		}
+		return
+	}
+	if ifIndex != 0 {
+		if ifi, err := net.InterfaceByIndex(ifIndex); err == nil {
+			r.sendOn(l, ifi, build(ifi))
+			return
+		}
+	}
+	for _, ifi := range multicastInterfaces() {
+		r.sendOn(l, &ifi, build(&ifi))
+	}
+}
+
+// answer builds the records for questions. addrs are the addresses to publish
+// for the host names.
+func (r *Responder) answer(questions []dnsmessage.Question, addrs []net.IP, ttl func(uint32) uint32) (answers, extra []dnsmessage.Resource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hostExtra := false
+	for _, q := range questions {
+		name := strings.ToLower(q.Name.String())
+		all := q.Type == dnsmessage.TypeALL
+		switch {
+		case name == metaQuery && (q.Type == dnsmessage.TypePTR || all) && len(r.services) > 0:
+			answers = append(answers, ptrRecord(metaQuery, serviceName, ttl(serviceTTL)))
+		case name == serviceName && (q.Type == dnsmessage.TypePTR || all):
+			for _, svc := range r.services {
+				answers = append(answers, ptrRecord(serviceName, svc.fqdn, ttl(serviceTTL)))
+				extra = append(extra, r.srvRecord(svc, ttl(hostTTL)), txtRecord(svc, ttl(serviceTTL)))
+				hostExtra = true
+			}
+		case r.services[name] != nil:
+			svc := r.services[name]
+			if q.Type == dnsmessage.TypeSRV || all {
+				answers = append(answers, r.srvRecord(svc, ttl(hostTTL)))
+				hostExtra = true
+			}
+			if q.Type == dnsmessage.TypeTXT || all {
+				answers = append(answers, txtRecord(svc, ttl(serviceTTL)))
+			}
+		case name == strings.ToLower(r.host) || (r.alias != "" && name == r.alias):
+			answers = append(answers, addrRecords(q.Name.String(), addrs, q.Type, ttl(hostTTL))...)
+		}
+	}
+	if hostExtra {
+		extra = append(extra, addrRecords(r.host, addrs, dnsmessage.TypeALL, ttl(hostTTL))...)
+	}
+	return answers, extra
+}
+
+// checkConflict notes an answer for the alias being probed
+func (r *Responder) checkConflict(p *dnsmessage.Parser) {
+	r.mu.Lock()
+	probing := r.probing
+	r.mu.Unlock()
+	if probing == "" {
+		return
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return
+	}
+	for _, a := range answers {
+		if strings.EqualFold(a.Header.Name.String(), probing) {
+			r.mu.Lock()
+			r.conflict = true
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+// announce sends unsolicited responses for a new service, twice as RFC 6762
+// section 8.3 asks, so caches pick it up without querying
+func (r *Responder) announce(svc *service) {
+	for i := 0; i < 2; i++ {
+		r.sendAll(func(ifi *net.Interface) []byte {
+			return r.serviceMessage(svc, addrsOf(ifi), false)
+		})
+		select {
+		case <-time.After(time.Second):
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// goodbye tells caches to drop a withdrawn service
+func (r *Responder) goodbye(svc *service) {
+	r.sendAll(func(*net.Interface) []byte {
+		return r.serviceMessage(svc, nil, true)
+	})
+}
+
+func (r *Responder) serviceMessage(svc *service, addrs []net.IP, goodbye bool) []byte {
+	ttl := func(t uint32) uint32 {
+		if goodbye {
+			return 0
+		}
+		return t
+	}
+	m := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{
+			ptrRecord(serviceName, svc.fqdn, ttl(serviceTTL)),
+			r.srvRecord(svc, ttl(hostTTL)),
+			txtRecord(svc, ttl(serviceTTL)),
+		},
+	}
+	if !goodbye {
+		m.Additionals = addrRecords(r.host, addrs, dnsmessage.TypeALL, hostTTL)
+	}
+	b, err := m.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// sendAll sends the message built for each multicast interface to both groups
+func (r *Responder) sendAll(build func(ifi *net.Interface) []byte) {
+	for _, ifi := range multicastInterfaces() {
+		msg := build(&ifi)
+		for _, l := range r.links {
+			r.sendOn(l, &ifi, msg)
+		}
+	}
+}
+
+func (r *Responder) sendOn(l *link, ifi *net.Interface, msg []byte) {
+	if msg == nil {
+		return
+	}
+	r.sendMu.Lock()
+	defer r.sendMu.Unlock()
+	l.sendOn(ifi, msg)
+}
+
+func (r *Responder) srvRecord(svc *service, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: header(svc.fqdn, ttl, true),
+		Body:   &dnsmessage.SRVResource{Port: svc.port, Target: mustName(r.host)},
+	}
+}
+
+func ptrRecord(name, target string, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: header(name, ttl, false),
+		Body:   &dnsmessage.PTRResource{PTR: mustName(target)},
+	}
+}
+
+func txtRecord(svc *service, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: header(svc.fqdn, ttl, true),
+		Body:   &dnsmessage.TXTResource{TXT: txt},
+	}
+}
+
+// addrRecords returns the A and AAAA records of addrs asked for by qtype.
+// They are shared with other responders for the same host (e.g. Avahi
+// publishing <host>.local), so the cache-flush bit is left off.
+func addrRecords(name string, addrs []net.IP, qtype dnsmessage.Type, ttl uint32) []dnsmessage.Resource {
+	var records []dnsmessage.Resource
+	for _, ip := range addrs {
+		var body dnsmessage.ResourceBody
+		if ip4 := ip.To4(); ip4 != nil {
+			if qtype != dnsmessage.TypeA && qtype != dnsmessage.TypeALL {
+				continue
+			}
+			var a [4]byte
+			copy(a[:], ip4)
+			body = &dnsmessage.AResource{A: a}
+		} else {
+			if qtype != dnsmessage.TypeAAAA && qtype != dnsmessage.TypeALL {
+				continue
+			}
+			var aaaa [16]byte
+			copy(aaaa[:], ip)
+			body = &dnsmessage.AAAAResource{AAAA: aaaa}
+		}
+		records = append(records, dnsmessage.Resource{Header: header(name, ttl, false), Body: body})
+	}
+	return records
+}
+
+func header(name string, ttl uint32, unique bool) dnsmessage.ResourceHeader {
+	class := dnsmessage.ClassINET
+	if unique {
+		class |= cacheFlush
+	}
+	return dnsmessage.ResourceHeader{Name: mustName(name), Class: class, TTL: ttl}
+}
+
+func question(name string, t dnsmessage.Type) dnsmessage.Question {
+	return dnsmessage.Question{Name: mustName(name), Type: t, Class: dnsmessage.ClassINET}
+}
+
+func query(id uint16, questions ...dnsmessage.Question) ([]byte, error) {
+	m := dnsmessage.Message{Header: dnsmessage.Header{ID: id}, Questions: questions}
+	return m.Pack()
+}
+
+// mustName converts names built by this package, which are always valid
+func mustName(name string) dnsmessage.Name {
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// multicastInterfaces lists the interfaces that are up, can multicast and
+// have an address
+func multicastInterfaces() []net.Interface {
+	var list []net.Interface
+	for _, ifi := range utils.MulticastInterfaces() {
+		if len(addrsOf(&ifi)) > 0 {
+			list = append(list, ifi)
+		}
+	}
+	return list
+}
+
+// addrsOf returns the IPv4 and IPv6 addresses of ifi, or of every interface
+// when ifi is nil
+func addrsOf(ifi *net.Interface) []net.IP {
+	var addrs []net.Addr
+	var err error
+	if ifi != nil {
+		addrs, err = ifi.Addrs()
+	} else {
+		addrs, err = net.InterfaceAddrs()
+	}
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips
+}
+
+// hostLabel returns the first label of the hostname
+func hostLabel() string {
+	name, _ := os.Hostname()
+	name, _, _ = strings.Cut(name, ".")
+	if name == "" {
+		return "justserve-host"
+	}
+	return name
+}
+
+// instanceLabel makes s usable as a single DNS label: dots would split it and
+// labels hold at most 63 bytes
+func instanceLabel(s string) string {
+	s = strings.ReplaceAll(s, ".", "_")
+	for len(s) > 63 {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+// Package metrics serves counters of the running shares, proxies and P2P
+// transfers in the Prometheus text format, on a loopback port of its own so
+// that shares never expose them.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port /metrics listens on unless configured otherwise
+const DefaultPort = 9464
+
+// contentType is the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source writes metric families to w; see Writer
+type Source interface {
+	WriteMetrics(w *Writer)
+}
+
+// SourceFunc adapts a plain function to a Source
+type SourceFunc func(w *Writer)
+
+// WriteMetrics calls f
+func (f SourceFunc) WriteMetrics(w *Writer) {
+	f(w)
+}
+
+// Writer writes metrics in the Prometheus text format. Each family is
+// started with Family and followed by its samples.
+type Writer struct {
+	out    *bufio.Writer
+	family string
+}
+
+// NewWriter returns a Writer to out; call Flush when done
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: bufio.NewWriter(out)}
+}
+
+// Family starts a metric family. typ is "counter" or "gauge".
+func (w *Writer) Family(name, typ, help string) {
+	w.family = name
+	fmt.Fprintf(w.out, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes a sample of the current family. labels are name, value pairs.
+func (w *Writer) Sample(value float64, labels ...string) {
+	w.out.WriteString(w.family)
+	if len(labels) > 0 {
+		w.out.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.out.WriteByte(',')
+			}
+			fmt.Fprintf(w.out, `%s="%s"`, labels[i], escapeLabel(labels[i+1]))
+		}
+		w.out.WriteByte('}')
+	}
+	w.out.WriteByte(' ')
+	w.out.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.out.WriteByte('\n')
+}
+
+// Flush writes any buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.out.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+// Handler serves the metrics of sources, in order
+func Handler(sources ...Source) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rw.Header().Set("Content-Type", contentType)
+		w := NewWriter(rw)
+		for _, s := range sources {
+			s.WriteMetrics(w)
+		}
+		w.Flush()
+	})
+}
+
+// Server serves /metrics on the loopback interface
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// Start listens on 127.0.0.1:port (0 picks a free port) and serves the
+// metrics of sources at /metrics
+func Start(port int, sources ...Source) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start metrics endpoint: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(sources...))
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go s.srv.Serve(listener)
+	return s, nil
+}
+
+// URL returns the address of the metrics endpoint
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String() + "/metrics"
+}
+
+// Close stops serving
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+	}
+}
@@ -2,24 +2,38 @@ package p2p
 
 import (
 	"archive/zip"
-	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"html/template"
 
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/qr"
 	"JustServe/pkg/utils"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"golang.org/x/net/ipv6"
 )
 
+// discoveryPort is the UDP port senders announce transfers on
+const discoveryPort = 41234
+
+// discoveryGroup6 is the link-local multicast group senders announce on over
+// IPv6, which has no broadcast
+var discoveryGroup6 = net.ParseIP("ff02::4a53")
+
 // TransferInfo holds the state of a P2P transfer session
 type TransferInfo struct {
 	Code             string `json:"code"`
@@ -30,35 +44,98 @@ type TransferInfo struct {
 	IsDir            bool   `json:"isDir"`
 	Status           string `json:"status"` // "waiting" | "transferring" | "completed" | "error"
 	BytesTransferred int64  `json:"bytesTransferred"`
+	SHA256           string `json:"sha256,omitempty"`    // Hex digest of a single file, once computed
+	Interface        string `json:"interface,omitempty"` // Network interface the sender is bound to, "" for all
+	Draining         bool   `json:"draining,omitempty"`  // Stopping once the current download is done
+
+	ExpiresAt    time.Time `json:"expiresAt,omitzero"`     // The send stops at this time, if set
+	MaxDownloads int       `json:"maxDownloads,omitempty"` // The send stops after this many downloads, if set
+	Downloads    int       `json:"downloads"`              // Downloads completed in this session
+}
+
+// SendOptions are the optional settings of SendWith
+type SendOptions struct {
+	Interface string // Serve and announce on this network interface only, "" for all
+
+	// The send stops by itself at ExpiresAt and once MaxDownloads downloads
+	// completed, reporting an "expired" status first; zero values mean no
+	// limit. The download page shows the time left.
+	ExpiresAt    time.Time
+	MaxDownloads int
+}
+
+// ErrExpired is returned when a send is started after its expiry time
+var ErrExpired = errcode.New(errcode.Expired, "the expiry time has already passed")
+
+// expiryDrainTimeout is how long an expired send waits for a download in progress
+const expiryDrainTimeout = 30 * time.Second
+
+// Peer is a sender found through LAN discovery
+type Peer struct {
+	Code string `json:"code"`
+	URL  string `json:"url"`
+}
+
+// PeerInfo is the transfer metadata published by a sender on /p2p/info
+type PeerInfo struct {
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	IsDir    bool   `json:"isDir"`
+	Code     string `json:"code"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// StatusEvent is the payload of "p2p-status"
+type StatusEvent struct {
+	Version int    `json:"version"`          // events.PayloadVersion
+	Code    string `json:"code"`             // Transfer code
+	Status  string `json:"status"`           // "transferring" | "completed" | "waiting" (a download broke off) | "expired" | "draining" | "stopped" (after draining)
+	Reason  string `json:"reason,omitempty"` // Why the send expired: "time" or "downloads"
+}
+
+// ProgressEvent is the payload of "p2p-progress"
+type ProgressEvent struct {
+	Version int    `json:"version"` // events.PayloadVersion
+	Code    string `json:"code"`    // Transfer code
+	Bytes   int64  `json:"bytes"`   // Sent to the current receiver
+	Total   int64  `json:"total"`   // -1 for folders, which are zipped on the fly
 }
 
+// Manager runs one P2P send session at a time. It reports "p2p-status",
+// "p2p-progress" and "p2p-error" events to its sink.
 type Manager struct {
-	ctx      context.Context
+	events   events.Sink
 	info     *TransferInfo
 	server   *http.Server
 	listener net.Listener
-	udpConn  *net.UDPConn
+	udpConn  *net.UDPConn // IPv4 broadcast
+	udpConn6 *net.UDPConn // IPv6 multicast
+	sending  int          // Downloads in progress
+	expiry   *time.Timer  // Fires at the expiry time of the current session
 	mu       sync.Mutex
-}
 
-func NewManager() *Manager {
-	return &Manager{}
+	// Totals since the manager was created, for metrics
+	sends     int64 // Send sessions started
+	completed int64 // Downloads sent in full
+	failed    int64 // Downloads that broke off
+	sentBytes int64
 }
 
-func (m *Manager) SetContext(ctx context.Context) {
-	m.ctx = ctx
+// NewManager creates a manager that reports to sink (nil discards events)
+func NewManager(sink events.Sink) *Manager {
+	return &Manager{events: events.OrDiscard(sink)}
 }
 
-func (m *Manager) GetStatus() string {
+// Info returns a copy of the current transfer state, or nil if nothing is being sent
+func (m *Manager) Info() *TransferInfo {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.info == nil {
-		return ""
+		return nil
 	}
-
-	result, _ := json.Marshal(m.info)
-	return string(result)
+	info := *m.info
+	return &info
 }
 
 // GenerateTransferCode creates a 6-digit code for easy sharing
@@ -67,23 +144,102 @@ func GenerateTransferCode() string {
 	return fmt.Sprintf("%d", code)
 }
 
-// StopTransfer stops the current P2P transfer session
+// ResetStatus returns a completed transfer to "waiting" so the sender can serve
+// another receiver
+func (m *Manager) ResetStatus() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.info != nil {
+		m.info.Status = "waiting"
+		m.info.BytesTransferred = 0
+	}
+}
+
+// StopTransfer stops the current P2P transfer session immediately, cutting
+// off a download in progress
 func (m *Manager) StopTransfer() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.stopInternal()
 }
 
-func (m *Manager) stopInternal() {
+// DrainTransfer stops the current session gracefully: it stops announcing
+// it, refuses new downloads and emits a "draining" status, then stops once
+// the download in progress is done or timeout has passed. It blocks until
+// the session stopped; StopTransfer or a new SendWith cut it short.
+func (m *Manager) DrainTransfer(timeout time.Duration) {
+	m.mu.Lock()
+	session := m.info
+	m.mu.Unlock()
+	m.drain(session, timeout)
+}
+
+// drain is DrainTransfer for session, if it is still the current one
+func (m *Manager) drain(session *TransferInfo, timeout time.Duration) {
+	m.mu.Lock()
+	if session == nil || m.info != session || session.Draining {
+		m.mu.Unlock()
+		return
+	}
+	session.Draining = true
+	m.stopBroadcast()
+	m.mu.Unlock()
+	m.events.Emit("p2p-status", StatusEvent{Version: events.PayloadVersion, Code: session.Code, Status: "draining"})
+
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		if m.info != session {
+			m.mu.Unlock()
+			return // Stopped or replaced meanwhile
+		}
+		if m.sending == 0 || !time.Now().Before(deadline) {
+			m.stopInternal()
+			m.mu.Unlock()
+			m.events.Emit("p2p-status", StatusEvent{Version: events.PayloadVersion, Code: session.Code, Status: "stopped"})
+			return
+		}
+		m.mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// stopBroadcast ends the discovery announcements of the current session
+func (m *Manager) stopBroadcast() {
 	if m.udpConn != nil {
 		m.udpConn.Close()
 		m.udpConn = nil
 	}
+	if m.udpConn6 != nil {
+		m.udpConn6.Close()
+		m.udpConn6 = nil
+	}
+}
+
+// expire reports that session reached its expiry time or download limit
+// and drains it
+func (m *Manager) expire(session *TransferInfo, reason string) {
+	m.mu.Lock()
+	current := m.info == session && !session.Draining
+	m.mu.Unlock()
+	if !current {
+		return
+	}
+	m.events.Emit("p2p-status", StatusEvent{Version: events.PayloadVersion, Code: session.Code, Status: "expired", Reason: reason})
+	m.drain(session, expiryDrainTimeout)
+}
+
+func (m *Manager) stopInternal() {
+	m.stopBroadcast()
+
+	if m.expiry != nil {
+		m.expiry.Stop()
+		m.expiry = nil
+	}
 
 	if m.server != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		m.server.Shutdown(ctx)
+		m.server.Close()
 		m.server = nil
 	}
 
@@ -95,8 +251,14 @@ func (m *Manager) stopInternal() {
 	m.info = nil
 }
 
-// StartSend starts a P2P send server for the given file or folder
-func (m *Manager) StartSend(filePath string) (string, error) {
+// SendWith starts a P2P send server for the given file or folder, replacing
+// the current session. With opts.Interface set, the server listens on that
+// interface's address only and announcements go out on it alone.
+func (m *Manager) SendWith(filePath string, opts SendOptions) (*TransferInfo, error) {
+	iface := opts.Interface
+	if !opts.ExpiresAt.IsZero() && !time.Now().Before(opts.ExpiresAt) {
+		return nil, ErrExpired
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -106,31 +268,46 @@ func (m *Manager) StartSend(filePath string) (string, error) {
 	// Validate path
 	info, err := os.Stat(filePath)
 	if err != nil {
-		return "", fmt.Errorf("cannot access path: %w", err)
+		return nil, fmt.Errorf("cannot access path: %w", err)
 	}
 
 	// Generate a transfer code
 	code := GenerateTransferCode()
-	localIP := utils.GetPreferredLocalIP()
+	localIP, listenAddr := utils.GetPreferredLocalIP(), ":0"
+	if iface != "" {
+		if localIP, err = utils.InterfaceIP(iface); err != nil {
+			return nil, fmt.Errorf("%s: %w", iface, err)
+		}
+		listenAddr = net.JoinHostPort(localIP, "0")
+	}
 
 	// Start listener on random port
-	listener, err := net.Listen("tcp", ":0")
+	listener, err := net.Listen("tcp", listenAddr)
 	if err != nil {
-		return "", fmt.Errorf("failed to start listener: %w", err)
+		return nil, fmt.Errorf("failed to start listener: %w", err)
 	}
 
 	port := listener.Addr().(*net.TCPAddr).Port
-	transferURL := fmt.Sprintf("http://%s:%d", localIP, port)
+	transferURL := "http://" + utils.HostPort(localIP, port)
 
+	atomic.AddInt64(&m.sends, 1)
 	m.listener = listener
 	m.info = &TransferInfo{
-		Code:     code,
-		URL:      transferURL,
-		FilePath: filePath,
-		FileName: filepath.Base(filePath),
-		FileSize: info.Size(),
-		IsDir:    info.IsDir(),
-		Status:   "waiting",
+		Code:      code,
+		URL:       transferURL,
+		FilePath:  filePath,
+		FileName:  filepath.Base(filePath),
+		FileSize:  info.Size(),
+		IsDir:     info.IsDir(),
+		Status:    "waiting",
+		Interface: iface,
+
+		ExpiresAt:    opts.ExpiresAt,
+		MaxDownloads: opts.MaxDownloads,
+	}
+	if !opts.ExpiresAt.IsZero() {
+		session := m.info
+		m.expiry = time.AfterFunc(time.Until(opts.ExpiresAt), func() { m.expire(session, "time") })
 	}
 
 	// Create HTTP handler for the transfer
@@ -138,49 +315,64 @@ func (m *Manager) StartSend(filePath string) (string, error) {
 
 	// Info endpoint - returns transfer metadata
 	mux.HandleFunc("/p2p/info", func(w http.ResponseWriter, r *http.Request) {
+		info := m.Info()
+		if info == nil {
+			http.Error(w, "Transfer stopped", http.StatusGone)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"fileName": m.info.FileName,
-			"fileSize": m.info.FileSize,
-			"isDir":    m.info.IsDir,
-			"code":     m.info.Code,
+		json.NewEncoder(w).Encode(PeerInfo{
+			FileName: info.FileName,
+			FileSize: info.FileSize,
+			IsDir:    info.IsDir,
+			Code:     info.Code,
+			SHA256:   info.SHA256,
 		})
 	})
 
 	// Download endpoint
 	mux.HandleFunc("/p2p/download", func(w http.ResponseWriter, r *http.Request) {
 		m.mu.Lock()
-		if m.info != nil {
-			m.info.Status = "transferring"
+		session := m.info
+		if session == nil || session.Draining {
+			m.mu.Unlock()
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Transfer is stopping", http.StatusServiceUnavailable)
+			return
 		}
+		session.Status = "transferring"
+		m.sending++
 		m.mu.Unlock()
+		defer func() {
+			m.mu.Lock()
+			m.sending--
+			m.mu.Unlock()
+		}()
 
-		if m.ctx != nil {
-			runtime.EventsEmit(m.ctx, "p2p-status", "transferring")
-		}
+		m.events.Emit("p2p-status", StatusEvent{Version: events.PayloadVersion, Code: code, Status: "transferring"})
 
-		if m.info.IsDir {
+		var sent int64
+		var sendErr error
+		if session.IsDir {
 			// Stream as zip
 			w.Header().Set("Content-Type", "application/zip")
-			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, m.info.FileName))
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, session.FileName))
 
 			pw := &progressWriter{
 				writer: w,
 				onProgress: func(written int64) {
 					m.mu.Lock()
-					if m.info != nil {
+					if m.info == session {
 						m.info.BytesTransferred = written
 					}
 					m.mu.Unlock()
-					if m.ctx != nil {
-						runtime.EventsEmit(m.ctx, "p2p-progress", written)
-					}
+					m.events.Emit("p2p-progress", ProgressEvent{Version: events.PayloadVersion, Code: code, Bytes: written, Total: -1})
 				},
 			}
 
 			zw := zip.NewWriter(pw)
-			filepath.WalkDir(filePath, func(path string, d os.DirEntry, err error) error {
+			sendErr = filepath.WalkDir(filePath, func(path string, d os.DirEntry, err error) error {
 				if err != nil {
 					return err
 				}
@@ -204,7 +396,10 @@ func (m *Manager) StartSend(filePath string) (string, error) {
 				_, err = io.Copy(zipFile, fsFile)
 				return err
 			})
-			zw.Close()
+			if err := zw.Close(); sendErr == nil {
+				sendErr = err
+			}
+			sent = pw.written
 		} else {
 			// Send single file
 			file, err := os.Open(filePath)
@@ -214,36 +409,48 @@ func (m *Manager) StartSend(filePath string) (string, error) {
 			}
 			defer file.Close()
 
-			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, m.info.FileName))
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, session.FileName))
 			w.Header().Set("Content-Type", "application/octet-stream")
-			w.Header().Set("Content-Length", fmt.Sprintf("%d", m.info.FileSize))
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", session.FileSize))
 
 			pw := &progressWriter{
 				writer: w,
-				total:  m.info.FileSize,
+				total:  session.FileSize,
 				onProgress: func(written int64) {
 					m.mu.Lock()
-					if m.info != nil {
+					if m.info == session {
 						m.info.BytesTransferred = written
 					}
 					m.mu.Unlock()
-					if m.ctx != nil {
-						runtime.EventsEmit(m.ctx, "p2p-progress", written)
-					}
+					m.events.Emit("p2p-progress", ProgressEvent{Version: events.PayloadVersion, Code: code, Bytes: written, Total: session.FileSize})
 				},
 			}
 
-			io.Copy(pw, file)
+			_, sendErr = io.Copy(pw, file)
+			sent = pw.written
 		}
+		m.countDownload(sent, sendErr)
 
-		// Mark as completed
+		// Mark as completed, or as waiting for the next receiver if the
+		// download broke off
+		status, limitReached := "completed", false
+		if sendErr != nil {
+			status = "waiting"
+		}
 		m.mu.Lock()
-		if m.info != nil {
-			m.info.Status = "completed"
+		if m.info == session {
+			m.info.Status = status
+			if sendErr == nil {
+				m.info.Downloads++
+				limitReached = m.info.MaxDownloads > 0 && m.info.Downloads >= m.info.MaxDownloads
+			} else {
+				m.info.BytesTransferred = 0
+			}
 		}
 		m.mu.Unlock()
-		if m.ctx != nil {
-			runtime.EventsEmit(m.ctx, "p2p-status", "completed")
+		m.events.Emit("p2p-status", StatusEvent{Version: events.PayloadVersion, Code: code, Status: status})
+		if limitReached {
+			go m.expire(session, "downloads")
 		}
 	})
 
@@ -253,126 +460,209 @@ func (m *Manager) StartSend(filePath string) (string, error) {
 			http.NotFound(w, r)
 			return
 		}
-		ServeP2PDownloadPage(w, m.info)
+		info := m.Info()
+		if info == nil {
+			http.Error(w, "Transfer stopped", http.StatusGone)
+			return
+		}
+		ServeP2PDownloadPage(w, r, info)
 	})
 
-	m.server = &http.Server{Handler: mux}
+	srv := &http.Server{Handler: mux}
+	m.server = srv
 
 	go func() {
-		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			if m.ctx != nil {
-				runtime.EventsEmit(m.ctx, "p2p-error", err.Error())
-			}
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			m.events.Emit("p2p-error", events.NewError(err))
 		}
 	}()
 
 	// Start UDP broadcast for discovery
-	go m.startBroadcast(code, transferURL, port)
+	go m.startBroadcast(code, transferURL, port, iface)
+
+	// Hash single files in the background so receivers can verify them
+	if !info.IsDir() {
+		go m.hashFile(m.info, filePath)
+	}
 
-	// Return info as JSON string
-	result, _ := json.Marshal(m.info)
-	return string(result), nil
+	sent := *m.info
+	return &sent, nil
 }
 
-func (m *Manager) startBroadcast(code string, transferURL string, port int) {
-	addr, err := net.ResolveUDPAddr("udp4", "255.255.255.255:41234")
+// hashFile records the SHA-256 of a file being sent, if the session is still current
+func (m *Manager) hashFile(session *TransferInfo, filePath string) {
+	f, err := os.Open(filePath)
 	if err != nil {
 		return
 	}
+	defer f.Close()
 
-	conn, err := net.DialUDP("udp4", nil, addr)
-	if err != nil {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
 		return
 	}
 
 	m.mu.Lock()
-	m.udpConn = conn
+	if m.info == session {
+		m.info.SHA256 = hex.EncodeToString(h.Sum(nil))
+	}
 	m.mu.Unlock()
+}
 
-	msg := fmt.Sprintf("JUSTSERVE_P2P:%s:%s:%d", code, transferURL, port)
+// startBroadcast announces the transfer every 2 seconds by IPv4 broadcast and
+// IPv6 multicast, on iface or every interface, until the session stops
+func (m *Manager) startBroadcast(code string, transferURL string, port int, iface string) {
+	bcast := net.IPv4bcast
+	if iface != "" {
+		bcast = utils.InterfaceBroadcast(iface) // Directed to the interface's subnet
+	}
+	var conn4 *net.UDPConn
+	if bcast != nil {
+		conn4, _ = net.DialUDP("udp4", nil, &net.UDPAddr{IP: bcast, Port: discoveryPort})
+	}
+	conn6, _ := net.ListenUDP("udp6", &net.UDPAddr{})
+	if conn4 == nil && conn6 == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.udpConn, m.udpConn6 = conn4, conn6
+	m.mu.Unlock()
+
+	msg := []byte(fmt.Sprintf("JUSTSERVE_P2P:%s:%s:%d", code, transferURL, port))
 
 	for {
 		m.mu.Lock()
-		if m.info == nil || m.udpConn == nil {
+		if m.info == nil || m.udpConn != conn4 || m.udpConn6 != conn6 {
 			m.mu.Unlock()
 			return
 		}
 		m.mu.Unlock()
 
-		conn.Write([]byte(msg))
+		if conn4 != nil {
+			conn4.Write(msg)
+		}
+		if conn6 != nil {
+			for _, ifi := range utils.MulticastInterfaces() {
+				if iface == "" || ifi.Name == iface {
+					conn6.WriteToUDP(msg, &net.UDPAddr{IP: discoveryGroup6, Port: discoveryPort, Zone: ifi.Name})
+				}
+			}
+		}
 		time.Sleep(2 * time.Second)
 	}
 }
 
-// DiscoverPeers listens for P2P broadcast messages on the LAN
-func (m *Manager) DiscoverPeers(timeoutSeconds int) (string, error) {
-	addr, err := net.ResolveUDPAddr("udp4", ":41234")
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve UDP address: %w", err)
+// Discover listens for sender announcements, over IPv4 broadcast and IPv6
+// multicast, until the timeout expires. If stop is set, discovery ends early
+// as soon as it returns true for a newly seen peer.
+func (m *Manager) Discover(timeout time.Duration, stop func(Peer) bool) ([]Peer, error) {
+	var conns []*net.UDPConn
+	conn4, err := net.ListenUDP("udp4", &net.UDPAddr{Port: discoveryPort})
+	if err == nil {
+		conns = append(conns, conn4)
 	}
-
-	conn, err := net.ListenUDP("udp4", addr)
-	if err != nil {
-		return "", fmt.Errorf("failed to listen for broadcasts: %w", err)
+	group := &net.UDPAddr{IP: discoveryGroup6, Port: discoveryPort}
+	if conn6, err6 := net.ListenMulticastUDP("udp6", nil, group); err6 == nil {
+		pc := ipv6.NewPacketConn(conn6)
+		for _, ifi := range utils.MulticastInterfaces() {
+			pc.JoinGroup(&ifi, group) // Fails for the interface already joined by ListenMulticastUDP
+		}
+		conns = append(conns, conn6)
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("failed to listen for broadcasts: %w", err)
 	}
-	defer conn.Close()
 
-	if timeoutSeconds <= 0 {
-		timeoutSeconds = 10
+	type datagram struct {
+		msg string
+		src *net.UDPAddr
+	}
+	received := make(chan datagram)
+	done := make(chan struct{})
+	defer close(done)
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			buf := make([]byte, 1024)
+			for {
+				n, src, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return // timeout or error
+				}
+				select {
+				case received <- datagram{string(buf[:n]), src}:
+				case <-done:
+					return
+				}
+			}
+		}(conn)
 	}
-	conn.SetReadDeadline(time.Now().Add(time.Duration(timeoutSeconds) * time.Second))
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
 
-	var peers []map[string]string
+	var peers []Peer
 	seen := make(map[string]bool)
-
-	buf := make([]byte, 1024)
-	for {
-		n, _, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			break // timeout or error
-		}
-
-		msg := string(buf[:n])
-		if !strings.HasPrefix(msg, "JUSTSERVE_P2P:") {
+	for d := range received {
+		peer, ok := parseAnnouncement(d.msg, d.src)
+		if !ok || seen[peer.Code] {
 			continue
 		}
-
-		parts := strings.Split(msg, ":")
-		if len(parts) >= 4 {
-			code := parts[1]
-			// Reconstruct URL (parts[2] has http, parts[3] has //host, parts[4] has port)
-			transferURL := parts[2] + ":" + parts[3] + ":" + parts[4]
-
-			if !seen[code] {
-				seen[code] = true
-				peers = append(peers, map[string]string{
-					"code": code,
-					"url":  transferURL,
-				})
-			}
+		seen[peer.Code] = true
+		peers = append(peers, peer)
+		if stop != nil && stop(peer) {
+			break
 		}
 	}
 
-	result, _ := json.Marshal(peers)
-	return string(result), nil
+	return peers, nil
 }
 
-// ConnectToPeer connects to a peer using transfer code and IP address
-func (m *Manager) ConnectToPeer(address string) (string, error) {
-	// Try to get info from the peer
-	resp, err := http.Get(address + "/p2p/info")
+// parseAnnouncement reads "JUSTSERVE_P2P:<code>:<url>:<port>". A link-local
+// IPv6 URL is only valid on the sender, so the zone of the interface the
+// announcement arrived on replaces its zone.
+func parseAnnouncement(msg string, src *net.UDPAddr) (Peer, bool) {
+	rest, ok := strings.CutPrefix(msg, "JUSTSERVE_P2P:")
+	if !ok {
+		return Peer{}, false
+	}
+	code, rest, ok := strings.Cut(rest, ":")
+	i := strings.LastIndex(rest, ":")
+	if !ok || code == "" || i < 0 {
+		return Peer{}, false
+	}
+	u, err := url.Parse(rest[:i]) // Drop the port, which is also in the URL
+	if err != nil || u.Host == "" {
+		return Peer{}, false
+	}
+	if ip := utils.ParseHostIP(u.Host); ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() && src != nil && src.Zone != "" {
+		u.Host = net.JoinHostPort(ip.String()+"%"+src.Zone, u.Port()) // String escapes the zone
+	}
+	return Peer{Code: code, URL: u.String()}, true
+}
+
+// FetchPeerInfo asks a sender for its transfer metadata
+func FetchPeerInfo(address string) (*PeerInfo, error) {
+	resp, err := http.Get(strings.TrimRight(address, "/") + "/p2p/info")
 	if err != nil {
-		return "", fmt.Errorf("cannot connect to peer: %w", err)
+		return nil, fmt.Errorf("cannot connect to peer: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var info map[string]interface{}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer returned %s", resp.Status)
+	}
+	var info PeerInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return "", fmt.Errorf("invalid response from peer: %w", err)
+		return nil, fmt.Errorf("invalid response from peer: %w", err)
 	}
-
-	result, _ := json.Marshal(info)
-	return string(result), nil
+	return &info, nil
 }
 
 // progressWriter wraps an io.Writer to track bytes written
@@ -392,8 +682,15 @@ func (pw *progressWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// CodeURL is the download page address with the transfer code attached, as
+// put in QR codes. The page warns when the code is not the one on offer, e.g.
+// after the sender restarted on the same port.
+func CodeURL(transferURL, code string) string {
+	return strings.TrimSuffix(transferURL, "/") + "/?code=" + url.QueryEscape(code)
+}
+
 // ServeP2PDownloadPage serves a beautiful download page for browser-based P2P download
-func ServeP2PDownloadPage(w http.ResponseWriter, info *TransferInfo) {
+func ServeP2PDownloadPage(w http.ResponseWriter, r *http.Request, info *TransferInfo) {
 	sizeStr := fmt.Sprintf("%.2f MB", float64(info.FileSize)/(1024*1024))
 	if info.FileSize < 1024*1024 {
 		sizeStr = fmt.Sprintf("%.2f KB", float64(info.FileSize)/1024)
@@ -496,6 +793,25 @@ func ServeP2PDownloadPage(w http.ResponseWriter, info *TransferInfo) {
             transform: translateY(-2px);
             box-shadow: 0 10px 25px -5px rgba(59,130,246,0.4);
         }
+        .qr {
+            display: inline-block;
+            margin-bottom: 2rem;
+        }
+        .qr img { width: 160px; height: 160px; border-radius: 12px; display: block; }
+        .qr div { color: var(--text-secondary); font-size: 0.8rem; margin-top: 0.5rem; }
+        .stale {
+            color: #f59e0b;
+            background: rgba(245,158,11,0.1);
+            border-radius: 12px;
+            padding: 0.75rem 1rem;
+            font-size: 0.9rem;
+            margin-bottom: 1.5rem;
+        }
+        .expiry {
+            color: #f59e0b;
+            font-size: 0.85rem;
+            margin: -1rem 0 1.5rem;
+        }
         .progress { display: none; margin-top: 1.5rem; }
         .progress-bar {
             height: 6px;
@@ -520,6 +836,7 @@ func ServeP2PDownloadPage(w http.ResponseWriter, info *TransferInfo) {
 <body>
     <div class="card">
         <div class="logo">Just<span>Serve</span></div>
+        {{if .StaleCode}}<div class="stale">Code {{.StaleCode}} has ended. This sender now offers the transfer below.</div>{{end}}
         <div class="icon-wrap">{{if .IsDir}}📁{{else}}📄{{end}}</div>
         <h1>{{.FileName}}</h1>
         <div class="meta">
@@ -527,28 +844,65 @@ func ServeP2PDownloadPage(w http.ResponseWriter, info *TransferInfo) {
             <span>{{.SizeStr}}</span>
         </div>
         <div class="code">{{.Code}}</div>
+        {{if .ExpiresAt}}<div class="expiry" id="expiry" data-expires="{{.ExpiresAt}}">⏳ Available until {{.ExpiresLabel}}</div>{{end}}
+        {{if .QR}}<div class="qr"><img src="{{.QR}}" alt="QR code for this transfer"><div>Scan to download on another device</div></div>{{end}}
         <a href="/p2p/download" class="btn" id="downloadBtn">⬇ Download Now</a>
         <div class="progress" id="progress">
             <div class="progress-bar"><div class="progress-fill" id="progressFill"></div></div>
         </div>
         <div class="footer">Served via JustServe P2P Direct Transfer</div>
     </div>
+    {{if .ExpiresAt}}<script>
+        // Counts down to the expiry; the sender stops at that time
+        (function () {
+            var el = document.getElementById('expiry');
+            var end = new Date(el.dataset.expires).getTime();
+            var tick = function () {
+                var s = Math.max(0, Math.round((end - Date.now()) / 1000));
+                var d = Math.floor(s / 86400), h = Math.floor(s % 86400 / 3600), m = Math.floor(s % 3600 / 60);
+                el.textContent = s === 0 ? '⏳ This transfer has expired'
+                    : '⏳ Available for ' + (d ? d + 'd ' : '') + (d || h ? h + 'h ' : '') + m + 'm' + (d ? '' : ' ' + s % 60 + 's');
+            };
+            tick();
+            setInterval(tick, 1000);
+        })();
+    </script>{{end}}
 </body>
 </html>`
 
+	// P2P transfers are plain HTTP on the LAN, so only the host is taken from r
+	qrURI, _ := qr.SVGDataURI(CodeURL("http://"+r.Host, info.Code))
+	staleCode := r.URL.Query().Get("code")
+	if staleCode == info.Code {
+		staleCode = ""
+	}
+
+	var expiresAt, expiresLabel string
+	if !info.ExpiresAt.IsZero() {
+		expiresAt, expiresLabel = info.ExpiresAt.Format(time.RFC3339), info.ExpiresAt.Format("Mon 2 Jan 15:04 MST")
+	}
+
 	t, _ := template.New("p2p").Parse(tpl)
 	data := struct {
-		FileName string
-		SizeStr  string
-		TypeStr  string
-		Code     string
-		IsDir    bool
+		FileName     string
+		SizeStr      string
+		TypeStr      string
+		Code         string
+		IsDir        bool
+		QR           template.URL
+		StaleCode    string
+		ExpiresAt    string
+		ExpiresLabel string
 	}{
-		FileName: info.FileName,
-		SizeStr:  sizeStr,
-		TypeStr:  typeStr,
-		Code:     info.Code,
-		IsDir:    info.IsDir,
+		FileName:     info.FileName,
+		SizeStr:      sizeStr,
+		TypeStr:      typeStr,
+		Code:         info.Code,
+		IsDir:        info.IsDir,
+		QR:           template.URL(qrURI),
+		StaleCode:    staleCode,
+		ExpiresAt:    expiresAt,
+		ExpiresLabel: expiresLabel,
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	t.Execute(w, data)
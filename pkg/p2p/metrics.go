@@ -0,0 +1,38 @@
+package p2p
+
+import (
+	"sync/atomic"
+
+	"JustServe/pkg/metrics"
+)
+
+// countDownload adds a finished download to the totals
+func (m *Manager) countDownload(sent int64, err error) {
+	atomic.AddInt64(&m.sentBytes, sent)
+	if err != nil {
+		atomic.AddInt64(&m.failed, 1)
+	} else {
+		atomic.AddInt64(&m.completed, 1)
+	}
+}
+
+// WriteMetrics writes the P2P send totals; it makes the Manager a
+// metrics.Source
+func (m *Manager) WriteMetrics(w *metrics.Writer) {
+	sending := 0.0
+	if m.Info() != nil {
+		sending = 1
+	}
+	w.Family("justserve_p2p_sending", "gauge", "1 while a P2P transfer is offered.")
+	w.Sample(sending)
+
+	w.Family("justserve_p2p_sends_total", "counter", "P2P send sessions started.")
+	w.Sample(float64(atomic.LoadInt64(&m.sends)))
+
+	w.Family("justserve_p2p_transfers_total", "counter", "P2P downloads served, by result.")
+	w.Sample(float64(atomic.LoadInt64(&m.completed)), "result", "completed")
+	w.Sample(float64(atomic.LoadInt64(&m.failed)), "result", "failed")
+
+	w.Family("justserve_p2p_sent_bytes_total", "counter", "Bytes sent to P2P receivers.")
+	w.Sample(float64(atomic.LoadInt64(&m.sentBytes)))
+}
@@ -0,0 +1,210 @@
+package p2p
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"JustServe/pkg/errcode"
+)
+
+var (
+	// ErrNoSender is returned when no sender with the wanted code answers discovery
+	ErrNoSender = errcode.New(errcode.NoSender, "no sender found on the network")
+	// ErrCorrupted is returned when a received file or folder fails verification
+	ErrCorrupted = errcode.New(errcode.Corrupted, "the received data is corrupted")
+)
+
+// ProgressFunc reports received bytes; total is -1 when the size is unknown (folders)
+type ProgressFunc func(received, total int64)
+
+// FindPeer waits until a sender broadcasting the given code is discovered
+func (m *Manager) FindPeer(code string, timeout time.Duration) (*Peer, error) {
+	var found *Peer
+	_, err := m.Discover(timeout, func(p Peer) bool {
+		if p.Code == code {
+			found = &p
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w (code %s)", ErrNoSender, code)
+	}
+	return found, nil
+}
+
+// Receive downloads the transfer offered at address into destDir. Single files
+// are checked against the size and SHA-256 published by the sender; folders
+// arrive as a zip that is verified and extracted. It returns the saved path.
+func (m *Manager) Receive(ctx context.Context, address string, destDir string, onProgress ProgressFunc) (string, error) {
+	address = strings.TrimRight(address, "/")
+	info, err := FetchPeerInfo(address)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(filepath.FromSlash(info.FileName))
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "", fmt.Errorf("sender offered an invalid file name %q", info.FileName)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address+"/p2p/download", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sender returned %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create destination: %w", err)
+	}
+	tmp, err := os.CreateTemp(destDir, ".justserve-p2p-*")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	total := int64(-1)
+	if !info.IsDir {
+		total = info.FileSize
+	}
+	h := sha256.New()
+	body := &progressReader{reader: resp.Body, total: total, onProgress: onProgress}
+	n, err := io.Copy(io.MultiWriter(tmp, h), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+
+	if info.IsDir {
+		dest := uniquePath(filepath.Join(destDir, name))
+		if err := extractZip(tmp.Name(), dest); err != nil {
+			os.RemoveAll(dest)
+			if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) {
+				err = fmt.Errorf("%w: %v", ErrCorrupted, err)
+			}
+			return "", err
+		}
+		return dest, nil
+	}
+
+	if n != info.FileSize {
+		return "", fmt.Errorf("%w: expected %d bytes, received %d", ErrCorrupted, info.FileSize, n)
+	}
+	want := info.SHA256
+	if want == "" {
+		// The sender hashes in the background; it is usually done by now
+		if latest, err := FetchPeerInfo(address); err == nil {
+			want = latest.SHA256
+		}
+	}
+	if want != "" && !strings.EqualFold(want, hex.EncodeToString(h.Sum(nil))) {
+		return "", fmt.Errorf("%w: SHA-256 does not match", ErrCorrupted)
+	}
+
+	dest := uniquePath(filepath.Join(destDir, name))
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("cannot save file: %w", err)
+	}
+	return dest, nil
+}
+
+// extractZip unpacks a received folder into dest. Reading every entry to the
+// end makes archive/zip verify its CRC-32.
+func extractZip(zipPath string, dest string) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("received folder is not a valid zip: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		target := filepath.Join(dest, filepath.FromSlash(f.Name))
+		if rel, err := filepath.Rel(dest, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing unsafe path %q in received folder", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractZipFile(f, target); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// uniquePath appends " (1)", " (2)", ... before the extension until path is free
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	reader     io.Reader
+	read       int64
+	total      int64
+	onProgress ProgressFunc
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.read += int64(n)
+	if pr.onProgress != nil && n > 0 {
+		pr.onProgress(pr.read, pr.total)
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// QuietZone is the light border, in modules, that scanners need around a symbol
+const QuietZone = 4
+
+// PNG renders the symbol with its quiet zone, scale pixels per module
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			if c.Dark(px/scale-QuietZone, py/scale-QuietZone) {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the symbol with its quiet zone as a scalable image, one unit
+// per module
+func (c *Code) SVG() string {
+	side := c.Size + 2*QuietZone
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, side, side)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, side, side)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String()
+}
+
+// PNGDataURI encodes text at Medium level and returns a data:image/png URI
+func PNGDataURI(text string, scale int) (string, error) {
+	c, err := Encode(text, Medium)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.PNG(scale)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// SVGDataURI encodes text at Medium level and returns a data:image/svg+xml URI
+func SVGDataURI(text string) (string, error) {
+	c, err := Encode(text, Medium)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(c.SVG())), nil
+}
@@ -0,0 +1,23 @@
+//go:build darwin
+
+package secrets
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+)
+
+var platformUUID = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+// machineID reads the hardware UUID from IOKit, falling back to the hostname
+func machineID() string {
+	out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+	if err == nil {
+		if m := platformUUID.FindSubmatch(out); m != nil {
+			return string(m[1])
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
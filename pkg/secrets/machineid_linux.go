@@ -0,0 +1,21 @@
+//go:build linux
+
+package secrets
+
+import (
+	"os"
+	"strings"
+)
+
+// machineID reads the systemd/dbus machine ID, falling back to the hostname
+func machineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id
+			}
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package secrets
+
+import "os"
+
+// machineID falls back to the hostname on platforms without a stable machine ID
+func machineID() string {
+	host, _ := os.Hostname()
+	return host
+}
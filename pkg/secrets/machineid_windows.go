@@ -0,0 +1,27 @@
+//go:build windows
+
+package secrets
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// machineID reads the MachineGuid set at Windows install, falling back to the hostname
+func machineID() string {
+	cmd := exec.Command("reg", "query", `HKLM\SOFTWARE\Microsoft\Cryptography`, "/v", "MachineGuid")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 3 && fields[0] == "MachineGuid" {
+				return fields[2]
+			}
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
@@ -0,0 +1,348 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"JustServe/pkg/errcode"
+)
+
+// Key modes of a vault
+const (
+	ModeMachine    = "machine"    // Key derived from this machine and user account; unlocks automatically
+	ModePassphrase = "passphrase" // Key derived from a user passphrase
+)
+
+const (
+	formatVersion    = 1
+	pbkdf2Iterations = 600000
+)
+
+var (
+	// ErrLocked is returned when a passphrase vault has not been unlocked yet
+	ErrLocked = errcode.New(errcode.VaultLocked, "secrets vault is locked")
+	// ErrWrongKey is returned when the vault cannot be decrypted with the given key
+	ErrWrongKey = errcode.New(errcode.VaultWrongKey, "wrong passphrase or the vault belongs to another machine")
+	// ErrNotFound is returned for an unknown secret name
+	ErrNotFound = errcode.New(errcode.SecretNotFound, "secret not found")
+)
+
+// Status describes a vault without revealing any secret values
+type Status struct {
+	Exists bool     `json:"exists"`
+	Locked bool     `json:"locked"`
+	Mode   string   `json:"mode"`
+	Names  []string `json:"names"` // Names of stored secrets, sorted
+}
+
+// vaultFile is the on-disk format. Only the secret map is encrypted.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	Mode       string `json:"mode"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Vault is an AES-256-GCM encrypted name -> secret store
+type Vault struct {
+	mu      sync.Mutex
+	path    string
+	mode    string
+	salt    []byte
+	key     []byte            // nil while locked
+	secrets map[string]string // nil while locked
+}
+
+// DefaultPath returns secrets.vault in the per-user config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "JustServe", "secrets.vault"), nil
+}
+
+// Open reads the vault header at path. A machine-key vault, or a missing file
+// (which becomes a new machine-key vault), is unlocked right away.
+func Open(path string) (*Vault, error) {
+	v := &Vault{path: path, mode: ModeMachine}
+
+	f, err := v.readFile()
+	if errors.Is(err, os.ErrNotExist) {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		v.salt = salt
+		v.key, err = machineKey(salt)
+		if err != nil {
+			return nil, err
+		}
+		v.secrets = make(map[string]string)
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v.mode, v.salt = f.Mode, f.Salt
+	if v.mode == ModeMachine {
+		key, err := machineKey(v.salt)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.unlockWith(f, key); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Status reports whether the vault exists and is locked, and which names it holds
+func (v *Vault) Status() Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	_, err := os.Stat(v.path)
+	st := Status{Exists: err == nil, Locked: v.key == nil, Mode: v.mode, Names: []string{}}
+	for name := range v.secrets {
+		st.Names = append(st.Names, name)
+	}
+	sort.Strings(st.Names)
+	return st
+}
+
+// Unlock decrypts a passphrase vault
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key != nil {
+		return nil
+	}
+	f, err := v.readFile()
+	if err != nil {
+		return err
+	}
+	key, err := passphraseKey(passphrase, v.salt)
+	if err != nil {
+		return err
+	}
+	return v.unlockWith(f, key)
+}
+
+// Lock forgets the key and decrypted secrets of a passphrase vault.
+// Machine-key vaults stay unlocked.
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.mode == ModePassphrase {
+		v.key, v.secrets = nil, nil
+	}
+}
+
+// SetPassphrase re-encrypts the vault with a passphrase, or with the machine
+// key when passphrase is empty. The vault must be unlocked.
+func (v *Vault) SetPassphrase(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
+	mode, key := ModeMachine, []byte(nil)
+	if passphrase == "" {
+		key, err = machineKey(salt)
+	} else {
+		mode = ModePassphrase
+		key, err = passphraseKey(passphrase, salt)
+	}
+	if err != nil {
+		return err
+	}
+	v.mode, v.salt, v.key = mode, salt, key
+	return v.save()
+}
+
+// Get returns a secret by name
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set stores a secret and writes the vault
+func (v *Vault) Set(name string, value string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("secret name is required")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	v.secrets[name] = value
+	return v.save()
+}
+
+// Delete removes a secret and writes the vault
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrLocked
+	}
+	delete(v.secrets, name)
+	return v.save()
+}
+
+func (v *Vault) readFile() (*vaultFile, error) {
+	data, err := os.ReadFile(v.path)
+	if err != nil {
+		return nil, err
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("secrets vault is corrupted: %w", err)
+	}
+	if f.Version > formatVersion {
+		return nil, fmt.Errorf("secrets vault was written by a newer JustServe (format %d)", f.Version)
+	}
+	if f.Mode != ModeMachine && f.Mode != ModePassphrase {
+		return nil, fmt.Errorf("secrets vault has unknown key mode %q", f.Mode)
+	}
+	return &f, nil
+}
+
+// unlockWith decrypts f with key and keeps both on success
+func (v *Vault) unlockWith(f *vaultFile, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Ciphertext, []byte(f.Mode))
+	if err != nil {
+		return ErrWrongKey
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("secrets vault is corrupted: %w", err)
+	}
+	v.key, v.secrets = key, secrets
+	return nil
+}
+
+// save encrypts the secrets with a fresh nonce and replaces the file atomically
+func (v *Vault) save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(vaultFile{
+		Version:    formatVersion,
+		Mode:       v.mode,
+		Salt:       v.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, []byte(v.mode)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return fmt.Errorf("cannot create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return fmt.Errorf("cannot save secrets: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save secrets: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save secrets: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("cannot save secrets: %w", err)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// passphraseKey stretches a passphrase with PBKDF2-SHA256
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+}
+
+// machineKey derives a key from the machine ID and the current user. It keeps
+// secrets out of plain files and useless on another machine, but does not
+// protect against other programs running as the same user.
+func machineKey(salt []byte) ([]byte, error) {
+	id := machineID()
+	if u, err := user.Current(); err == nil {
+		id += "|" + u.Uid + "|" + u.HomeDir
+	}
+	return hkdf.Key(sha256.New, []byte(id), salt, "JustServe secrets vault", 32)
+}
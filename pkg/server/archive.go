@@ -0,0 +1,315 @@
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archiveEntry is a file or folder stored inside an archive
+type archiveEntry struct {
+	name  string // Clean slash-separated path inside the archive
+	isDir bool
+	size  int64
+}
+
+// errStopWalk ends walkArchive early without reporting an error
+var errStopWalk = errors.New("stop walking archive")
+
+// isArchive reports whether a file can be browsed as a virtual directory
+func isArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanEntryName normalizes an archive entry name; entries that would point
+// outside the archive root are dropped
+func cleanEntryName(name string) (string, bool) {
+	name = strings.TrimPrefix(pathpkg.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	if name == "" || name == "." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// walkArchive calls fn for every entry of a .zip, .tar or .tar.gz file.
+// open is only valid for the duration of the callback.
+func walkArchive(archivePath string, fn func(e archiveEntry, open func() (io.ReadCloser, error)) error) error {
+	lower := strings.ToLower(archivePath)
+	if strings.HasSuffix(lower, ".zip") {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			name, ok := cleanEntryName(f.Name)
+			if !ok {
+				continue
+			}
+			e := archiveEntry{name: name, isDir: f.FileInfo().IsDir(), size: int64(f.UncompressedSize64)}
+			if err := fn(e, f.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		name, ok := cleanEntryName(hdr.Name)
+		if !ok {
+			continue
+		}
+		e := archiveEntry{name: name, isDir: hdr.Typeflag == tar.TypeDir, size: hdr.Size}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := fn(e, open); err != nil {
+			return err
+		}
+	}
+}
+
+// splitArchivePath finds the archive file that fsPath points into, for
+// requests like /downloads/archive.zip/docs/readme.md. It never looks above
+// the mount root.
+func splitArchivePath(root string, fsPath string, wantDir bool) (archivePath string, inner string, ok bool) {
+	if info, err := os.Stat(fsPath); err == nil {
+		// archive.zip/ browses the archive, archive.zip downloads it
+		if wantDir && info.Mode().IsRegular() && isArchive(fsPath) {
+			return fsPath, "", true
+		}
+		return "", "", false
+	}
+
+	root = filepath.Clean(root)
+	for p := fsPath; p != root; {
+		parent := filepath.Dir(p)
+		if parent == p || !strings.HasPrefix(parent, root) {
+			break
+		}
+		if info, err := os.Stat(parent); err == nil {
+			if !info.Mode().IsRegular() || !isArchive(parent) {
+				break
+			}
+			rel, err := filepath.Rel(parent, fsPath)
+			if err != nil {
+				break
+			}
+			return parent, filepath.ToSlash(rel), true
+		}
+		p = parent
+	}
+	return "", "", false
+}
+
+// serveArchive lists a folder inside an archive or streams a single entry
+func (h *FileHandler) serveArchive(w http.ResponseWriter, r *http.Request, archivePath string, inner string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Archives are read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var entries []archiveEntry
+	err := walkArchive(archivePath, func(e archiveEntry, open func() (io.ReadCloser, error)) error {
+		if e.name == inner && !e.isDir {
+			// A single entry: stream it straight out of the archive
+			var err error
+			h.serveDownload(w, r, func(w http.ResponseWriter) { err = writeArchiveEntry(w, r, e, open) })
+			if err != nil {
+				return err
+			}
+			return errStopWalk
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err == errStopWalk {
+		return
+	}
+	if err != nil {
+		http.Error(w, "Unable to read archive: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Everything else must be a folder inside the archive
+	prefix := ""
+	if inner != "" {
+		prefix = inner + "/"
+	}
+	found := inner == ""
+	for _, e := range entries {
+		if strings.HasPrefix(e.name, prefix) || e.name == inner {
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		// Relative links in the listing need the trailing slash
+		http.Redirect(w, r, pathpkg.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	if r.URL.Query().Get("download") == "zip" {
+		h.serveDownload(w, r, func(w http.ResponseWriter) { h.streamArchiveZip(w, r, archivePath, prefix) })
+		return
+	}
+	h.serveArchiveListing(w, r.URL.Path, entries, prefix)
+}
+
+// serveArchiveListing renders the direct children of prefix
+func (h *FileHandler) serveArchiveListing(w http.ResponseWriter, requestPath string, entries []archiveEntry, prefix string) {
+	dirs := make(map[string]bool)
+	files := make(map[string]int64)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.name, prefix) || e.name == strings.TrimSuffix(prefix, "/") {
+			continue
+		}
+		rest := strings.TrimPrefix(e.name, prefix)
+		if name, _, deeper := strings.Cut(rest, "/"); deeper || e.isDir {
+			// Folders are often implied by their files rather than stored
+			dirs[name] = true
+		} else {
+			files[name] = e.size
+		}
+	}
+
+	dirNames := make([]string, 0, len(dirs))
+	for name := range dirs {
+		dirNames = append(dirNames, name)
+	}
+	fileNames := make([]string, 0, len(files))
+	for name := range files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(dirNames)
+	sort.Strings(fileNames)
+
+	fileList := []fileEntry{{Name: "..", IsDir: true, Size: "-", Icon: "↩️", Href: "../"}}
+	for _, name := range dirNames {
+		fileList = append(fileList, fileEntry{Name: name, IsDir: true, Size: "-", Icon: "📁", Href: entryHref(name, true)})
+	}
+	for _, name := range fileNames {
+		fileList = append(fileList, fileEntry{Name: name, Size: formatSize(files[name]), Icon: fileIcon(name), Href: entryHref(name, false)})
+	}
+	h.renderListing(w, requestPath, fileList, false)
+}
+
+// writeArchiveEntry streams one file out of the archive without extracting it to disk
+func writeArchiveEntry(w http.ResponseWriter, r *http.Request, e archiveEntry, open func() (io.ReadCloser, error)) error {
+	rc, err := open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	contentType := mime.TypeByExtension(pathpkg.Ext(e.name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", e.size))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", pathpkg.Base(e.name)))
+	if r.Method != http.MethodHead {
+		io.Copy(w, rc)
+	}
+	return nil
+}
+
+// streamArchiveZip re-packs a folder of an archive as a zip download.
+// The root of a .zip archive is served as the original file.
+func (h *FileHandler) streamArchiveZip(w http.ResponseWriter, r *http.Request, archivePath string, prefix string) {
+	h.countZip()
+	name := filepath.Base(archivePath)
+	if prefix != "" {
+		name = pathpkg.Base(strings.TrimSuffix(prefix, "/"))
+	}
+	if prefix == "" && strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		f, err := os.Open(archivePath)
+		if err != nil {
+			http.Error(w, "Unable to open archive", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, "Unable to open archive", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", name))
+
+	zw := zip.NewWriter(w)
+	err := walkArchive(archivePath, func(e archiveEntry, open func() (io.ReadCloser, error)) error {
+		if e.isDir || !strings.HasPrefix(e.name, prefix) {
+			return nil
+		}
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		zipFile, err := zw.Create(strings.TrimPrefix(e.name, prefix))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(zipFile, rc)
+		return err
+	})
+	if err != nil {
+		// Headers are gone, so cut the connection rather than finish the
+		// zip: the client sees a broken download instead of a short one
+		panic(http.ErrAbortHandler)
+	}
+	zw.Close()
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SumsFileName is the virtual checksum list offered in every directory
+const SumsFileName = "SHA256SUMS"
+
+// newHasher returns a hash.Hash for a supported algorithm name
+func newHasher(algo string) (hash.Hash, bool) {
+	switch strings.ToLower(algo) {
+	case "sha256":
+		return sha256.New(), true
+	case "sha1":
+		return sha1.New(), true
+	case "md5":
+		return md5.New(), true
+	}
+	return nil, false
+}
+
+type hashKey struct {
+	path string
+	algo string
+}
+
+type hashEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// hashCall is a digest being computed; other callers for the same file
+// wait on done instead of reading it again
+type hashCall struct {
+	done    chan struct{}
+	size    int64
+	modTime time.Time
+	sum     string
+	err     error
+}
+
+// hashCache remembers file digests until the file's size or mtime changes.
+// Each file is hashed by one caller at a time.
+type hashCache struct {
+	mu       sync.Mutex
+	entries  map[hashKey]hashEntry
+	inflight map[hashKey]*hashCall
+}
+
+// fileHash returns the hex digest of path, computing it only when the cached
+// value is missing or stale.
+func (c *hashCache) fileHash(path string, algo string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", filepath.Base(path))
+	}
+	h, ok := newHasher(algo)
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	key := hashKey{path: path, algo: strings.ToLower(algo)}
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		c.mu.Unlock()
+		return e.sum, nil
+	}
+	if call := c.inflight[key]; call != nil && call.size == info.Size() && call.modTime.Equal(info.ModTime()) {
+		c.mu.Unlock()
+		<-call.done
+		return call.sum, call.err
+	}
+	call := &hashCall{done: make(chan struct{}), size: info.Size(), modTime: info.ModTime()}
+	if c.inflight == nil {
+		c.inflight = make(map[hashKey]*hashCall)
+	}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.sum, call.err = hashFile(path, h)
+
+	c.mu.Lock()
+	if call.err == nil {
+		if c.entries == nil {
+			c.entries = make(map[hashKey]hashEntry)
+		}
+		c.entries[key] = hashEntry{size: call.size, modTime: call.modTime, sum: call.sum}
+	}
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.sum, call.err
+}
+
+// cachedHash returns the digest of path if it is already known and still
+// fresh. Otherwise it starts hashing the file in the background, unless that
+// is already under way, and returns ok false.
+func (c *hashCache) cachedHash(path string, algo string) (sum string, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	key := hashKey{path: path, algo: strings.ToLower(algo)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.entries[key]; found && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.sum, true
+	}
+	if call := c.inflight[key]; call == nil || call.size != info.Size() || !call.modTime.Equal(info.ModTime()) {
+		go c.fileHash(path, algo)
+	}
+	return "", false
+}
+
+// hashFile feeds the contents of path to h and returns its hex digest
+func hashFile(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// serveHash answers ?hash=<algo> with a line in the `sha256sum` output format
+func (h *FileHandler) serveHash(w http.ResponseWriter, fsPath string, algo string) {
+	if _, ok := newHasher(algo); !ok {
+		http.Error(w, "Unsupported hash algorithm (use sha256, sha1 or md5)", http.StatusBadRequest)
+		return
+	}
+	sum, err := h.hashes.fileHash(fsPath, algo)
+	if err != nil {
+		http.Error(w, "Unable to hash file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s  %s\n", sum, filepath.Base(fsPath))
+}
+
+// serveSums generates a SHA256SUMS list for the regular files in dirPath
+func (h *FileHandler) serveSums(w http.ResponseWriter, dirPath string) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		http.Error(w, "Unable to read directory", http.StatusInternalServerError)
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		sum, err := h.hashes.fileHash(filepath.Join(dirPath, name), "sha256")
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum, name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", SumsFileName))
+	io.WriteString(w, b.String())
+}
+
+// isVirtualSums reports whether fsPath should be served as a generated
+// SHA256SUMS file (there is no real file of that name in the directory)
+func isVirtualSums(fsPath string) bool {
+	if filepath.Base(fsPath) != SumsFileName {
+		return false
+	}
+	if _, err := os.Stat(fsPath); err == nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Dir(fsPath))
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"JustServe/pkg/errcode"
+)
+
+// Conn is a live client connection of a share or proxy
+type Conn struct {
+	ID       string    `json:"id"`
+	Remote   string    `json:"remote"`           // Client address; behind a tunnel, the forwarded client IP
+	User     string    `json:"user,omitempty"`   // Basic auth user name of the last request
+	Method   string    `json:"method,omitempty"` // Request in progress, empty while idle and for TCP
+	Path     string    `json:"path,omitempty"`
+	Active   bool      `json:"active"`   // Serving a request; TCP connections are always active
+	Since    time.Time `json:"since"`    // Connected at
+	Requests int64     `json:"requests"` // HTTP requests served on the connection
+	Bytes    int64     `json:"bytes"`    // Sent to the client
+	Speed    int64     `json:"speed"`    // Bytes per second of the request in progress, or of a TCP connection
+}
+
+// ErrNoConn is returned by ConnTracker.Kill for an unknown connection
+var ErrNoConn = errcode.New(errcode.ConnNotFound, "no such connection")
+
+// liveConn is the state of one tracked connection
+type liveConn struct {
+	id    string
+	conn  net.Conn
+	since time.Time
+	tcp   bool
+	bytes int64 // Atomic
+
+	mu       sync.Mutex
+	remote   string
+	user     string
+	method   string
+	path     string
+	requests int64
+	reqStart time.Time // Zero while idle
+	reqBytes int64     // Atomic; sent for the request in progress
+}
+
+// ConnTracker keeps the live connections of a session so they can be listed
+// and cut off one by one. An http.Server reports to it through ConnState and
+// ConnContext, with its handler wrapped by Wrap; TCP proxies call Track.
+type ConnTracker struct {
+	forwarded bool // Trust X-Forwarded-For, set by the ngrok edge
+
+	mu    sync.Mutex
+	conns map[net.Conn]*liveConn
+	next  int64
+}
+
+// NewConnTracker creates an empty tracker. With forwarded set the client
+// address is taken from X-Forwarded-For, for servers behind a tunnel.
+func NewConnTracker(forwarded bool) *ConnTracker {
+	return &ConnTracker{forwarded: forwarded, conns: make(map[net.Conn]*liveConn)}
+}
+
+func (t *ConnTracker) add(c net.Conn, tcp bool) *liveConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if lc, ok := t.conns[c]; ok {
+		return lc
+	}
+	t.next++
+	lc := &liveConn{id: strconv.FormatInt(t.next, 10), conn: c, since: time.Now(), tcp: tcp, remote: c.RemoteAddr().String()}
+	t.conns[c] = lc
+	return lc
+}
+
+func (t *ConnTracker) remove(c net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+}
+
+// ConnState is the http.Server ConnState hook
+func (t *ConnTracker) ConnState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		t.add(c, false)
+	case http.StateHijacked, http.StateClosed:
+		t.remove(c)
+	}
+}
+
+type connKey struct{}
+
+// ConnContext is the http.Server ConnContext hook; it lets Wrap find the
+// connection of a request
+func (t *ConnTracker) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// Wrap records the request in progress and the bytes sent on each connection
+func (t *ConnTracker) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := r.Context().Value(connKey{}).(net.Conn)
+		t.mu.Lock()
+		lc := t.conns[c]
+		t.mu.Unlock()
+		if lc == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, _, _ := r.BasicAuth()
+		lc.mu.Lock()
+		if t.forwarded {
+			if ip := forwardedFor(r); ip != "" {
+				lc.remote = ip
+			}
+		}
+		lc.user, lc.method, lc.path = user, r.Method, r.URL.Path
+		lc.requests++
+		lc.reqStart = time.Now()
+		atomic.StoreInt64(&lc.reqBytes, 0)
+		lc.mu.Unlock()
+		defer func() {
+			lc.mu.Lock()
+			lc.method, lc.path, lc.reqStart = "", "", time.Time{}
+			lc.mu.Unlock()
+		}()
+
+		next.ServeHTTP(&connWriter{ResponseWriter: w, lc: lc}, r)
+	})
+}
+
+// forwardedFor returns the client IP a proxy put first in X-Forwarded-For
+func forwardedFor(r *http.Request) string {
+	first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ",")
+	return strings.TrimSpace(first)
+}
+
+// ClientIP returns the IP address of the client of r. With forwarded set it
+// is taken from X-Forwarded-For, for servers behind a tunnel.
+func ClientIP(r *http.Request, forwarded bool) string {
+	if forwarded {
+		if ip := forwardedFor(r); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// connWriter counts the bytes a request sends on its connection
+type connWriter struct {
+	http.ResponseWriter
+	lc *liveConn
+}
+
+func (cw *connWriter) Write(p []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(p)
+	atomic.AddInt64(&cw.lc.bytes, int64(n))
+	atomic.AddInt64(&cw.lc.reqBytes, int64(n))
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *connWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// trackedConn is a TCP proxy connection registered with a tracker
+type trackedConn struct {
+	net.Conn
+	t  *ConnTracker
+	lc *liveConn
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.lc.bytes, int64(n))
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.t.remove(c.lc.conn)
+	return c.Conn.Close()
+}
+
+// Track registers a proxied TCP connection until the returned conn is closed
+func (t *ConnTracker) Track(c net.Conn) net.Conn {
+	return &trackedConn{Conn: c, t: t, lc: t.add(c, true)}
+}
+
+// List returns the live connections, oldest first
+func (t *ConnTracker) List() []Conn {
+	t.mu.Lock()
+	live := make([]*liveConn, 0, len(t.conns))
+	for _, lc := range t.conns {
+		live = append(live, lc)
+	}
+	t.mu.Unlock()
+
+	now := time.Now()
+	list := make([]Conn, len(live))
+	for i, lc := range live {
+		bytes := atomic.LoadInt64(&lc.bytes)
+		lc.mu.Lock()
+		c := Conn{
+			ID:       lc.id,
+			Remote:   lc.remote,
+			User:     lc.user,
+			Method:   lc.method,
+			Path:     lc.path,
+			Active:   lc.tcp || !lc.reqStart.IsZero(),
+			Since:    lc.since,
+			Requests: lc.requests,
+			Bytes:    bytes,
+		}
+		switch {
+		case lc.tcp:
+			c.Speed = rate(bytes, now.Sub(lc.since))
+		case !lc.reqStart.IsZero():
+			c.Speed = rate(atomic.LoadInt64(&lc.reqBytes), now.Sub(lc.reqStart))
+		}
+		lc.mu.Unlock()
+		list[i] = c
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Since.Before(list[j].Since) })
+	return list
+}
+
+// rate is n bytes over d in bytes per second
+func rate(n int64, d time.Duration) int64 {
+	if d < time.Second {
+		d = time.Second // Avoid wild figures right after a start
+	}
+	return int64(float64(n) / d.Seconds())
+}
+
+// Kill closes the connection with the given ID, cutting off its request
+func (t *ConnTracker) Kill(id string) error {
+	t.mu.Lock()
+	var target *liveConn
+	for _, lc := range t.conns {
+		if lc.id == id {
+			target = lc
+			break
+		}
+	}
+	if target != nil {
+		delete(t.conns, target.conn)
+	}
+	t.mu.Unlock()
+	if target == nil {
+		return fmt.Errorf("%w %q", ErrNoConn, id)
+	}
+	return target.conn.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package server
+
+import "errors"
+
+// freeSpace is not implemented on this platform; the disk-space guard is skipped.
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// DownloadFunc is told about every download a share sent in full: a file, a
+// file inside an archive or a zipped folder. size is the bytes sent.
+type DownloadFunc func(r *http.Request, size int64)
+
+// SetDownloadHook sets the function told about completed downloads (nil for
+// none). A download resumed over several requests counts once its client got
+// every byte; with forwarded set, clients are told apart by X-Forwarded-For
+// (see ClientIP).
+func (h *FileHandler) SetDownloadHook(fn DownloadFunc, forwarded bool) {
+	h.onDownload, h.forwarded = fn, forwarded
+}
+
+// downloadWriter notes what a download response sent
+type downloadWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	failed  bool // A write to the client failed
+}
+
+func (dw *downloadWriter) WriteHeader(status int) {
+	if dw.status == 0 {
+		dw.status = status
+	}
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *downloadWriter) Write(p []byte) (int, error) {
+	if dw.status == 0 {
+		dw.status = http.StatusOK
+	}
+	n, err := dw.ResponseWriter.Write(p)
+	dw.written += int64(n)
+	if err != nil {
+		dw.failed = true
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (dw *downloadWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// complete reports whether the whole file went out in this response: a 200,
+// or a 206 from the first byte to the last, with every announced byte written
+func (dw *downloadWriter) complete() bool {
+	if dw.failed {
+		return false
+	}
+	switch dw.status {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		var first, last, total int64
+		if _, err := fmt.Sscanf(dw.Header().Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total); err != nil || first != 0 || last != total-1 {
+			return false
+		}
+	default:
+		return false
+	}
+	if n, err := strconv.ParseInt(dw.Header().Get("Content-Length"), 10, 64); err == nil && n != dw.written {
+		return false
+	}
+	return true
+}
+
+// span returns the bytes [start, end) of a file of total bytes that this
+// response sent, for joining the parts of a resumed download
+func (dw *downloadWriter) span() (start, end, total int64, ok bool) {
+	switch dw.status {
+	case http.StatusOK:
+		total, err := strconv.ParseInt(dw.Header().Get("Content-Length"), 10, 64)
+		return 0, dw.written, total, err == nil
+	case http.StatusPartialContent:
+		var last int64
+		if _, err := fmt.Sscanf(dw.Header().Get("Content-Range"), "bytes %d-%d/%d", &start, &last, &total); err != nil {
+			return 0, 0, 0, false
+		}
+		return start, start + dw.written, total, true
+	}
+	return 0, 0, 0, false
+}
+
+// serveDownload runs serve and reports the download to the hook if it was
+// sent in full
+func (h *FileHandler) serveDownload(w http.ResponseWriter, r *http.Request, serve func(w http.ResponseWriter)) {
+	if h.onDownload == nil || r.Method != http.MethodGet {
+		serve(w)
+		return
+	}
+	dw := &downloadWriter{ResponseWriter: w}
+	serve(dw)
+	key := ClientIP(r, h.forwarded) + " " + r.URL.Path
+	if dw.complete() && r.Context().Err() == nil {
+		h.ranges.forget(key)
+		h.onDownload(r, dw.written)
+		return
+	}
+	// Otherwise it may be one part of a download the client resumes
+	if start, end, total, ok := dw.span(); ok {
+		if done, sent := h.ranges.add(key, start, end, total); done {
+			h.onDownload(r, sent)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import "time"
+
+// SetExpiry shows the time left until t on the pages of the share (zero for none)
+func (h *FileHandler) SetExpiry(t time.Time) {
+	h.expiresAt = t
+}
+
+// pageExpiry is the expiry shown on a page; the page counts down to At
+type pageExpiry struct {
+	At    string // RFC 3339
+	Label string // Shown without JavaScript
+}
+
+// expiry returns the expiry for page templates, nil when the share has none
+func (h *FileHandler) expiry() *pageExpiry {
+	if h.expiresAt.IsZero() {
+		return nil
+	}
+	return &pageExpiry{
+		At:    h.expiresAt.Format(time.RFC3339),
+		Label: h.expiresAt.Format("Mon 2 Jan 15:04 MST"),
+	}
+}
@@ -5,22 +5,43 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 	"html/template"
 	"embed"
+
+	"JustServe/pkg/events"
+	"JustServe/pkg/qr"
+	"JustServe/pkg/utils"
 )
 
 //go:embed templates/*.html
 var templateFS embed.FS
 
 type FileHandler struct {
-	root        string
-	isFile      bool
-	password    string
-	allowUpload bool
-	fileServer  http.Handler
+	root     string
+	isFile   bool
+	password string
+
+	// Folders served by the share. A plain folder share has a single unnamed
+	// mount at "/"; multi is set when the share is built from named mounts.
+	mounts []*mountPoint
+	multi  bool
+
+	limits   UploadLimits
+	uploaded int64 // Bytes uploaded so far, counted against limits.Quota
+	zips     int64 // Zip downloads streamed
+	events   events.Sink
+	hashes   hashCache
+
+	onDownload DownloadFunc // Told about completed downloads, if set
+	forwarded  bool         // Client IPs come from X-Forwarded-For
+	ranges     rangeTracker // Parts of resumed downloads
+	expiresAt  time.Time    // Shown as the time left on pages, zero for none
 }
 
 func NewFileHandler(root string, password string, allowUpload bool) *FileHandler {
@@ -39,14 +60,47 @@ func NewFileHandler(root string, password string, allowUpload bool) *FileHandler
 	}
 
 	return &FileHandler{
-		root:        root,
-		isFile:      isFile,
-		password:    password,
-		allowUpload: allowUpload,
-		fileServer:  fs,
+		root:     root,
+		isFile:   isFile,
+		password: password,
+		events:   events.Discard,
+		mounts: []*mountPoint{{
+			Mount:      Mount{Path: root, AllowUpload: allowUpload},
+			fileServer: fs,
+		}},
+	}
+}
+
+// SetUploadLimits configures size, quota, type and disk-space limits for uploads
+func (h *FileHandler) SetUploadLimits(limits UploadLimits) {
+	h.limits = limits
+}
+
+// SetEventSink sets where handler events such as "upload-rejected" go
+// (nil discards them)
+func (h *FileHandler) SetEventSink(sink events.Sink) {
+	h.events = events.OrDiscard(sink)
+}
+
+// Counters are the traffic counters kept by a FileHandler
+type Counters struct {
+	UploadedBytes int64 // Bytes of stored uploads; failed uploads are not counted
+	ZipStreams    int64 // Folders, mounts and archive folders downloaded as zip
+}
+
+// Counters returns the current traffic counters
+func (h *FileHandler) Counters() Counters {
+	return Counters{
+		UploadedBytes: atomic.LoadInt64(&h.uploaded),
+		ZipStreams:    atomic.LoadInt64(&h.zips),
 	}
 }
 
+// countZip records a zip download
+func (h *FileHandler) countZip() {
+	atomic.AddInt64(&h.zips, 1)
+}
+
 func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 1. Basic Auth Check
 	if h.password != "" {
@@ -61,26 +115,23 @@ func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		_ = username
 	}
 
-	// 2. Upload Handling (Only for directories)
-	if !h.isFile && h.allowUpload && r.Method == http.MethodPost {
-		// handle upload
-		h.handleUpload(w, r)
-		return
-	}
-
-	// 3. Single File Mode
+	// 2. Single File Mode
 	if h.isFile {
 		filename := filepath.Base(h.root)
 		
 		// If requesting the root, show download page (metadata)
 		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
-			h.serveSingleFilePage(w, h.root)
+			h.serveSingleFilePage(w, r, h.root)
 			return
 		}
 
 		// If requesting the specific file
 		if strings.TrimPrefix(r.URL.Path, "/") == filename {
-			http.ServeFile(w, r, h.root)
+			if algo := r.URL.Query().Get("hash"); algo != "" {
+				h.serveHash(w, h.root, algo)
+				return
+			}
+			h.serveDownload(w, r, func(w http.ResponseWriter) { http.ServeFile(w, r, h.root) })
 			return
 		}
 
@@ -89,70 +140,100 @@ func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 4. Directory Listing & File Serving
-	// Check if path is a directory
-	path := filepath.Join(h.root, r.URL.Path)
-	info, err := os.Stat(path)
-	if err == nil && info.IsDir() {
-		// Checks for Zip download request
+	// 3. Resolve the mount point serving this path
+	mount, path, ok := h.resolve(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if mount == nil {
+		// Virtual root of a multi-mount share
+		if r.Method == http.MethodPut {
+			http.Error(w, "Upload into one of the mount points", http.StatusMethodNotAllowed)
+			return
+		}
 		if r.URL.Query().Get("download") == "zip" {
-			h.streamZip(w, path, filepath.Base(path))
+			h.serveDownload(w, r, h.streamMountsZip)
 			return
 		}
+		h.serveMountList(w)
+		return
+	}
 
-		// Serve custom directory listing
-		h.serveDirectory(w, r.URL.Path, path)
+	// 4. Raw PUT uploads (curl -T, CI scripts)
+	if r.Method == http.MethodPut {
+		if !mount.AllowUpload {
+			http.Error(w, "Uploads are disabled for this share", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handlePut(w, r, path)
 		return
 	}
 
-	// Default file server
-	h.fileServer.ServeHTTP(w, r)
-}
+	// 5. Browse inside .zip/.tar/.tar.gz files as virtual directories
+	if archivePath, inner, ok := splitArchivePath(mount.Path, path, strings.HasSuffix(r.URL.Path, "/")); ok {
+		h.serveArchive(w, r, archivePath, inner)
+		return
+	}
 
-func (h *FileHandler) handleUpload(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form
-	// Limit upload size to 10GB (arbitrary large limit)
-	err := r.ParseMultipartForm(10 << 30) // 10 GB
-	if err != nil {
-		http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+	// 6. Upload Handling (Only for directories of mounts that allow it)
+	info, err := os.Stat(path)
+	if mount.AllowUpload && r.Method == http.MethodPost {
+		if err != nil || !info.IsDir() {
+			http.Error(w, "Upload target is not a folder", http.StatusNotFound)
+			return
+		}
+		h.handleUpload(w, r, path)
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+	// 7. Directory Listing & File Serving
+	// Check if path is a directory
+	if err == nil && info.IsDir() {
+		// Checks for Zip download request
+		if r.URL.Query().Get("download") == "zip" {
+			h.serveDownload(w, r, func(w http.ResponseWriter) { h.streamZip(w, path, filepath.Base(path)) })
+			return
+		}
+
+		// Serve custom directory listing
+		h.serveDirectory(w, r.URL.Path, path, mount.AllowUpload)
 		return
 	}
-	defer file.Close()
 
-	// Create destination file
-	dstPath := filepath.Join(h.root, header.Filename)
-	dst, err := os.Create(dstPath)
-	if err != nil {
-		http.Error(w, "Error creating the file on server", http.StatusInternalServerError)
+	// Checksums for a single file (?hash=sha256|sha1|md5)
+	if algo := r.URL.Query().Get("hash"); algo != "" && err == nil {
+		h.serveHash(w, path, algo)
 		return
 	}
-	defer dst.Close()
 
-	// Copy file
-	if _, err := io.Copy(dst, file); err != nil {
-		http.Error(w, "Error saving the file", http.StatusInternalServerError)
+	// Generated checksum list for the directory
+	if err != nil && isVirtualSums(path) {
+		h.serveSums(w, filepath.Dir(path))
 		return
 	}
 
-	// Redirect back to root or success page
-	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+	// Default file server
+	h.serveDownload(w, r, func(w http.ResponseWriter) { mount.fileServer.ServeHTTP(w, r) })
 }
 
 func (h *FileHandler) streamZip(w http.ResponseWriter, dirPath string, dirName string) {
+	h.countZip()
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", dirName))
 
 	zw := zip.NewWriter(w)
-	defer zw.Close()
+	if err := addDirToZip(zw, dirPath, ""); err != nil {
+		// Cut the connection rather than finish a zip missing files
+		panic(http.ErrAbortHandler)
+	}
+	zw.Close()
+}
 
+// addDirToZip writes every file below dirPath into zw, prefixing entry names
+func addDirToZip(zw *zip.Writer, dirPath string, prefix string) error {
 	// streamZip with optimized WalkDir
-	filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
+	return filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -168,7 +249,7 @@ func (h *FileHandler) streamZip(w http.ResponseWriter, dirPath string, dirName s
 		// Use forward slashes for zip compatibility
 		relPath = filepath.ToSlash(relPath)
 
-		zipFile, err := zw.Create(relPath)
+		zipFile, err := zw.Create(prefix + relPath)
 		if err != nil {
 			return err
 		}
@@ -184,7 +265,7 @@ func (h *FileHandler) streamZip(w http.ResponseWriter, dirPath string, dirName s
 	})
 }
 
-func (h *FileHandler) serveSingleFilePage(w http.ResponseWriter, path string) {
+func (h *FileHandler) serveSingleFilePage(w http.ResponseWriter, r *http.Request, path string) {
 	info, err := os.Stat(path)
 	if err != nil {
 		http.Error(w, "File not found", http.StatusNotFound)
@@ -200,37 +281,61 @@ func (h *FileHandler) serveSingleFilePage(w http.ResponseWriter, path string) {
 	// Reusing similar template structure for consistency
 	var t *template.Template
 	var errTmp error
-	if t, errTmp = template.ParseFS(templateFS, "templates/single_file.html"); errTmp != nil {
+	if t, errTmp = template.ParseFS(templateFS, "templates/single_file.html", "templates/expiry.html"); errTmp != nil {
 		http.Error(w, "Template error: " + errTmp.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Shown next to the size so recipients can verify the download, once
+	// known; large files are hashed in the background rather than holding
+	// up the page
+	hash, _ := h.hashes.cachedHash(path, "sha256")
+	// Lets a visitor on a laptop hand the page over to a phone
+	qrURI, _ := qr.SVGDataURI(utils.RequestURL(r))
+
 	data := struct {
-		Name string
-		Size string
+		Name   string
+		Size   string
+		Hash   string
+		QR     template.URL
+		Expiry *pageExpiry
 	}{
-		Name: filename,
-		Size: size,
+		Name:   filename,
+		Size:   size,
+		Hash:   hash,
+		QR:     template.URL(qrURI),
+		Expiry: h.expiry(),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	t.Execute(w, data)
 }
 
-func (h *FileHandler) serveDirectory(w http.ResponseWriter, requestPath string, fsPath string) {
+// fileEntry is a row of the directory listing page
+type fileEntry struct {
+	Name   string
+	IsDir  bool
+	Size   string
+	Icon   string
+	Href   string // Relative link, built in Go so html/template can escape it as one URL
+	Browse string // Link to the contents of an archive, listed like a folder
+}
+
+// entryHref links to a listing entry relative to the current directory
+func entryHref(name string, isDir bool) string {
+	href := url.PathEscape(name)
+	if isDir {
+		href += "/"
+	}
+	return href
+}
+
+func (h *FileHandler) serveDirectory(w http.ResponseWriter, requestPath string, fsPath string, allowUpload bool) {
 	entries, err := os.ReadDir(fsPath)
 	if err != nil {
 		http.Error(w, "Unable to read directory", http.StatusInternalServerError)
 		return
 	}
 
-	type FileEntry struct {
-		Name   string
-		IsDir  bool
-		Size   string
-		Icon   string
-		Params string
-	}
-
-	var fileList []FileEntry
+	var fileList []fileEntry
 	
 	// Add Go Back link if not root
 	if requestPath != "/" {
@@ -241,7 +346,7 @@ func (h *FileHandler) serveDirectory(w http.ResponseWriter, requestPath string,
 		parent = filepath.ToSlash(parent)
 		if !strings.HasPrefix(parent, "/") { parent = "/" + parent }
 		
-		fileList = append(fileList, FileEntry{Name: "..", IsDir: true, Size: "-", Icon: "↩️", Params: "?dir=back"}) 
+		fileList = append(fileList, fileEntry{Name: "..", IsDir: true, Size: "-", Icon: "↩️", Href: "../"}) 
 	}
 
 	// Sort entries: Directories first, then files. Both alphabetical.
@@ -263,57 +368,89 @@ func (h *FileHandler) serveDirectory(w http.ResponseWriter, requestPath string,
 			if entry.IsDir() {
 				icon = "📁"
 			} else {
-				ext := strings.ToLower(filepath.Ext(entry.Name()))
-				switch ext {
-				case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".ico":
-					icon = "🖼️"
-				case ".mp4", ".mov", ".avi", ".mkv", ".webm":
-					icon = "🎬"
-				case ".mp3", ".wav", ".ogg", ".flac":
-					icon = "🎵"
-				case ".pdf":
-					icon = "📕"
-				case ".zip", ".rar", ".7z", ".tar", ".gz":
-					icon = "📦"
-				case ".exe", ".msi", ".bat", ".sh":
-					icon = "💿"
-				case ".txt", ".md", ".json", ".xml", ".yaml", ".css", ".js", ".html", ".go", ".py":
-					icon = "📝"
-				}
-				size = fmt.Sprintf("%.2f KB", float64(info.Size())/1024)
-				if info.Size() > 1024*1024 {
-					size = fmt.Sprintf("%.2f MB", float64(info.Size())/(1024*1024))
-				}
+				icon = fileIcon(entry.Name())
+				size = formatSize(info.Size())
 			}
 		}
-		
-		fileList = append(fileList, FileEntry{
-			Name:  entry.Name(),
-			IsDir: entry.IsDir(),
-			Size:  size,
-			Icon:  icon,
+
+		// Archives download as files and are also browsable like folders (archive.zip/)
+		var browse string
+		if !entry.IsDir() && isArchive(entry.Name()) {
+			browse = entryHref(entry.Name(), true)
+		}
+
+		fileList = append(fileList, fileEntry{
+			Name:   entry.Name(),
+			IsDir:  entry.IsDir(),
+			Size:   size,
+			Icon:   icon,
+			Href:   entryHref(entry.Name(), entry.IsDir()),
+			Browse: browse,
 		})
 	}
 	
 	for _, d := range dirs { processEntry(d) }
 	for _, f := range files { processEntry(f) }
 
+	// Offer a generated SHA256SUMS unless the folder already ships one
+	if len(files) > 0 {
+		if _, err := os.Stat(filepath.Join(fsPath, SumsFileName)); err != nil {
+			fileList = append(fileList, fileEntry{Name: SumsFileName, Size: "-", Icon: "🔐", Href: SumsFileName})
+		}
+	}
+
+	h.renderListing(w, requestPath, fileList, allowUpload)
+}
+
+// fileIcon picks a listing icon from the file extension
+func fileIcon(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".ico":
+		return "🖼️"
+	case ".mp4", ".mov", ".avi", ".mkv", ".webm":
+		return "🎬"
+	case ".mp3", ".wav", ".ogg", ".flac":
+		return "🎵"
+	case ".pdf":
+		return "📕"
+	case ".zip", ".rar", ".7z", ".tar", ".gz", ".tgz":
+		return "📦"
+	case ".exe", ".msi", ".bat", ".sh":
+		return "💿"
+	case ".txt", ".md", ".json", ".xml", ".yaml", ".css", ".js", ".html", ".go", ".py":
+		return "📝"
+	}
+	return "📄"
+}
+
+// formatSize renders a file size for the listing
+func formatSize(size int64) string {
+	if size > 1024*1024 {
+		return fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+	}
+	return fmt.Sprintf("%.2f KB", float64(size)/1024)
+}
+
+// renderListing renders the directory listing template
+func (h *FileHandler) renderListing(w http.ResponseWriter, requestPath string, fileList []fileEntry, allowUpload bool) {
 	// HTML Template - Modernized
 	var t *template.Template
 	var errTmp error
-	if t, errTmp = template.ParseFS(templateFS, "templates/directory_listing.html"); errTmp != nil {
+	if t, errTmp = template.ParseFS(templateFS, "templates/directory_listing.html", "templates/expiry.html"); errTmp != nil {
 		http.Error(w, "Template error: " + errTmp.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	data := struct {
 		Path        string
-		Files       []FileEntry
+		Files       []fileEntry
 		AllowUpload bool
+		Expiry      *pageExpiry
 	}{
 		Path:        requestPath,
 		Files:       fileList,
-		AllowUpload: h.allowUpload,
+		AllowUpload: allowUpload,
+		Expiry:      h.expiry(),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
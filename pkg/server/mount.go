@@ -0,0 +1,133 @@
+package server
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"JustServe/pkg/events"
+)
+
+// Mount maps a folder on disk to a named mount point (/<Name>) of a share
+type Mount struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	AllowUpload bool   `json:"allowUpload"`
+}
+
+// mountPoint is a Mount prepared for serving
+type mountPoint struct {
+	Mount
+	fileServer http.Handler
+}
+
+// NewMountHandler creates a handler that serves several folders under one share.
+// The root listing shows the mount points; each mount has its own upload flag.
+func NewMountHandler(mounts []Mount, password string) (*FileHandler, error) {
+	if len(mounts) == 0 {
+		return nil, fmt.Errorf("at least one mount point is required")
+	}
+
+	h := &FileHandler{
+		password: password,
+		multi:    true,
+		events:   events.Discard,
+	}
+	seen := make(map[string]bool)
+	for _, m := range mounts {
+		info, err := os.Stat(m.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access mount %q: %w", m.Path, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("mount %q is not a folder", m.Path)
+		}
+
+		// Default the name to the folder name and keep it to a single URL segment
+		name := strings.Trim(m.Name, "/")
+		if name == "" {
+			name = filepath.Base(m.Path)
+		}
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return nil, fmt.Errorf("invalid mount name %q", m.Name)
+		}
+		// Names match in any case (see resolve), so these would collide
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("duplicate mount name %q", name)
+		}
+		seen[strings.ToLower(name)] = true
+
+		m.Name = name
+		h.mounts = append(h.mounts, &mountPoint{
+			Mount:      m,
+			fileServer: stripMount(http.FileServer(http.Dir(m.Path))),
+		})
+	}
+	return h, nil
+}
+
+// resolve maps a request path to the mount serving it and the path on disk,
+// without letting ".." segments escape the mount. A nil mount with ok=true is
+// the virtual root of a multi-mount share.
+func (h *FileHandler) resolve(urlPath string) (m *mountPoint, fsPath string, ok bool) {
+	clean := pathpkg.Clean("/" + urlPath)
+	if !h.multi {
+		m = h.mounts[0]
+		return m, filepath.Join(m.Path, filepath.FromSlash(clean)), true
+	}
+	if clean == "/" {
+		return nil, "", true
+	}
+
+	name, rest, _ := strings.Cut(strings.TrimPrefix(clean, "/"), "/")
+	for _, mp := range h.mounts {
+		if strings.EqualFold(mp.Name, name) {
+			return mp, filepath.Join(mp.Path, filepath.FromSlash("/"+rest)), true
+		}
+	}
+	return nil, "", false
+}
+
+// stripMount removes the mount name, in whatever case resolve matched it,
+// from the request path before next serves it
+func stripMount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, rest, _ := strings.Cut(strings.TrimPrefix(pathpkg.Clean("/"+r.URL.Path), "/"), "/")
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path, r2.URL.RawPath = "/"+rest, ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// serveMountList renders the root of a multi-mount share
+func (h *FileHandler) serveMountList(w http.ResponseWriter) {
+	var fileList []fileEntry
+	for _, m := range h.mounts {
+		fileList = append(fileList, fileEntry{Name: m.Name, IsDir: true, Size: "-", Icon: "🗂️", Href: entryHref(m.Name, true)})
+	}
+	h.renderListing(w, "/", fileList, false)
+}
+
+// streamMountsZip zips every mount of the share, each under its own folder
+func (h *FileHandler) streamMountsZip(w http.ResponseWriter) {
+	h.countZip()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"JustServe.zip\"")
+
+	zw := zip.NewWriter(w)
+	for _, m := range h.mounts {
+		if err := addDirToZip(zw, m.Path, m.Name+"/"); err != nil {
+			// Cut the connection rather than finish a zip missing files
+			panic(http.ErrAbortHandler)
+		}
+	}
+	zw.Close()
+}
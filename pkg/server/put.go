@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// PutResult is the JSON response of a successful PUT upload
+type PutResult struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	MD5     string `json:"md5"`
+	Created bool   `json:"created"` // false when an existing file was replaced
+}
+
+// digestReader hashes and counts everything read through it
+type digestReader struct {
+	r      io.Reader
+	md5    hash.Hash
+	sha256 hash.Hash
+	n      int64
+}
+
+func newDigestReader(r io.Reader) *digestReader {
+	return &digestReader{r: r, md5: md5.New(), sha256: sha256.New()}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if n > 0 {
+		d.md5.Write(p[:n])
+		d.sha256.Write(p[:n])
+		d.n += int64(n)
+	}
+	return n, err
+}
+
+// expectedDigests collects the digests a client asked us to verify, from
+// Content-MD5 (RFC 1864), Digest (RFC 3230) and Content-Digest/Repr-Digest
+// (RFC 9530). Keys are "md5" and "sha-256"; values are raw digest bytes.
+func expectedDigests(header http.Header) (map[string][]byte, error) {
+	want := make(map[string][]byte)
+	add := func(algo string, encoded string) error {
+		algo = strings.ToLower(strings.TrimSpace(algo))
+		if algo != "md5" && algo != "sha-256" {
+			return nil // Ignore algorithms we don't compute
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(encoded), ":"))
+		if err != nil {
+			return fmt.Errorf("malformed %s digest", algo)
+		}
+		want[algo] = raw
+		return nil
+	}
+
+	if v := header.Get("Content-MD5"); v != "" {
+		if err := add("md5", v); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range []string{"Digest", "Content-Digest", "Repr-Digest"} {
+		for _, item := range strings.Split(header.Get(name), ",") {
+			algo, value, ok := strings.Cut(item, "=")
+			if !ok {
+				continue
+			}
+			if err := add(algo, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return want, nil
+}
+
+// handlePut streams a raw request body to fsPath, e.g. `curl -T build.tgz http://host:8080/builds/`.
+// It follows the same upload limits and overwrite behaviour as multipart uploads.
+func (h *FileHandler) handlePut(w http.ResponseWriter, r *http.Request, fsPath string) {
+	if strings.HasSuffix(r.URL.Path, "/") {
+		http.Error(w, "PUT needs a file name in the URL", http.StatusBadRequest)
+		return
+	}
+	info, err := os.Stat(fsPath)
+	if err == nil && info.IsDir() {
+		http.Error(w, "A folder with that name already exists", http.StatusConflict)
+		return
+	}
+	created := err != nil
+
+	dir, filename := filepath.Dir(fsPath), filepath.Base(fsPath)
+	if !h.limits.allowsName(filename) {
+		h.rejectUpload(w, "type", filename, fmt.Errorf("file type of %q is not allowed", filename))
+		return
+	}
+	if h.limits.MaxFileSize > 0 && r.ContentLength > h.limits.MaxFileSize {
+		h.rejectUpload(w, "size", filename, errFileTooLarge)
+		return
+	}
+	if h.limits.Quota > 0 && r.ContentLength > 0 &&
+		atomic.LoadInt64(&h.uploaded)+r.ContentLength > h.limits.Quota {
+		h.rejectUpload(w, "quota", filename, errQuotaExceeded)
+		return
+	}
+
+	want, err := expectedDigests(r.Header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Missing parent folders are only created once the upload is accepted,
+	// so measure free space on the nearest one that exists
+	existing := existingParent(dir)
+	if err := h.limits.checkFreeSpace(existing, r.ContentLength); err != nil {
+		h.rejectUpload(w, "disk", filename, err)
+		return
+	}
+
+	body := newDigestReader(r.Body)
+	verify := func() error {
+		got := map[string][]byte{"md5": body.md5.Sum(nil), "sha-256": body.sha256.Sum(nil)}
+		for algo, sum := range want {
+			if !bytes.Equal(sum, got[algo]) {
+				return fmt.Errorf("%s digest mismatch: body does not match the header", algo)
+			}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, "Error creating the folder on server", http.StatusInternalServerError)
+		return
+	}
+	if reason, err := h.saveUpload(dir, filename, body, verify); err != nil {
+		removeEmptyDirs(dir, existing)
+		if reason == "" {
+			http.Error(w, "Error saving the file", http.StatusInternalServerError)
+			return
+		}
+		h.rejectUpload(w, reason, filename, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(PutResult{
+		Path:    r.URL.Path,
+		Size:    body.n,
+		SHA256:  hex.EncodeToString(body.sha256.Sum(nil)),
+		MD5:     hex.EncodeToString(body.md5.Sum(nil)),
+		Created: created,
+	})
+}
+
+// existingParent returns dir or its closest ancestor that exists
+func existingParent(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// removeEmptyDirs undoes the folders a failed upload created, from dir up to
+// but not including stop
+func removeEmptyDirs(dir string, stop string) {
+	for dir != stop && filepath.Dir(dir) != dir {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// rangeIdle is how long the parts of an unfinished download are kept
+const rangeIdle = time.Hour
+
+// rangeTracker joins the parts of a download that a client fetched over
+// several requests, as browsers and download managers do when they resume
+type rangeTracker struct {
+	mu    sync.Mutex
+	parts map[string]*rangeParts // By client and path
+}
+
+// rangeParts are the bytes of one file a client received so far
+type rangeParts struct {
+	total int64
+	spans [][2]int64 // Received [start, end) spans, sorted and merged
+	sent  int64      // Bytes sent over all parts
+	seen  time.Time
+}
+
+// add records that bytes [start, end) of a file of total bytes went out to
+// the client of key. It reports whether the client now has every byte, and
+// the bytes sent for it over all parts.
+func (t *rangeTracker) add(key string, start, end, total int64) (bool, int64) {
+	if end <= start || total <= 0 {
+		return false, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.parts == nil {
+		t.parts = make(map[string]*rangeParts)
+	}
+	for k, p := range t.parts {
+		if now.Sub(p.seen) > rangeIdle {
+			delete(t.parts, k)
+		}
+	}
+	p := t.parts[key]
+	if p == nil || p.total != total {
+		// A new download, or the file changed size since the last part
+		p = &rangeParts{total: total}
+		t.parts[key] = p
+	}
+	p.sent += end - start
+	p.seen = now
+	p.spans = mergeSpans(append(p.spans, [2]int64{start, end}))
+
+	if len(p.spans) == 1 && p.spans[0][0] == 0 && p.spans[0][1] >= total {
+		delete(t.parts, key)
+		return true, p.sent
+	}
+	return false, 0
+}
+
+// forget drops the parts of key, once its download completed in one go
+func (t *rangeTracker) forget(key string) {
+	t.mu.Lock()
+	delete(t.parts, key)
+	t.mu.Unlock()
+}
+
+// mergeSpans sorts spans and joins the ones that overlap or touch
+func mergeSpans(spans [][2]int64) [][2]int64 {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] > last[1] {
+			merged = append(merged, s)
+		} else if s[1] > last[1] {
+			last[1] = s[1]
+		}
+	}
+	return merged
+}
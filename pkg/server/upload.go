@@ -0,0 +1,277 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"JustServe/pkg/events"
+)
+
+// UploadLimits restricts what visitors may upload to a share.
+// Zero values mean "no limit".
+type UploadLimits struct {
+	MaxFileSize  int64    `json:"maxFileSize"`  // Max bytes per uploaded file
+	Quota        int64    `json:"quota"`        // Max total bytes uploaded during the share
+	AllowedExts  []string `json:"allowedExts"`  // If set, only these extensions are accepted
+	BlockedExts  []string `json:"blockedExts"`  // Extensions that are always refused
+	MinFreeSpace int64    `json:"minFreeSpace"` // Refuse uploads when the volume has less free space than this
+}
+
+// UploadRejection describes why an upload was refused.
+// It is sent to the UI with the "upload-rejected" event.
+type UploadRejection struct {
+	Version   int    `json:"version"` // events.PayloadVersion
+	Reason    string `json:"reason"`  // "size" | "quota" | "type" | "disk" | "digest"
+	FileName  string `json:"fileName"`
+	Message   string `json:"message"`
+	SessionID string `json:"sessionId,omitempty"` // Set by the session that runs the handler
+}
+
+// How often the free-space guard is re-checked while a file is being written
+const freeSpaceCheckInterval = 64 << 20
+
+var (
+	errFileTooLarge  = errors.New("file exceeds the maximum upload size")
+	errQuotaExceeded = errors.New("upload quota for this share is exhausted")
+	errDiskFull      = errors.New("not enough free disk space on the server")
+)
+
+// normalizeExt lowercases an extension and makes sure it has a leading dot
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// allowsName reports whether the file name passes the extension lists
+func (l UploadLimits) allowsName(name string) bool {
+	lower := strings.ToLower(name)
+	hasExt := func(list []string) bool {
+		for _, ext := range list {
+			// Match on suffix so multi-part extensions like ".tar.gz" work
+			if e := normalizeExt(ext); e != "" && strings.HasSuffix(lower, e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasExt(l.BlockedExts) {
+		return false
+	}
+	if len(l.AllowedExts) > 0 {
+		return hasExt(l.AllowedExts)
+	}
+	return true
+}
+
+// checkFreeSpace refuses the upload if writing `incoming` more bytes into dir
+// would leave less than MinFreeSpace on the volume.
+func (l UploadLimits) checkFreeSpace(dir string, incoming int64) error {
+	if l.MinFreeSpace <= 0 {
+		return nil
+	}
+	free, err := freeSpace(dir)
+	if err != nil {
+		// Unknown free space (unsupported platform) - don't block uploads
+		return nil
+	}
+	if incoming < 0 {
+		incoming = 0
+	}
+	if int64(free)-incoming < l.MinFreeSpace {
+		return errDiskFull
+	}
+	return nil
+}
+
+// limitedWriter enforces the per-file size, share quota and free-space guard
+// while an upload is streamed to disk.
+type limitedWriter struct {
+	dst       io.Writer
+	dir       string
+	limits    UploadLimits
+	usage     *int64 // Shared counter of bytes uploaded to the share
+	written   int64
+	nextCheck int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	n := int64(len(p))
+	if lw.limits.MaxFileSize > 0 && lw.written+n > lw.limits.MaxFileSize {
+		return 0, errFileTooLarge
+	}
+	// Reserve the bytes against the quota before writing so concurrent
+	// uploads cannot overshoot it together.
+	if total := atomic.AddInt64(lw.usage, n); lw.limits.Quota > 0 && total > lw.limits.Quota {
+		atomic.AddInt64(lw.usage, -n)
+		return 0, errQuotaExceeded
+	}
+	if lw.written >= lw.nextCheck {
+		lw.nextCheck = lw.written + freeSpaceCheckInterval
+		if err := lw.limits.checkFreeSpace(lw.dir, n); err != nil {
+			atomic.AddInt64(lw.usage, -n)
+			return 0, err
+		}
+	}
+
+	written, err := lw.dst.Write(p)
+	lw.written += int64(written)
+	if int64(written) < n {
+		atomic.AddInt64(lw.usage, int64(written)-n)
+	}
+	return written, err
+}
+
+// release gives the bytes of a failed upload back to the quota
+func (lw *limitedWriter) release() {
+	atomic.AddInt64(lw.usage, -lw.written)
+}
+
+// handleUpload stores the files of a multipart POST into dir
+func (h *FileHandler) handleUpload(w http.ResponseWriter, r *http.Request, dir string) {
+	// Fast checks on the declared request size before reading the body
+	if err := h.limits.checkFreeSpace(dir, r.ContentLength); err != nil {
+		h.rejectUpload(w, "disk", "", err)
+		return
+	}
+	if h.limits.Quota > 0 && r.ContentLength > 0 &&
+		atomic.LoadInt64(&h.uploaded)+r.ContentLength > h.limits.Quota+multipartOverhead {
+		h.rejectUpload(w, "quota", "", errQuotaExceeded)
+		return
+	}
+
+	// Stream the multipart body instead of buffering it with ParseMultipartForm
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	received := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Error parsing form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		// Never trust client-provided paths
+		filename := filepath.Base(filepath.Clean(part.FileName()))
+		if !h.limits.allowsName(filename) {
+			part.Close()
+			h.rejectUpload(w, "type", filename, fmt.Errorf("file type of %q is not allowed", filename))
+			return
+		}
+
+		if reason, err := h.saveUpload(dir, filename, part, nil); err != nil {
+			part.Close()
+			if reason == "" {
+				http.Error(w, "Error saving the file", http.StatusInternalServerError)
+				return
+			}
+			h.rejectUpload(w, reason, filename, err)
+			return
+		}
+		part.Close()
+		received = true
+	}
+
+	if !received {
+		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+		return
+	}
+
+	// Redirect back to root or success page
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+// Slack allowed for multipart boundaries and headers when comparing
+// Content-Length against the remaining quota
+const multipartOverhead = 64 << 10
+
+// saveUpload writes src to dir/filename through a temporary file so a rejected
+// or interrupted upload never leaves a partial file behind. If verify is set it
+// runs before the file is moved into place. A non-empty reason means the upload
+// was refused by one of the limits or by verify.
+func (h *FileHandler) saveUpload(dir string, filename string, src io.Reader, verify func() error) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".justserve-upload-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	lw := &limitedWriter{dst: tmp, dir: dir, limits: h.limits, usage: &h.uploaded}
+	_, err = io.Copy(lw, src)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && verify != nil {
+		if err = verify(); err != nil {
+			err = &verifyError{err}
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, filepath.Join(dir, filename))
+	}
+	if err != nil {
+		lw.release()
+		os.Remove(tmpPath)
+		switch {
+		case errors.Is(err, errFileTooLarge):
+			return "size", err
+		case errors.Is(err, errQuotaExceeded):
+			return "quota", err
+		case errors.Is(err, errDiskFull):
+			return "disk", err
+		}
+		var vErr *verifyError
+		if errors.As(err, &vErr) {
+			return "digest", vErr.err
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// verifyError marks a failed integrity check of an uploaded file
+type verifyError struct{ err error }
+
+func (e *verifyError) Error() string { return e.err.Error() }
+
+// rejectUpload answers with a descriptive HTTP error and notifies the UI
+func (h *FileHandler) rejectUpload(w http.ResponseWriter, reason string, filename string, err error) {
+	status := http.StatusRequestEntityTooLarge
+	switch reason {
+	case "type":
+		status = http.StatusUnsupportedMediaType
+	case "digest":
+		status = http.StatusBadRequest
+	case "disk":
+		status = http.StatusInsufficientStorage
+	}
+
+	h.events.Emit("upload-rejected", UploadRejection{
+		Version:  events.PayloadVersion,
+		Reason:   reason,
+		FileName: filename,
+		Message:  err.Error(),
+	})
+	http.Error(w, "Upload rejected: "+err.Error(), status)
+}
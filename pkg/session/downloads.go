@@ -0,0 +1,166 @@
+package session
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"JustServe/pkg/events"
+	"JustServe/pkg/server"
+)
+
+// FileStats are the completed downloads of one path of a share. A folder
+// downloaded as a zip is counted under its own path, ending in "/".
+type FileStats struct {
+	Path       string    `json:"path"`
+	Downloads  int64     `json:"downloads"`
+	Clients    int       `json:"clients"`    // Distinct client IPs
+	Bytes      int64     `json:"bytes"`      // Sent by these downloads
+	LastAccess time.Time `json:"lastAccess"` // When the latest one completed
+}
+
+// fileStats is FileStats with the clients seen so far
+type fileStats struct {
+	FileStats
+	clients map[string]struct{}
+}
+
+// DownloadReport is what a share writes to ShareConfig.StatsFile in JSON
+type DownloadReport struct {
+	SessionID string      `json:"sessionId"`
+	Name      string      `json:"name"`
+	StartedAt time.Time   `json:"startedAt"`
+	StoppedAt time.Time   `json:"stoppedAt"`
+	Files     []FileStats `json:"files"`
+}
+
+// StatsExported is the payload of "stats-exported": a share that stopped
+// wrote its download stats to ShareConfig.StatsFile. A failed write is sent
+// as "stats-export-error" (events.Error) instead.
+type StatsExported struct {
+	Version   int    `json:"version"` // events.PayloadVersion
+	SessionID string `json:"sessionId"`
+	File      string `json:"file"`
+	Paths     int    `json:"paths"` // Rows written
+}
+
+// countDownload adds a completed download of r.URL.Path to the stats of s
+func (s *Session) countDownload(r *http.Request, size int64) {
+	ip := server.ClientIP(r, s.public)
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]*fileStats)
+	}
+	f := s.files[r.URL.Path]
+	if f == nil {
+		f = &fileStats{FileStats: FileStats{Path: r.URL.Path}, clients: make(map[string]struct{})}
+		s.files[r.URL.Path] = f
+	}
+	f.clients[ip] = struct{}{}
+	f.Downloads++
+	f.Bytes += size
+	f.Clients = len(f.clients)
+	f.LastAccess = time.Now()
+}
+
+// Downloads returns the download stats of s by path, most downloaded first
+func (s *Session) Downloads() []FileStats {
+	s.filesMu.Lock()
+	list := make([]FileStats, 0, len(s.files))
+	for _, f := range s.files {
+		list = append(list, f.FileStats)
+	}
+	s.filesMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Downloads != list[j].Downloads {
+			return list[i].Downloads > list[j].Downloads
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// Downloads returns the download stats of a running share by path
+func (m *Manager) Downloads(id string) ([]FileStats, error) {
+	s, ok := m.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w with id %q", ErrNoSession, id)
+	}
+	return s.Downloads(), nil
+}
+
+// exportStats writes the download stats of a stopped share to its stats
+// file, if it has one
+func (m *Manager) exportStats(s *Session) {
+	if s.statsFile == "" {
+		return
+	}
+	report := DownloadReport{
+		SessionID: s.id,
+		Name:      s.name,
+		StartedAt: s.startedAt,
+		StoppedAt: time.Now(),
+		Files:     s.Downloads(),
+	}
+	if err := writeStatsFile(s.statsFile, report); err != nil {
+		payload := events.NewError(fmt.Errorf("failed to save download stats: %w", err))
+		payload.SessionID = s.id
+		m.events.Emit("stats-export-error", payload)
+		return
+	}
+	m.events.Emit("stats-exported", StatsExported{
+		Version:   events.PayloadVersion,
+		SessionID: s.id,
+		File:      s.statsFile,
+		Paths:     len(report.Files),
+	})
+}
+
+// writeStatsFile writes report as CSV when path ends in .csv, else as JSON
+func writeStatsFile(path string, report DownloadReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeStatsCSV(f, report.Files)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeStatsCSV writes one row per path under a header row
+func writeStatsCSV(out io.Writer, files []FileStats) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"path", "downloads", "clients", "bytes", "last_access"})
+	for _, fs := range files {
+		w.Write([]string{
+			fs.Path,
+			strconv.FormatInt(fs.Downloads, 10),
+			strconv.Itoa(fs.Clients),
+			strconv.FormatInt(fs.Bytes, 10),
+			fs.LastAccess.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
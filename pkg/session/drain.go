@@ -0,0 +1,221 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"JustServe/pkg/events"
+)
+
+// DefaultDrainTimeout is how long a draining session waits for its transfers
+// when no timeout is given
+const DefaultDrainTimeout = 30 * time.Second
+
+// drainPoll is how often a draining session checks for finished transfers
+const drainPoll = 200 * time.Millisecond
+
+// Transfer is a request or proxied TCP connection in progress
+type Transfer struct {
+	Method    string    `json:"method,omitempty"` // Empty for TCP connections
+	Path      string    `json:"path,omitempty"`
+	Remote    string    `json:"remote"`
+	StartedAt time.Time `json:"startedAt"`
+	Bytes     int64     `json:"bytes"`          // Sent so far, or received for uploads
+	Size      int64     `json:"size,omitempty"` // Expected total, when known
+}
+
+// Draining is the payload of "session-draining": the session refuses new
+// requests and stops once its transfers are done or at Deadline
+type Draining struct {
+	Version  int       `json:"version"` // events.PayloadVersion
+	Session  Info      `json:"session"`
+	Deadline time.Time `json:"deadline"` // Transfers still running then are cut off
+}
+
+// transfer tracks one Transfer; bytes and size are updated atomically
+type transfer struct {
+	method, path, remote string
+	started              time.Time
+	upload               bool
+	bytes, size          int64
+	conn                 net.Conn // TCP connections only, closed on stop
+}
+
+// track registers a transfer in progress until the returned function is called
+func (s *Session) track(t *transfer) (untrack func()) {
+	t.started = time.Now()
+	s.transfersMu.Lock()
+	s.transfers[t] = struct{}{}
+	s.transfersMu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.transfersMu.Lock()
+			delete(s.transfers, t)
+			s.transfersMu.Unlock()
+		})
+	}
+}
+
+// inFlight lists the transfers in progress, oldest first
+func (s *Session) inFlight() []Transfer {
+	s.transfersMu.Lock()
+	list := make([]Transfer, 0, len(s.transfers))
+	for t := range s.transfers {
+		list = append(list, Transfer{
+			Method:    t.method,
+			Path:      t.path,
+			Remote:    t.remote,
+			StartedAt: t.started,
+			Bytes:     atomic.LoadInt64(&t.bytes),
+			Size:      atomic.LoadInt64(&t.size),
+		})
+	}
+	s.transfersMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// busy reports whether transfers are still in progress
+func (s *Session) busy() bool {
+	s.transfersMu.Lock()
+	defer s.transfersMu.Unlock()
+	return len(s.transfers) > 0
+}
+
+// closeConns cuts off the proxied TCP connections still open
+func (s *Session) closeConns() {
+	s.transfersMu.Lock()
+	var conns []net.Conn
+	for t := range s.transfers {
+		if t.conn != nil {
+			conns = append(conns, t.conn)
+		}
+	}
+	s.transfersMu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+func (s *Session) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) != 0
+}
+
+// refuseDraining answers a request to a draining session with 503 and closes
+// its connection. It reports whether the request was refused.
+func (s *Session) refuseDraining(w http.ResponseWriter) bool {
+	if !s.isDraining() {
+		return false
+	}
+	w.Header().Set("Connection", "close")
+	w.Header().Set("Retry-After", "30")
+	http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+	return true
+}
+
+// countingBody counts the bytes of an upload as the handler reads them
+type countingBody struct {
+	io.ReadCloser
+	t *transfer
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.t.bytes, int64(n))
+	return n, err
+}
+
+// requestTransfer starts tracking r; uploads count the request body, other
+// requests the response
+func requestTransfer(r *http.Request) *transfer {
+	t := &transfer{method: r.Method, path: r.URL.Path, remote: r.RemoteAddr}
+	if (r.Method == http.MethodPost || r.Method == http.MethodPut) && r.ContentLength != 0 {
+		t.upload = true
+		if r.ContentLength > 0 {
+			t.size = r.ContentLength
+		}
+		r.Body = &countingBody{ReadCloser: r.Body, t: t}
+	}
+	return t
+}
+
+// responseSize records the Content-Length of a download once its header is written
+func (t *transfer) responseSize(h http.Header) {
+	if t.upload {
+		return
+	}
+	if n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		atomic.StoreInt64(&t.size, n)
+	}
+}
+
+// Drain stops a session gracefully: it refuses new requests and connections,
+// withdraws its LAN advertisement and emits "session-draining", then stops
+// once its transfers are done or timeout has passed (DefaultDrainTimeout if
+// 0). Drain returns at once; Session.Done tells when the session stopped.
+// Stop cuts a draining session off early.
+func (m *Manager) Drain(id string, timeout time.Duration) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return fmt.Errorf("%w with id %q", ErrNoSession, id)
+	}
+	m.drain(s, timeout)
+	return nil
+}
+
+// DrainAll drains every running session and returns once all have stopped
+func (m *Manager) DrainAll(timeout time.Duration) {
+	list := m.snapshot()
+	for _, s := range list {
+		m.drain(s, timeout)
+	}
+	for _, s := range list {
+		<-s.done
+	}
+}
+
+func (m *Manager) drain(s *Session, timeout time.Duration) {
+	if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		return // Already draining
+	}
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	m.mu.Lock()
+	if s.withdraw != nil {
+		s.withdraw()
+		s.withdraw = nil
+	}
+	m.mu.Unlock()
+	if s.refuse != nil {
+		s.refuse()
+	}
+
+	deadline := time.Now().Add(timeout)
+	m.events.Emit("session-draining", Draining{Version: events.PayloadVersion, Session: s.Info(), Deadline: deadline})
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		tick := time.NewTicker(drainPoll)
+		defer tick.Stop()
+		for s.busy() {
+			select {
+			case <-tick.C:
+			case <-timer.C:
+				m.finish(s)
+				return
+			case <-s.done:
+				return
+			}
+		}
+		m.finish(s)
+	}()
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+)
+
+// Reasons a share expired, see Expired
+const (
+	ExpiredTime      = "time"      // ShareConfig.ExpiresAt has passed
+	ExpiredDownloads = "downloads" // ShareConfig.MaxDownloads downloads completed
+)
+
+// ErrExpired is returned when a share is started after its expiry time
+var ErrExpired = errcode.New(errcode.Expired, "the expiry time has already passed")
+
+// Expired is the payload of "session-expired": a share reached its expiry
+// time or download limit. It drains and stops right after.
+type Expired struct {
+	Version int    `json:"version"` // events.PayloadVersion
+	Session Info   `json:"session"`
+	Reason  string `json:"reason"` // ExpiredTime or ExpiredDownloads
+}
+
+// limit counts the downloads of s (see Downloads) and applies the limits of
+// cfg; the expiry timer starts once s is registered (see watchExpiry)
+func (m *Manager) limit(s *Session, cfg ShareConfig) {
+	s.expiresAt, s.maxDownloads = cfg.ExpiresAt, int64(cfg.MaxDownloads)
+	s.handler.SetExpiry(cfg.ExpiresAt)
+	s.handler.SetDownloadHook(func(r *http.Request, size int64) {
+		s.countDownload(r, size)
+		n := atomic.AddInt64(&s.downloads, 1)
+		if s.maxDownloads > 0 && n >= s.maxDownloads {
+			m.expire(s, ExpiredDownloads)
+		}
+	}, cfg.Public)
+}
+
+// watchExpiry expires s at its expiry time unless it stopped before
+func (m *Manager) watchExpiry(s *Session) {
+	if s.expiresAt.IsZero() {
+		return
+	}
+	go func() {
+		timer := time.NewTimer(time.Until(s.expiresAt))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			m.expire(s, ExpiredTime)
+		case <-s.done:
+		}
+	}()
+}
+
+// expire announces that s reached a limit and drains it, so downloads in
+// progress may still finish
+func (m *Manager) expire(s *Session, reason string) {
+	if !atomic.CompareAndSwapInt32(&s.expired, 0, 1) {
+		return
+	}
+	m.events.Emit("session-expired", Expired{Version: events.PayloadVersion, Session: s.Info(), Reason: reason})
+	m.drain(s, DefaultDrainTimeout)
+}
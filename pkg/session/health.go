@@ -0,0 +1,71 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HealthPath is answered by every share, before the password check, so load
+// balancers and uptime monitors can probe it. It sits under a dot folder so
+// it doesn't hide a shared file named healthz.
+const HealthPath = "/.justserve/healthz"
+
+// Health is the body of a HealthPath response. Public shares send the
+// status alone, see PublicHealth.
+type Health struct {
+	Status  string `json:"status"` // "ok", or "unavailable" or "draining" with status 503
+	Session string `json:"session"`
+	Uptime  int64  `json:"uptime"`          // Seconds since the share started
+	Error   string `json:"error,omitempty"` // Why the share is unavailable
+}
+
+// PublicHealth is the body of a HealthPath response of a public share, which
+// anyone on the internet can read
+type PublicHealth struct {
+	Status string `json:"status"` // As in Health
+}
+
+// sharedPaths lists the files and folders a share serves
+func (cfg ShareConfig) sharedPaths() []string {
+	if len(cfg.Mounts) == 0 {
+		return []string{cfg.Path}
+	}
+	paths := make([]string, len(cfg.Mounts))
+	for i, mount := range cfg.Mounts {
+		paths[i] = mount.Path
+	}
+	return paths
+}
+
+// health answers HealthPath for s and passes other requests to next. The
+// share is unavailable once one of paths is gone, e.g. an unmounted drive.
+func (s *Session) health(paths []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != HealthPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := Health{Status: "ok", Session: s.id, Uptime: int64(time.Since(s.startedAt).Seconds())}
+		code := http.StatusOK
+		if s.isDraining() {
+			h.Status, code = "draining", http.StatusServiceUnavailable
+		}
+		for _, p := range paths {
+			if _, err := os.Stat(p); err != nil {
+				h.Status, h.Error = "unavailable", "shared path is not accessible"
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		if s.public {
+			json.NewEncoder(w).Encode(PublicHealth{Status: h.Status})
+			return
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
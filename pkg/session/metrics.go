@@ -0,0 +1,77 @@
+package session
+
+import (
+	"sort"
+	"strconv"
+
+	"JustServe/pkg/metrics"
+)
+
+// WriteMetrics writes the counters of the running sessions, labelled with
+// the session ID; it makes the Manager a metrics.Source
+func (m *Manager) WriteMetrics(w *metrics.Writer) {
+	list := m.List()
+
+	w.Family("justserve_sessions", "gauge", "Running sessions by kind.")
+	counts := map[Kind]int{KindShare: 0, KindProxy: 0}
+	for _, info := range list {
+		counts[info.Kind]++
+	}
+	w.Sample(float64(counts[KindShare]), "kind", string(KindShare))
+	w.Sample(float64(counts[KindProxy]), "kind", string(KindProxy))
+
+	w.Family("justserve_session_info", "gauge", "Running sessions, always 1.")
+	for _, info := range list {
+		w.Sample(1, "session", info.ID, "kind", string(info.Kind), "name", info.Name, "public", strconv.FormatBool(info.Public))
+	}
+
+	w.Family("justserve_http_requests_total", "counter", "HTTP requests served, by status code.")
+	for _, info := range list {
+		codes := make([]int, 0, len(info.Stats.Responses))
+		for code := range info.Stats.Responses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			w.Sample(float64(info.Stats.Responses[code]), "session", info.ID, "code", strconv.Itoa(code))
+		}
+	}
+
+	w.Family("justserve_sent_bytes_total", "counter", "Bytes sent to clients.")
+	for _, info := range list {
+		w.Sample(float64(info.Stats.BytesSent), "session", info.ID)
+	}
+
+	w.Family("justserve_active_connections", "gauge", "Client connections open now.")
+	for _, info := range list {
+		w.Sample(float64(info.Stats.ActiveConnections), "session", info.ID)
+	}
+
+	w.Family("justserve_upload_bytes_total", "counter", "Bytes of uploads stored by a share.")
+	for _, info := range list {
+		if info.Kind == KindShare {
+			w.Sample(float64(info.Stats.BytesUploaded), "session", info.ID)
+		}
+	}
+
+	w.Family("justserve_downloads_total", "counter", "Downloads a share sent in full.")
+	for _, info := range list {
+		if info.Kind == KindShare {
+			w.Sample(float64(info.Stats.Downloads), "session", info.ID)
+		}
+	}
+
+	w.Family("justserve_zip_streams_total", "counter", "Folders and archives a share streamed as zip.")
+	for _, info := range list {
+		if info.Kind == KindShare {
+			w.Sample(float64(info.Stats.ZipStreams), "session", info.ID)
+		}
+	}
+
+	w.Family("justserve_proxy_connections_total", "counter", "Connections accepted by a TCP proxy.")
+	for _, info := range list {
+		if info.Kind == KindProxy {
+			w.Sample(float64(info.Stats.Connections), "session", info.ID)
+		}
+	}
+}
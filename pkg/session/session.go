@@ -0,0 +1,386 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/events"
+	"JustServe/pkg/server"
+	"JustServe/pkg/utils"
+)
+
+// Kind identifies what a session is running
+type Kind string
+
+const (
+	KindShare Kind = "share" // File share (local or public)
+	KindProxy Kind = "proxy" // HTTP (ngrok) or TCP port proxy
+)
+
+// Stats are live counters of a session
+type Stats struct {
+	Requests          int64         `json:"requests"`            // HTTP requests handled
+	Responses         map[int]int64 `json:"responses,omitempty"` // HTTP requests by status code
+	BytesSent         int64         `json:"bytesSent"`           // Bytes sent to clients
+	BytesUploaded     int64         `json:"bytesUploaded"`       // Bytes of uploads stored by a share
+	ZipStreams        int64         `json:"zipStreams"`          // Zip downloads streamed by a share
+	Connections       int64         `json:"connections"`         // Proxy connections accepted
+	ActiveConnections int64         `json:"activeConnections"`   // Client connections open now
+	Downloads         int64         `json:"downloads"`           // Downloads a share sent in full
+}
+
+// Info is a snapshot of a running session
+type Info struct {
+	ID        string     `json:"id"`
+	Kind      Kind       `json:"kind"`
+	Name      string     `json:"name"` // Shared path or proxied port
+	Public    bool       `json:"public"`
+	URLs      []string   `json:"urls"`
+	Port      int        `json:"port,omitempty"` // Listening port of a local share
+	StartedAt time.Time  `json:"startedAt"`
+	Stats     Stats      `json:"stats"`
+	Draining  bool       `json:"draining,omitempty"`  // Refusing new requests until Transfers are done
+	Transfers []Transfer `json:"transfers,omitempty"` // Requests and proxied connections in progress
+
+	ExpiresAt    time.Time `json:"expiresAt,omitzero"`     // A share stops at this time, if set
+	MaxDownloads int64     `json:"maxDownloads,omitempty"` // A share stops after this many downloads, if set
+}
+
+// Session is a running share or proxy owned by a Manager
+type Session struct {
+	id        string
+	kind      Kind
+	name      string
+	public    bool
+	urls      []string
+	startedAt time.Time
+
+	requests    int64
+	bytesSent   int64
+	connections int64
+	active      int64               // Open client connections
+	handler     *server.FileHandler // Shares only; keeps the upload and zip counters
+	conns       *server.ConnTracker // Live client connections, for listing and killing them
+	downloads   int64               // Completed downloads of a share
+
+	// Limits of a share, see ShareConfig.ExpiresAt
+	expiresAt    time.Time
+	maxDownloads int64
+	expired      int32 // Set once a limit was reached
+
+	// Completed downloads of a share by path, see Downloads
+	filesMu   sync.Mutex
+	files     map[string]*fileStats
+	statsFile string // Written when the share stops, see ShareConfig.StatsFile
+
+	responsesMu sync.Mutex
+	responses   map[int]int64 // Requests by status code
+
+	// Local shares are advertised on the LAN while an Advertiser is set
+	title    string // Short name for the advertisement
+	port     int    // Listening port of local shares, 0 for sessions that are not advertised
+	withdraw func() // Guarded by Manager.mu
+
+	// Draining refuses new work and waits for the transfers in progress
+	draining    int32  // Set once by Manager.Drain
+	refuse      func() // Stops accepting connections when draining starts, if set
+	transfersMu sync.Mutex
+	transfers   map[*transfer]struct{}
+
+	stopOnce sync.Once
+	stop     func() // Closes the session immediately
+	done     chan struct{}
+}
+
+// ID returns the session identifier
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Done is closed once the session has stopped
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Info returns a snapshot of the session
+func (s *Session) Info() Info {
+	return Info{
+		ID:        s.id,
+		Kind:      s.kind,
+		Name:      s.name,
+		Public:    s.public,
+		URLs:      append([]string(nil), s.urls...),
+		Port:      s.port,
+		StartedAt: s.startedAt,
+		Stats:     s.stats(),
+		Draining:  s.isDraining(),
+		Transfers: s.inFlight(),
+
+		ExpiresAt:    s.expiresAt,
+		MaxDownloads: s.maxDownloads,
+	}
+}
+
+func (s *Session) stats() Stats {
+	st := Stats{
+		Requests:          atomic.LoadInt64(&s.requests),
+		BytesSent:         atomic.LoadInt64(&s.bytesSent),
+		Connections:       atomic.LoadInt64(&s.connections),
+		ActiveConnections: atomic.LoadInt64(&s.active),
+		Downloads:         atomic.LoadInt64(&s.downloads),
+	}
+	if s.handler != nil {
+		c := s.handler.Counters()
+		st.BytesUploaded, st.ZipStreams = c.UploadedBytes, c.ZipStreams
+	}
+	s.responsesMu.Lock()
+	if len(s.responses) > 0 {
+		st.Responses = make(map[int]int64, len(s.responses))
+		for code, n := range s.responses {
+			st.Responses[code] = n
+		}
+	}
+	s.responsesMu.Unlock()
+	return st
+}
+
+// ErrNoSession is returned for an unknown session ID
+var ErrNoSession = errcode.New(errcode.SessionNotFound, "no running session")
+
+// Advertiser announces local shares on the network, e.g. over mDNS
+type Advertiser interface {
+	// Advertise publishes a share listening on port and returns a function
+	// that withdraws it. It is called with the Manager locked and must not
+	// call back into it.
+	Advertise(name string, port int) (withdraw func())
+}
+
+// Manager keeps track of every running session
+type Manager struct {
+	mu         sync.Mutex
+	sessions   map[string]*Session
+	events     events.Sink
+	accessLog  io.Writer
+	advertiser Advertiser
+}
+
+// Event is the payload of "session-started" and "session-stopped"
+type Event struct {
+	Version int  `json:"version"` // events.PayloadVersion
+	Session Info `json:"session"`
+}
+
+// PortFallback is the payload of "port-fallback": a local share found its
+// port busy and listens on a following one
+type PortFallback struct {
+	Version   int              `json:"version"` // events.PayloadVersion
+	SessionID string           `json:"sessionId"`
+	Requested int              `json:"requested"`
+	Port      int              `json:"port"`
+	Owner     *utils.PortOwner `json:"owner,omitempty"` // Process holding Requested, when known
+}
+
+// NewManager creates an empty session registry that reports to sink (nil
+// discards events): "session-started", "session-stopped" (Event),
+// "session-draining" (Draining), "session-expired" (Expired),
+// "stats-exported" (StatsExported), "server-error" and
+// "stats-export-error" (events.Error),
+// "port-fallback" (PortFallback), and the events of the components a
+// session runs such as "upload-rejected" and "tunnel-disconnected".
+// Payloads of component events are tagged with the session ID.
+func NewManager(sink events.Sink) *Manager {
+	return &Manager{sessions: make(map[string]*Session), events: events.OrDiscard(sink)}
+}
+
+// SetAccessLog writes one line per HTTP request served by any session to w
+// (nil disables it). Used by the headless CLI to log to stdout.
+func (m *Manager) SetAccessLog(w io.Writer) {
+	m.mu.Lock()
+	m.accessLog = w
+	m.mu.Unlock()
+}
+
+// SetAdvertiser advertises local shares through a (nil stops advertising).
+// Running shares are moved to the new advertiser.
+func (m *Manager) SetAdvertiser(a Advertiser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.advertiser = a
+	for _, s := range m.sessions {
+		if s.port == 0 || s.isDraining() {
+			continue
+		}
+		if s.withdraw != nil {
+			s.withdraw()
+			s.withdraw = nil
+		}
+		if a != nil {
+			s.withdraw = a.Advertise(s.title, s.port)
+		}
+	}
+}
+
+// advertise publishes s if it is still running and an advertiser is set
+func (m *Manager) advertise(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.advertiser != nil && m.sessions[s.id] == s && s.port > 0 && !s.isDraining() {
+		s.withdraw = m.advertiser.Advertise(s.title, s.port)
+	}
+}
+
+func (m *Manager) accessLogWriter() io.Writer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.accessLog
+}
+
+// newSession allocates a session that is not yet registered
+func newSession(kind Kind, name string) *Session {
+	return &Session{
+		id:        newID(),
+		kind:      kind,
+		name:      name,
+		startedAt: time.Now(),
+		transfers: make(map[*transfer]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// register adds a fully started session and announces it
+func (m *Manager) register(s *Session) {
+	m.mu.Lock()
+	m.sessions[s.id] = s
+	m.mu.Unlock()
+	m.events.Emit("session-started", Event{Version: events.PayloadVersion, Session: s.Info()})
+	m.watchExpiry(s)
+}
+
+// finish tears a session down once and announces it. It is used both for
+// explicit stops and for sessions whose server died on its own.
+func (m *Manager) finish(s *Session) {
+	s.stopOnce.Do(func() {
+		m.mu.Lock()
+		delete(m.sessions, s.id)
+		if s.withdraw != nil {
+			s.withdraw()
+			s.withdraw = nil
+		}
+		m.mu.Unlock()
+
+		if s.stop != nil {
+			s.stop()
+		}
+		close(s.done)
+		m.exportStats(s)
+		m.events.Emit("session-stopped", Event{Version: events.PayloadVersion, Session: s.Info()})
+	})
+}
+
+// fail reports a serve error and removes the session
+func (m *Manager) fail(s *Session, err error) {
+	payload := events.NewError(err)
+	payload.SessionID = s.id
+	m.events.Emit("server-error", payload)
+	m.finish(s)
+}
+
+// sessionSink forwards component events, tagging their payloads with the ID of s
+func (m *Manager) sessionSink(s *Session) events.Sink {
+	return events.Func(func(name string, data interface{}) {
+		switch v := data.(type) {
+		case events.Error:
+			v.SessionID = s.id
+			data = v
+		case events.Notice:
+			v.SessionID = s.id
+			data = v
+		case server.UploadRejection:
+			v.SessionID = s.id
+			data = v
+		}
+		m.events.Emit(name, data)
+	})
+}
+
+// List returns all running sessions, oldest first
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	list := make([]Info, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		list = append(list, s.Info())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// Get returns a running session by ID
+func (m *Manager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// Stop stops a single session immediately, cutting off its transfers
+func (m *Manager) Stop(id string) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return fmt.Errorf("%w with id %q", ErrNoSession, id)
+	}
+	m.finish(s)
+	return nil
+}
+
+// Connections lists the live client connections of a session
+func (m *Manager) Connections(id string) ([]server.Conn, error) {
+	s, ok := m.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w with id %q", ErrNoSession, id)
+	}
+	return s.conns.List(), nil
+}
+
+// KillConnection disconnects one client of a session
+func (m *Manager) KillConnection(id string, connID string) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return fmt.Errorf("%w with id %q", ErrNoSession, id)
+	}
+	return s.conns.Kill(connID)
+}
+
+// StopAll stops every running session
+func (m *Manager) StopAll() {
+	for _, s := range m.snapshot() {
+		m.finish(s)
+	}
+}
+
+func (m *Manager) snapshot() []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		list = append(list, s)
+	}
+	return list
+}
+
+// newID returns a short random session identifier
+func newID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
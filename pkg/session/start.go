@@ -0,0 +1,278 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"JustServe/pkg/events"
+	"JustServe/pkg/server"
+	"JustServe/pkg/tunnel"
+	"JustServe/pkg/utils"
+)
+
+// ShareConfig describes a file share session
+type ShareConfig struct {
+	Path        string              `json:"path"`   // Folder or single file to share
+	Mounts      []server.Mount      `json:"mounts"` // Used instead of Path to share several folders
+	Port        string              `json:"port"`   // Local port (ignored for public shares)
+	Password    string              `json:"password,omitempty"`
+	AllowUpload bool                `json:"allowUpload"`
+	Upload      server.UploadLimits `json:"upload"`
+	Public      bool                `json:"public"` // Serve through an ngrok tunnel
+	NgrokToken  string              `json:"-"`
+	Title       string              `json:"title,omitempty"`     // Name advertised on the LAN, default the folder or mount names
+	Interface   string              `json:"interface,omitempty"` // Listen on this network interface only (see utils.Interfaces)
+
+	// PortFallback is how many following ports to try when Port is busy,
+	// 0 to fail instead. The port in use is reported by "port-fallback".
+	PortFallback int `json:"portFallback,omitempty"`
+
+	// PasswordSecret names a vault secret used as Password. The session
+	// package ignores it; callers resolve it before StartShare.
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+
+	// The share stops by itself at ExpiresAt and once MaxDownloads downloads
+	// completed (see Expired); zero values mean no limit. Its pages show the
+	// time left.
+	ExpiresAt    time.Time `json:"expiresAt,omitzero"`
+	MaxDownloads int       `json:"maxDownloads,omitempty"`
+
+	// StatsFile receives the download stats of the share when it stops (see
+	// DownloadReport): CSV when it ends in .csv, JSON otherwise.
+	StatsFile string `json:"statsFile,omitempty"`
+}
+
+// ProxyConfig describes a port proxy session
+type ProxyConfig struct {
+	Port       string `json:"port"`     // Local port to expose
+	Protocol   string `json:"protocol"` // "http" (ngrok) or "tcp" (LAN listener)
+	NgrokToken string `json:"-"`
+}
+
+// StartShare starts a file share session and registers it
+func (m *Manager) StartShare(cfg ShareConfig) (*Session, error) {
+	if !cfg.ExpiresAt.IsZero() && !time.Now().Before(cfg.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	var handler *server.FileHandler
+	name, title := cfg.Path, filepath.Base(cfg.Path)
+	if len(cfg.Mounts) > 0 {
+		h, err := server.NewMountHandler(cfg.Mounts, cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		handler = h
+		names := make([]string, len(cfg.Mounts))
+		titles := make([]string, len(cfg.Mounts))
+		for i, mount := range cfg.Mounts {
+			names[i] = mount.Path
+			titles[i] = mount.Name
+		}
+		name, title = strings.Join(names, ", "), strings.Join(titles, ", ")
+	} else {
+		handler = server.NewFileHandler(cfg.Path, cfg.Password, cfg.AllowUpload)
+	}
+	if cfg.Title != "" {
+		title = cfg.Title
+	}
+	handler.SetUploadLimits(cfg.Upload)
+
+	s := newSession(KindShare, name)
+	handler.SetEventSink(m.sessionSink(s))
+	s.public, s.handler, s.statsFile = cfg.Public, handler, cfg.StatsFile
+	m.limit(s, cfg)
+	s.conns = server.NewConnTracker(cfg.Public)
+	srv := &http.Server{Handler: s.health(cfg.sharedPaths(), m.countRequests(s, s.conns.Wrap(handler)))}
+
+	if cfg.Public {
+		// We use a background context for the tunnel itself so it lives until the session is stopped
+		tun, err := tunnel.StartNgrokTunnel(context.Background(), cfg.NgrokToken, m.sessionSink(s))
+		if err != nil {
+			return nil, fmt.Errorf("failed to start ngrok tunnel: %w", err)
+		}
+		s.urls = []string{tun.URL()}
+		s.stop = func() {
+			srv.Close()
+			tun.Close()
+		}
+		m.serve(s, srv, tun)
+		return s, nil
+	}
+
+	listener, busy, err := listenPort(cfg.Port, "8080", cfg.Interface, cfg.PortFallback)
+	if err != nil {
+		return nil, err
+	}
+	s.urls = localURLs("http", listener)
+	s.title, s.port = title, listener.Addr().(*net.TCPAddr).Port
+	if busy != 0 {
+		m.events.Emit("port-fallback", PortFallback{
+			Version:   events.PayloadVersion,
+			SessionID: s.id,
+			Requested: busy,
+			Port:      s.port,
+			Owner:     utils.FindPortOwner(busy),
+		})
+	}
+	s.stop = func() { srv.Close() }
+	m.serve(s, srv, listener)
+	m.advertise(s)
+	return s, nil
+}
+
+// StartProxy exposes a local port via ngrok (HTTP) or a LAN listener (TCP)
+func (m *Manager) StartProxy(cfg ProxyConfig) (*Session, error) {
+	s := newSession(KindProxy, cfg.Port)
+	s.conns = server.NewConnTracker(cfg.Protocol == "http")
+	target := fmt.Sprintf("http://localhost:%s", cfg.Port)
+
+	if cfg.Protocol == "http" {
+		// Use Ngrok for HTTP (Public URL)
+		srv, err := tunnel.CreateProxyHandler(target)
+		if err != nil {
+			return nil, err
+		}
+		tun, err := tunnel.StartNgrokTunnel(context.Background(), cfg.NgrokToken, m.sessionSink(s))
+		if err != nil {
+			return nil, err
+		}
+		srv.Handler = m.countRequests(s, s.conns.Wrap(srv.Handler))
+		s.public = true
+		s.urls = []string{tun.URL()}
+		s.stop = func() {
+			srv.Close()
+			tun.Close()
+		}
+		m.serve(s, srv, tun)
+		return s, nil
+	}
+
+	// Use local net.Listen for TCP (Sidesteps Ngrok Credit Card requirement)
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start local TCP listener: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.urls = localURLs("tcp", listener)
+	s.refuse = func() {
+		cancel()
+		listener.Close()
+	}
+	s.stop = func() {
+		s.refuse()
+		s.closeConns()
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					continue
+				}
+				m.fail(s, fmt.Errorf("accept error: %w", err))
+				return
+			}
+			go tunnel.HandleTCPConnection(s.countConn(conn), cfg.Port, m.sessionSink(s))
+		}
+	}()
+
+	m.register(s)
+	return s, nil
+}
+
+// serve registers the session and runs srv until it is stopped or fails
+func (m *Manager) serve(s *Session, srv *http.Server, listener net.Listener) {
+	srv.ConnState = s.trackConn
+	srv.ConnContext = s.conns.ConnContext
+	m.register(s)
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			m.fail(s, err)
+		}
+	}()
+}
+
+// listenPort listens on all interfaces, IPv4 and IPv6 alike, or on the
+// address of iface when it is set; an empty port uses def. When the port is
+// busy, up to fallback following ports are tried and busy is the requested
+// port. Errors for a busy port name the process holding it where possible.
+func listenPort(port string, def string, iface string, fallback int) (listener net.Listener, busy int, err error) {
+	if port == "" {
+		port = def
+	}
+	port = strings.TrimPrefix(port, ":")
+	host := ""
+	if iface != "" {
+		if host, err = utils.InterfaceIP(iface); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", iface, err)
+		}
+	}
+	listener, err = net.Listen("tcp", net.JoinHostPort(host, port))
+	if err == nil {
+		return listener, 0, nil
+	}
+	first, convErr := strconv.Atoi(port)
+	if convErr != nil || first == 0 || !errors.Is(err, syscall.EADDRINUSE) {
+		return nil, 0, fmt.Errorf("failed to listen on port :%s: %w", port, err)
+	}
+
+	msg := fmt.Sprintf("failed to listen on port :%d", first)
+	if fallback > 0 {
+		last := first + fallback
+		if last > 65535 {
+			last = 65535
+		}
+		for p := first + 1; p <= last; p++ {
+			l, tryErr := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p)))
+			if tryErr == nil {
+				return l, first, nil
+			}
+			if !errors.Is(tryErr, syscall.EADDRINUSE) {
+				return nil, 0, fmt.Errorf("failed to listen on port :%d: %w", p, tryErr)
+			}
+		}
+		msg = fmt.Sprintf("no free port in %d-%d", first, last)
+	}
+	if owner := utils.FindPortOwner(first); owner != nil {
+		msg += fmt.Sprintf(" (port %d is used by %s)", first, owner)
+	}
+	return nil, 0, fmt.Errorf("%s: %w", msg, err)
+}
+
+// localURLs builds the LAN URLs of a listener: its own address when it is
+// bound to one interface, else one per address family using the preferred
+// local IPs
+func localURLs(scheme string, listener net.Listener) []string {
+	// listener.Addr() ensures we get the real port if ":0" was used
+	addr := listener.Addr().(*net.TCPAddr)
+	port := addr.Port
+	ips := utils.GetPreferredLocalIPs()
+	switch {
+	case !addr.IP.IsUnspecified():
+		ips = []string{addr.IP.String()}
+		if addr.Zone != "" {
+			ips[0] += "%" + addr.Zone
+		}
+	case len(ips) == 0:
+		ips = []string{"localhost"}
+	}
+	urls := make([]string, len(ips))
+	for i, ip := range ips {
+		urls[i] = scheme + "://" + utils.HostPort(ip, port)
+	}
+	return urls
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// countingWriter counts the response bytes of a session
+type countingWriter struct {
+	http.ResponseWriter
+	sent    *int64
+	written int64
+	status  int
+	t       *transfer // Progress of the request, nil when it was refused
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+		if cw.t != nil {
+			cw.t.responseSize(cw.Header())
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+		if cw.t != nil {
+			cw.t.responseSize(cw.Header())
+		}
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.written += int64(n)
+	atomic.AddInt64(cw.sent, int64(n))
+	if cw.t != nil && !cw.t.upload {
+		atomic.AddInt64(&cw.t.bytes, int64(n))
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (Flush etc.)
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// countRequests wraps an HTTP handler so requests and bytes are added to the
+// session stats, and written to the manager's access log if one is set.
+// Requests are tracked as transfers in progress, and refused while the
+// session is draining.
+func (m *Manager) countRequests(s *Session, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.requests, 1)
+		start := time.Now()
+		cw := &countingWriter{ResponseWriter: w, sent: &s.bytesSent}
+		if !s.refuseDraining(cw) {
+			cw.t = requestTransfer(r)
+			defer s.track(cw.t)()
+			next.ServeHTTP(cw, r)
+		}
+		if cw.status == 0 {
+			cw.status = http.StatusOK // Nothing written
+		}
+		s.countResponse(cw.status)
+
+		if out := m.accessLogWriter(); out != nil {
+			fmt.Fprintf(out, "%s [%s] %s %s %s %d %dB %s\n",
+				start.Format("2006-01-02 15:04:05"), s.id, r.RemoteAddr, r.Method, r.URL.RequestURI(),
+				cw.status, cw.written, time.Since(start).Round(time.Millisecond))
+		}
+	})
+}
+
+// countResponse adds a response to the requests by status code
+func (s *Session) countResponse(status int) {
+	s.responsesMu.Lock()
+	if s.responses == nil {
+		s.responses = make(map[int]int64)
+	}
+	s.responses[status]++
+	s.responsesMu.Unlock()
+}
+
+// trackConn keeps the count and list of open HTTP connections; it is set as
+// the http.Server ConnState hook
+func (s *Session) trackConn(c net.Conn, state http.ConnState) {
+	s.conns.ConnState(c, state)
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&s.active, 1)
+	case http.StateHijacked, http.StateClosed:
+		atomic.AddInt64(&s.active, -1)
+	}
+}
+
+// countingConn counts the bytes a TCP proxy sends back to its client
+type countingConn struct {
+	net.Conn
+	sent      *int64
+	active    *int64
+	t         *transfer
+	untrack   func()
+	closeOnce sync.Once
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(c.sent, int64(n))
+	atomic.AddInt64(&c.t.bytes, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Close() error {
+	c.closeOnce.Do(func() {
+		atomic.AddInt64(c.active, -1)
+		c.untrack()
+	})
+	return c.Conn.Close()
+}
+
+// countConn registers a proxied connection with the session stats and its
+// transfers in progress
+func (s *Session) countConn(conn net.Conn) net.Conn {
+	atomic.AddInt64(&s.connections, 1)
+	atomic.AddInt64(&s.active, 1)
+	c := &countingConn{Conn: s.conns.Track(conn), sent: &s.bytesSent, active: &s.active}
+	c.t = &transfer{remote: conn.RemoteAddr().String(), conn: conn}
+	c.untrack = s.track(c.t)
+	return c
+}
@@ -0,0 +1,299 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"JustServe/pkg/server"
+	"JustServe/pkg/session"
+)
+
+// CurrentVersion is the schema version written by this build
+const CurrentVersion = 2
+
+// NgrokTokenSecret is the default vault name of the ngrok token
+const NgrokTokenSecret = "ngrok"
+
+// MaxRecentPaths is how many recently shared paths are remembered
+const MaxRecentPaths = 10
+
+// Settings are the user preferences kept between launches
+type Settings struct {
+	Version     int                 `json:"version"`
+	DefaultPort string              `json:"defaultPort"`
+	AllowUpload bool                `json:"allowUpload"` // Default upload toggle for new shares
+	Upload      server.UploadLimits `json:"upload"`
+	RecentPaths []string            `json:"recentPaths"` // Most recent first
+	Presets     []Preset            `json:"presets"`
+
+	// Secrets live in the vault; settings only name them
+	PasswordSecret   string `json:"passwordSecret"`   // Default share password, empty for none
+	NgrokTokenSecret string `json:"ngrokTokenSecret"` // ngrok auth token
+
+	RestoreLastSession bool                  `json:"restoreLastSession"` // Restart LastSession on launch
+	LastSession        []session.ShareConfig `json:"lastSession"`        // Shares running when the app last quit
+
+	ControlAPI  bool `json:"controlApi"`  // Serve the loopback REST API for scripts
+	ControlPort int  `json:"controlPort"` // Port of the REST API, 0 for any free port
+
+	MDNS         bool `json:"mdns"`         // Advertise local shares over multicast DNS
+	MDNSHostname bool `json:"mdnsHostname"` // Also publish justserve.local
+
+	Metrics     bool `json:"metrics"`     // Serve Prometheus /metrics on loopback
+	MetricsPort int  `json:"metricsPort"` // Port of /metrics, 0 for metrics.DefaultPort
+
+	// StatsExport saves the download stats of each share when it stops, as
+	// "csv" or "json" ("" for off), into StatsDir or Downloads/JustServe
+	StatsExport string `json:"statsExport"`
+	StatsDir    string `json:"statsDir"`
+
+	// LegacySecrets holds cleartext values found while migrating an older
+	// file, keyed by the secret name that now refers to them. The app moves
+	// them into the vault and clears this field.
+	LegacySecrets map[string]string `json:"legacySecrets,omitempty"`
+}
+
+// Preset is a named share configuration that can be started in one click
+type Preset struct {
+	Name  string              `json:"name"`
+	Share session.ShareConfig `json:"share"`
+}
+
+// Defaults returns the settings of a fresh install
+func Defaults() Settings {
+	return Settings{
+		Version:          CurrentVersion,
+		DefaultPort:      "8080",
+		NgrokTokenSecret: NgrokTokenSecret,
+		RecentPaths:      []string{},
+		Presets:          []Preset{},
+		LastSession:      []session.ShareConfig{},
+	}
+}
+
+// migrations[i] upgrades a raw settings document from version i to i+1
+var migrations = []func(doc map[string]interface{}){
+	// 0 -> 1: files written before the schema was versioned may miss fields
+	// whose zero value is not a sensible default
+	func(doc map[string]interface{}) {
+		if port, _ := doc["defaultPort"].(string); port == "" {
+			doc["defaultPort"] = "8080"
+		}
+	},
+	// 1 -> 2: the ngrok token and passwords move to the secrets vault
+	func(doc map[string]interface{}) {
+		legacy := make(map[string]interface{})
+		moveSecret := func(obj map[string]interface{}, field, ref, name string) {
+			value, _ := obj[field].(string)
+			delete(obj, field)
+			if value != "" {
+				legacy[name] = value
+				obj[ref] = name
+			}
+		}
+
+		doc["ngrokTokenSecret"] = NgrokTokenSecret
+		moveSecret(doc, "ngrokToken", "ngrokTokenSecret", NgrokTokenSecret)
+		moveSecret(doc, "password", "passwordSecret", "default-password")
+		if presets, ok := doc["presets"].([]interface{}); ok {
+			for _, p := range presets {
+				preset, _ := p.(map[string]interface{})
+				share, _ := preset["share"].(map[string]interface{})
+				if name, _ := preset["name"].(string); share != nil {
+					moveSecret(share, "password", "passwordSecret", PresetPasswordSecret(name))
+				}
+			}
+		}
+		if shares, ok := doc["lastSession"].([]interface{}); ok {
+			for i, s := range shares {
+				if share, ok := s.(map[string]interface{}); ok {
+					moveSecret(share, "password", "passwordSecret", fmt.Sprintf("last-session/%d", i))
+				}
+			}
+		}
+		if len(legacy) > 0 {
+			doc["legacySecrets"] = legacy
+		}
+	},
+}
+
+// PresetPasswordSecret is the vault name of a preset's share password
+func PresetPasswordSecret(preset string) string {
+	return "preset/" + preset
+}
+
+// Store reads and writes the settings file
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultPath returns settings.json in the per-user config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "JustServe", "settings.json"), nil
+}
+
+// NewStore creates a store backed by the file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the location of the settings file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load reads the settings, migrating older schemas. A missing file yields defaults.
+func (s *Store) Load() (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *Store) load() (Settings, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Defaults(), nil
+	}
+	if err != nil {
+		return Defaults(), fmt.Errorf("cannot read settings: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Defaults(), fmt.Errorf("settings file is corrupted: %w", err)
+	}
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > CurrentVersion {
+		return Defaults(), fmt.Errorf("settings were written by a newer JustServe (schema %d)", version)
+	}
+	for ; version < CurrentVersion; version++ {
+		migrations[version](doc)
+	}
+	doc["version"] = CurrentVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return Defaults(), err
+	}
+	st := Defaults()
+	if err := json.Unmarshal(migrated, &st); err != nil {
+		return Defaults(), fmt.Errorf("settings file is corrupted: %w", err)
+	}
+	return st, nil
+}
+
+// Save writes the settings atomically. The file is private to the user
+// because it may hold the ngrok token and share passwords.
+func (s *Store) Save(st Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(st)
+}
+
+func (s *Store) save(st Settings) error {
+	st.Version = CurrentVersion
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("cannot create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".settings-*")
+	if err != nil {
+		return fmt.Errorf("cannot save settings: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save settings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save settings: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot save settings: %w", err)
+	}
+	return nil
+}
+
+// Update loads the settings, applies fn and saves the result
+func (s *Store) Update(fn func(st *Settings)) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return st, err
+	}
+	fn(&st)
+	return st, s.save(st)
+}
+
+// AddRecentPath moves path to the front of the recent list
+func (st *Settings) AddRecentPath(path string) {
+	if path == "" {
+		return
+	}
+	recent := []string{path}
+	for _, p := range st.RecentPaths {
+		if p != path && len(recent) < MaxRecentPaths {
+			recent = append(recent, p)
+		}
+	}
+	st.RecentPaths = recent
+}
+
+// SetPreset adds a preset or replaces the one with the same name
+func (st *Settings) SetPreset(p Preset) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("preset name is required")
+	}
+	for i := range st.Presets {
+		if st.Presets[i].Name == p.Name {
+			st.Presets[i] = p
+			return nil
+		}
+	}
+	st.Presets = append(st.Presets, p)
+	return nil
+}
+
+// DeletePreset removes a preset by name
+func (st *Settings) DeletePreset(name string) {
+	presets := st.Presets[:0]
+	for _, p := range st.Presets {
+		if p.Name != name {
+			presets = append(presets, p)
+		}
+	}
+	st.Presets = presets
+}
+
+// Preset looks up a preset by name
+func (st *Settings) Preset(name string) (Preset, bool) {
+	for _, p := range st.Presets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
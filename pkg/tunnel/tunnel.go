@@ -8,16 +8,43 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync"
 
 	"golang.ngrok.com/ngrok"
 	"golang.ngrok.com/ngrok/config"
+
+	"JustServe/pkg/events"
 )
 
-// StartNgrokTunnel creates an HTTP tunnel with Ngrok
-func StartNgrokTunnel(ctx context.Context, token string) (ngrok.Tunnel, error) {
+// StartNgrokTunnel creates an HTTP tunnel with Ngrok. Losing the connection
+// to ngrok emits "tunnel-disconnected" (events.Error) and getting it back
+// emits "tunnel-reconnected" (events.Notice); the tunnel URL stays the same.
+func StartNgrokTunnel(ctx context.Context, token string, sink events.Sink) (ngrok.Tunnel, error) {
+	sink = events.OrDiscard(sink)
+	var mu sync.Mutex
+	connected, lost := false, false
 	tun, err := ngrok.Listen(ctx,
 		config.HTTPEndpoint(),
 		ngrok.WithAuthtoken(token),
+		ngrok.WithConnectHandler(func(ctx context.Context, sess ngrok.Session) {
+			mu.Lock()
+			reconnected := connected && lost
+			connected, lost = true, false
+			mu.Unlock()
+			if reconnected {
+				sink.Emit("tunnel-reconnected", events.NewNotice())
+			}
+		}),
+		ngrok.WithDisconnectHandler(func(ctx context.Context, sess ngrok.Session, err error) {
+			// A nil error means the tunnel was closed on purpose
+			mu.Lock()
+			report := connected && !lost && err != nil
+			lost = lost || report
+			mu.Unlock()
+			if report {
+				sink.Emit("tunnel-disconnected", events.NewError(err))
+			}
+		}),
 	)
 	if err != nil {
 		return nil, err
@@ -38,13 +65,15 @@ func CreateProxyHandler(target string) (*http.Server, error) {
 	return server, nil
 }
 
-// HandleTCPConnection copies data between the incoming connection and local port
-func HandleTCPConnection(conn net.Conn, localPort string) {
+// HandleTCPConnection copies data between the incoming connection and local
+// port. A port that refuses the connection is reported as "proxy-error"
+// (events.Error); the proxy keeps accepting.
+func HandleTCPConnection(conn net.Conn, localPort string, sink events.Sink) {
 	defer conn.Close()
 
 	local, err := net.Dial("tcp", fmt.Sprintf("localhost:%s", localPort))
 	if err != nil {
-		fmt.Printf("Failed to dial local port %s: %v\n", localPort, err)
+		events.OrDiscard(sink).Emit("proxy-error", events.NewError(fmt.Errorf("failed to dial local port %s: %w", localPort, err)))
 		return
 	}
 	defer local.Close()
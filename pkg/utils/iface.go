@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"JustServe/pkg/errcode"
+)
+
+// InterfaceKind classifies a network adapter
+type InterfaceKind string
+
+const (
+	KindEthernet InterfaceKind = "ethernet"
+	KindWiFi     InterfaceKind = "wifi"
+	KindVPN      InterfaceKind = "vpn"
+	KindVirtual  InterfaceKind = "virtual" // Docker, Hyper-V, VirtualBox, VMware and other host-only adapters
+	KindLoopback InterfaceKind = "loopback"
+	KindOther    InterfaceKind = "other"
+)
+
+// NetInterface describes a network adapter
+type NetInterface struct {
+	Name  string        `json:"name"`
+	Kind  InterfaceKind `json:"kind"`
+	CIDRs []string      `json:"cidrs"` // e.g. "192.168.1.20/24", "fe80::1/64"
+	MAC   string        `json:"mac"`
+	Up    bool          `json:"up"`
+	Rank  int           `json:"rank"` // 0 for the adapter LAN URLs use, then in order of preference
+}
+
+var (
+	// ErrNoInterface is returned for an interface name that does not exist
+	ErrNoInterface = errcode.New(errcode.NotFound, "network interface not found")
+	// ErrNoInterfaceAddress is returned for an interface that is down or has no address
+	ErrNoInterfaceAddress = errcode.New(errcode.Unreachable, "network interface is down or has no address")
+)
+
+// Interfaces lists the network adapters, best suited for LAN sharing first:
+// adapters that are up with an address, then wired before Wi-Fi before VPN
+// and virtual adapters. Within a kind, the adapter of the default route wins.
+func Interfaces() ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	defaultIP := defaultRouteIP()
+
+	type ranked struct {
+		NetInterface
+		hasAddr, hasIPv4, isDefault bool
+		kindRank, index             int
+	}
+	list := make([]ranked, 0, len(ifaces))
+	for _, ifi := range ifaces {
+		r := ranked{NetInterface: NetInterface{
+			Name:  ifi.Name,
+			Kind:  interfaceKind(ifi),
+			CIDRs: []string{},
+			MAC:   ifi.HardwareAddr.String(),
+			Up:    ifi.Flags&net.FlagUp != 0,
+		}, index: ifi.Index}
+		r.kindRank = kindRank[r.Kind]
+		addrs, _ := ifi.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			r.CIDRs = append(r.CIDRs, ipnet.String())
+			r.hasAddr = true
+			if ipnet.IP.To4() != nil {
+				r.hasIPv4 = true
+			}
+			if defaultIP != nil && ipnet.IP.Equal(defaultIP) {
+				r.isDefault = true
+			}
+		}
+		list = append(list, r)
+	}
+
+	usable := func(r ranked) bool { return r.Up && r.hasAddr && r.Kind != KindLoopback }
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case usable(a) != usable(b):
+			return usable(a)
+		case a.kindRank != b.kindRank:
+			return a.kindRank < b.kindRank
+		case a.isDefault != b.isDefault:
+			return a.isDefault
+		case a.hasIPv4 != b.hasIPv4:
+			return a.hasIPv4
+		}
+		return a.index < b.index
+	})
+	result := make([]NetInterface, len(list))
+	for i, r := range list {
+		result[i] = r.NetInterface
+		result[i].Rank = i
+	}
+	return result, nil
+}
+
+// InterfaceIP returns the address a listener bound to the named interface
+// uses: its first IPv4 address, else a routable IPv6 one, else its link-local
+// IPv6 address with the zone ("fe80::1%eth0")
+func InterfaceIP(name string) (string, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", ErrNoInterface
+	}
+	if ifi.Flags&net.FlagUp == 0 {
+		return "", ErrNoInterfaceAddress
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return "", ErrNoInterfaceAddress
+	}
+	var v6, linkLocal string
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipnet.IP
+		switch {
+		case ip.To4() != nil:
+			return ip.String(), nil
+		case ip.IsLinkLocalUnicast():
+			if linkLocal == "" {
+				linkLocal = ip.String() + "%" + ifi.Name
+			}
+		case v6 == "":
+			v6 = ip.String()
+		}
+	}
+	switch {
+	case v6 != "":
+		return v6, nil
+	case linkLocal != "":
+		return linkLocal, nil
+	}
+	return "", ErrNoInterfaceAddress
+}
+
+// InterfaceBroadcast returns the IPv4 broadcast address of the named
+// interface's first subnet, or nil if it has no IPv4 address
+func InterfaceBroadcast(name string) net.IP {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	addrs, _ := ifi.Addrs()
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		ip, mask := ipnet.IP.To4(), ipnet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range bcast {
+			bcast[i] = ip[i] | ^mask[i]
+		}
+		return bcast
+	}
+	return nil
+}
+
+var kindRank = map[InterfaceKind]int{
+	KindEthernet: 0,
+	KindWiFi:     1,
+	KindOther:    2,
+	KindVPN:      3,
+	KindVirtual:  4,
+	KindLoopback: 5,
+}
+
+// Name patterns, matched against the lowercase interface name. Windows uses
+// friendly names ("Wi-Fi", "vEthernet (WSL)"), Unix systems driver names.
+var (
+	vpnPrefixes     = []string{"tun", "tap", "utun", "wg", "ppp", "ipsec", "tailscale", "zt", "nordlynx", "gpd"}
+	vpnWords        = []string{"vpn", "wireguard", "tailscale", "zerotier", "anyconnect", "fortinet", "globalprotect", "pangp", "wintun"}
+	virtualPrefixes = []string{"docker", "br-", "veth", "virbr", "vmnet", "vboxnet", "lxc", "lxd", "cni", "flannel", "cali", "vxlan", "podman", "ifb", "dummy", "bridge", "awdl", "llw", "anpi"}
+	virtualWords    = []string{"vethernet", "virtualbox", "vmware", "hyper-v", "virtual"}
+	wifiPrefixes    = []string{"wl", "ath"}
+	wifiWords       = []string{"wi-fi", "wifi", "wireless", "wlan"}
+	ethPrefixes     = []string{"eth", "en", "em"}
+	ethWords        = []string{"ethernet", "local area connection"}
+)
+
+// interfaceKind guesses the kind of an adapter from its flags, what the
+// operating system tells and its name
+func interfaceKind(ifi net.Interface) InterfaceKind {
+	if ifi.Flags&net.FlagLoopback != 0 {
+		return KindLoopback
+	}
+	name := strings.ToLower(ifi.Name)
+	switch {
+	case ifi.Flags&net.FlagPointToPoint != 0 || matches(name, vpnPrefixes, vpnWords):
+		return KindVPN
+	case matches(name, virtualPrefixes, virtualWords):
+		return KindVirtual
+	}
+	if kind := osInterfaceKind(ifi.Name); kind != "" {
+		return kind
+	}
+	switch {
+	case matches(name, wifiPrefixes, wifiWords):
+		return KindWiFi
+	case matches(name, ethPrefixes, ethWords):
+		return KindEthernet
+	}
+	return KindOther
+}
+
+func matches(name string, prefixes, words []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	for _, w := range words {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRouteIP returns the local address of the IPv4 default route, or nil.
+// Connecting a UDP socket sends nothing; it only picks the route.
+func defaultRouteIP() net.IP {
+	conn, err := net.Dial("udp4", "192.0.2.1:9") // TEST-NET-1, never routed
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
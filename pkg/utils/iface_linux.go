@@ -0,0 +1,23 @@
+//go:build linux
+
+package utils
+
+import "os"
+
+// osInterfaceKind asks sysfs whether an adapter is wireless, virtual or a
+// physical device
+func osInterfaceKind(name string) InterfaceKind {
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	switch {
+	case exists("/sys/class/net/" + name + "/wireless"), exists("/sys/class/net/" + name + "/phy80211"):
+		return KindWiFi
+	case exists("/sys/devices/virtual/net/" + name):
+		return KindVirtual
+	case exists("/sys/class/net/" + name + "/device"):
+		return KindEthernet
+	}
+	return ""
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package utils
+
+// osInterfaceKind leaves the kind to the name heuristics
+func osInterfaceKind(name string) InterfaceKind {
+	return ""
+}
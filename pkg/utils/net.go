@@ -2,50 +2,137 @@ package utils
 
 import (
 	"net"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// GetPreferredLocalIP returns the best local IP for LAN transfer
-// Prefers 192.168.x.x or 10.x.x.x
+// GetPreferredLocalIP returns the best local IP for LAN transfer: IPv4 before
+// IPv6, from the best ranked adapter (see Interfaces)
 func GetPreferredLocalIP() string {
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
+	ips := localIPs()
+	if len(ips) == 0 {
 		return "localhost"
 	}
+	return ips[0]
+}
 
-	var fallbackIP string
-	for _, address := range addrs {
-		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ip := ipnet.IP.String()
-				if strings.HasPrefix(ip, "192.168.") || strings.HasPrefix(ip, "10.") {
-					return ip
-				}
-				if fallbackIP == "" {
-					fallbackIP = ip
-				}
+// GetPreferredLocalIPs returns the best local IP of each family, IPv4 first.
+// Link-local IPv6 addresses are only used when nothing else is configured.
+func GetPreferredLocalIPs() []string {
+	ips := localIPs()
+	var v4, v6 string
+	for _, ip := range ips {
+		switch {
+		case !strings.Contains(ip, ":"):
+			if v4 == "" {
+				v4 = ip
+			}
+		case !strings.Contains(ip, "%"):
+			if v6 == "" {
+				v6 = ip
 			}
 		}
 	}
-	if fallbackIP != "" {
-		return fallbackIP
+	var list []string
+	for _, ip := range []string{v4, v6} {
+		if ip != "" {
+			list = append(list, ip)
+		}
+	}
+	if len(list) == 0 && len(ips) > 0 {
+		list = ips[:1]
+	}
+	return list
+}
+
+// RequestURL returns the absolute URL the client used for r. Public shares sit
+// behind the ngrok edge, which terminates TLS and sets X-Forwarded-Proto.
+func RequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
 	}
-	return "localhost"
+	return scheme + "://" + r.Host + r.URL.RequestURI()
 }
 
-// GetAllLocalIPs returns all non-loopback IPv4 addresses
+// GetAllLocalIPs returns all non-loopback addresses, IPv4 first. Link-local
+// IPv6 addresses carry their zone, e.g. "fe80::1%eth0".
 func GetAllLocalIPs() ([]string, error) {
-	var ips []string
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
+	if _, err := net.Interfaces(); err != nil {
 		return nil, err
 	}
-	for _, address := range addrs {
-		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP.String())
+	return localIPs(), nil
+}
+
+// HostPort joins host and port for use in a URL. IPv6 literals are bracketed
+// and their zone escaped: "[fe80::1%25eth0]:8080".
+func HostPort(host string, port int) string {
+	return net.JoinHostPort(strings.Replace(host, "%", "%25", 1), strconv.Itoa(port))
+}
+
+// ParseHostIP returns the IP in a Host header or URL host, with or without
+// port, brackets and zone, or nil for names
+func ParseHostIP(host string) net.IP {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	host, _, _ = strings.Cut(host, "%")
+	return net.ParseIP(host)
+}
+
+// MulticastInterfaces lists the interfaces that are up and can multicast
+func MulticastInterfaces() []net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var list []net.Interface
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 {
+			list = append(list, ifi)
+		}
+	}
+	return list
+}
+
+// localIPs lists the addresses of the interfaces that are up, IPv4 first and
+// link-local IPv6 last, each family in the order of Interfaces
+func localIPs() []string {
+	ifaces, err := Interfaces()
+	if err != nil {
+		return nil
+	}
+	type candidate struct {
+		ip   string
+		rank int
+	}
+	var list []candidate
+	for _, ifi := range ifaces {
+		if !ifi.Up || ifi.Kind == KindLoopback {
+			continue
+		}
+		for _, cidr := range ifi.CIDRs {
+			ip, _, err := net.ParseCIDR(cidr)
+			if err != nil || ip.IsLoopback() {
+				continue
+			}
+			switch {
+			case ip.To4() != nil:
+				list = append(list, candidate{ip.String(), 0})
+			case ip.IsLinkLocalUnicast():
+				list = append(list, candidate{ip.String() + "%" + ifi.Name, 2})
+			case ip.IsGlobalUnicast():
+				list = append(list, candidate{ip.String(), 1})
 			}
 		}
 	}
-	return ips, nil
+	sort.SliceStable(list, func(i, j int) bool { return list[i].rank < list[j].rank })
+	ips := make([]string, len(list))
+	for i, c := range list {
+		ips[i] = c.ip
+	}
+	return ips
 }
@@ -0,0 +1,26 @@
+package utils
+
+import "fmt"
+
+// PortOwner is the process listening on a TCP port
+type PortOwner struct {
+	PID  int    `json:"pid"` // 0 when the process belongs to another user and cannot be inspected
+	Name string `json:"name,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+func (o PortOwner) String() string {
+	switch {
+	case o.PID != 0:
+		return fmt.Sprintf("%s (pid %d)", o.Name, o.PID)
+	case o.User != "":
+		return "a process of user " + o.User
+	}
+	return "another process"
+}
+
+// FindPortOwner returns the process listening on the TCP port, or nil when
+// none is found or the platform does not tell (only Linux is supported)
+func FindPortOwner(port int) *PortOwner {
+	return findPortOwner(port)
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package utils
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the socket state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+func findPortOwner(port int) *PortOwner {
+	inode, uid := listeningSocket(port)
+	if inode == "" {
+		return nil
+	}
+	owner := &PortOwner{User: uid}
+	if u, err := user.LookupId(uid); err == nil {
+		owner.User = u.Username
+	}
+	if pid := socketPID(inode); pid != 0 {
+		owner.PID = pid
+		comm, _ := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm")
+		owner.Name = strings.TrimSpace(string(comm))
+	}
+	return owner
+}
+
+// listeningSocket returns the inode and owner uid of the IPv4 or IPv6 socket
+// listening on port, from the socket tables in /proc/net
+func listeningSocket(port int) (inode, uid string) {
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListen || fields[9] == "0" {
+				continue
+			}
+			local := fields[1] // Hex "0100007F:1F90"
+			p, err := strconv.ParseUint(local[strings.LastIndexByte(local, ':')+1:], 16, 16)
+			if err == nil && int(p) == port {
+				f.Close()
+				return fields[9], fields[7]
+			}
+		}
+		f.Close()
+	}
+	return "", ""
+}
+
+// socketPID finds the process with a file descriptor open on the socket.
+// Processes of other users are skipped unless we run as root.
+func socketPID(inode string) int {
+	target := "socket:[" + inode + "]"
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		dir := "/proc/" + p.Name() + "/fd/"
+		fds, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(dir + fd.Name()); err == nil && link == target {
+				return pid
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package utils
+
+// findPortOwner needs a platform-specific socket table; only Linux has one
+func findPortOwner(port int) *PortOwner {
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"JustServe/pkg/errcode"
+	"JustServe/pkg/secrets"
+	"JustServe/pkg/settings"
+)
+
+// Vault bindings only accept secret values; they never return them, so
+// tokens and passwords stay on the Go side of the bridge.
+
+// GetVaultStatus reports whether the vault is locked and which secrets it holds
+func (a *App) GetVaultStatus() (secrets.Status, error) {
+	if a.vault == nil {
+		return secrets.Status{}, a.vaultErr
+	}
+	return a.vault.Status(), nil
+}
+
+// UnlockVault unlocks a passphrase-protected vault
+func (a *App) UnlockVault(passphrase string) error {
+	if a.vault == nil {
+		return a.vaultErr
+	}
+	if err := a.vault.Unlock(passphrase); err != nil {
+		return err
+	}
+	a.importLegacySecrets()
+	return nil
+}
+
+// LockVault forgets the key of a passphrase-protected vault
+func (a *App) LockVault() {
+	if a.vault != nil {
+		a.vault.Lock()
+	}
+}
+
+// SetVaultPassphrase re-encrypts the vault with a passphrase, or with a key
+// derived from this machine when passphrase is empty
+func (a *App) SetVaultPassphrase(passphrase string) error {
+	if a.vault == nil {
+		return a.vaultErr
+	}
+	return a.vault.SetPassphrase(passphrase)
+}
+
+// SetSecret stores a secret (e.g. the ngrok token) under name
+func (a *App) SetSecret(name string, value string) error {
+	return a.storeSecret(name, value)
+}
+
+// DeleteSecret removes a secret
+func (a *App) DeleteSecret(name string) error {
+	if a.vault == nil {
+		return a.vaultErr
+	}
+	return a.vault.Delete(name)
+}
+
+// secret resolves a secret by name for use on the Go side
+func (a *App) secret(name string) (string, error) {
+	if name == "" {
+		return "", errcode.New(errcode.InvalidInput, "no secret name given")
+	}
+	if a.vault == nil {
+		return "", a.vaultErr
+	}
+	return a.vault.Get(name)
+}
+
+func (a *App) storeSecret(name string, value string) error {
+	if a.vault == nil {
+		return a.vaultErr
+	}
+	return a.vault.Set(name, value)
+}
+
+// importLegacySecrets moves cleartext values found while migrating an older
+// settings file into the vault. It waits until the vault is unlocked.
+func (a *App) importLegacySecrets() {
+	st, err := a.settings.Load()
+	if err != nil || len(st.LegacySecrets) == 0 || a.vault == nil || a.vault.Status().Locked {
+		return
+	}
+	for name, value := range st.LegacySecrets {
+		if err := a.vault.Set(name, value); err != nil {
+			runtime.LogErrorf(a.ctx, "Failed to move %s into the vault: %v", name, err)
+			return
+		}
+	}
+	if _, err := a.settings.Update(func(st *settings.Settings) { st.LegacySecrets = nil }); err != nil {
+		runtime.LogErrorf(a.ctx, "Failed to save settings: %v", err)
+	}
+}